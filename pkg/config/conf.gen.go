@@ -8,11 +8,43 @@ type Databricks struct {
 	AccountId string `mapstructure:"account-id"`
 	DatabricksClientId string `mapstructure:"databricks-client-id"`
 	DatabricksClientSecret string `mapstructure:"databricks-client-secret"`
+	WorkspaceOauth bool `mapstructure:"workspace-oauth"`
 	Hostname string `mapstructure:"hostname"`
 	Workspaces []string `mapstructure:"workspaces"`
 	WorkspaceTokens []string `mapstructure:"workspace-tokens"`
+	PatWorkspaces []string `mapstructure:"pat-workspaces"`
+	PatWorkspaceTokens []string `mapstructure:"pat-workspace-tokens"`
 	BaseUrl string `mapstructure:"base-url"`
+	RecordDir string `mapstructure:"record-dir"`
 	DatabricksExcludeWorkspaces []string `mapstructure:"databricks-exclude-workspaces"`
+	WorkspaceUrls []string `mapstructure:"workspace-urls"`
+	FlattenNestedGroups bool `mapstructure:"flatten-nested-groups"`
+	RetryMaxAttempts int `mapstructure:"retry-max-attempts"`
+	RetryMaxWait int `mapstructure:"retry-max-wait"`
+	RateLimitScimUsers int `mapstructure:"rate-limit-scim-users"`
+	RateLimitScimGroups int `mapstructure:"rate-limit-scim-groups"`
+	RateLimitRuleSets int `mapstructure:"rate-limit-rule-sets"`
+	RateLimitPermissionAssignments int `mapstructure:"rate-limit-permission-assignments"`
+	PageSize int `mapstructure:"page-size"`
+	PageConcurrency int `mapstructure:"page-concurrency"`
+	IncrementalSync bool `mapstructure:"incremental-sync"`
+	IncrementalStateDir string `mapstructure:"incremental-state-dir"`
+	IncrementalFullSyncInterval int `mapstructure:"incremental-full-sync-interval"`
+	AuditWarehouseId string `mapstructure:"audit-warehouse-id"`
+	AuditWorkspace string `mapstructure:"audit-workspace"`
+	SyncLastLogin bool `mapstructure:"sync-last-login"`
+	AuditExportFile string `mapstructure:"audit-export-file"`
+	OfflineExportDir string `mapstructure:"offline-export-dir"`
+	AzureTenantId string `mapstructure:"azure-tenant-id"`
+	AzureClientId string `mapstructure:"azure-client-id"`
+	AzureClientSecret string `mapstructure:"azure-client-secret"`
+	AzureClientCertificate string `mapstructure:"azure-client-certificate"`
+	OidcFederationClientId string `mapstructure:"oidc-federation-client-id"`
+	OidcTokenFile string `mapstructure:"oidc-token-file"`
+	OidcTokenEnv string `mapstructure:"oidc-token-env"`
+	GcpServiceAccountKey string `mapstructure:"gcp-service-account-key"`
+	GcpImpersonateServiceAccount string `mapstructure:"gcp-impersonate-service-account"`
+	DatabricksConfigProfile string `mapstructure:"databricks-config-profile"`
 }
 
 func (c *Databricks) findFieldByTag(tagValue string) (any, bool) {
@@ -7,9 +7,18 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/field"
 )
 
+// MaxPageSize is the largest page the Databricks SCIM APIs return.
+const MaxPageSize = 10000
+
 const (
 	DatabricksOAuth2Group         = "oauth2"
 	DatabricksWorkspaceTokenGroup = "workspace-token"
+	DatabricksMixedGroup          = "oauth2-workspace-token"
+	DatabricksAzureADGroup        = "azure-ad"
+	DatabricksOIDCFederationGroup = "oidc-federation"
+	DatabricksGCPGroup            = "gcp"
+	DatabricksConfigProfileGroup  = "config-profile"
+	DatabricksOfflineExportGroup  = "offline-export"
 )
 
 var (
@@ -32,6 +41,14 @@ var (
 		field.WithRequired(true),
 		field.WithDisplayName("OAuth2 Client Secret"),
 	)
+	WorkspaceOAuthField = field.BoolField(
+		"workspace-oauth",
+		field.WithDescription(
+			"Request OAuth tokens from each workspace's own token endpoint instead of using one account token everywhere, "+
+				"for service principals without account-level federation",
+		),
+		field.WithDisplayName("Per-Workspace OAuth Tokens"),
+	)
 	WorkspacesField = field.StringSliceField(
 		"workspaces",
 		field.WithDescription(
@@ -48,6 +65,29 @@ var (
 		field.WithRequired(true),
 		field.WithDisplayName("Workspace Tokens"),
 	)
+	PATWorkspacesField = field.StringSliceField(
+		"pat-workspaces",
+		field.WithDescription(
+			"Workspaces, by deployment name, reached with personal access tokens instead of OAuth, "+
+				"in the same order as pat-workspace-tokens. Other workspaces are still discovered and synced with OAuth.",
+		),
+		field.WithRequired(true),
+		field.WithDisplayName("Personal Access Token Workspaces"),
+	)
+	PATWorkspaceTokensField = field.StringSliceField(
+		"pat-workspace-tokens",
+		field.WithDescription("The Databricks personal access tokens for the workspaces in pat-workspaces"),
+		field.WithIsSecret(true),
+		field.WithRequired(true),
+		field.WithDisplayName("Personal Access Tokens"),
+	)
+	WorkspaceURLsField = field.StringSliceField(
+		"workspace-urls",
+		field.WithDescription(
+			"Workspace URL overrides as deployment-name=URL pairs, for PrivateLink or custom DNS hosts that can't be derived from the workspace list",
+		),
+		field.WithDisplayName("Workspace URLs"),
+	)
 	AccountHostnameField = field.StringField(
 		"account-hostname",
 		field.WithDescription("The hostname used to connect to the Databricks account API. If not set, it will be calculated from the hostname field."),
@@ -65,21 +105,234 @@ var (
 		field.WithHidden(true),
 		field.WithExportTarget(field.ExportTargetCLIOnly),
 	)
+	RecordDirField = field.StringField(
+		"record-dir",
+		field.WithDescription("Save sanitized API requests and responses to this directory, as fixtures for regression tests"),
+		field.WithHidden(true),
+		field.WithExportTarget(field.ExportTargetCLIOnly),
+	)
 	ExcludeWorkspacesField = field.StringSliceField(
 		"databricks-exclude-workspaces",
 		field.WithDescription("Workspaces to exclude from sync, identified by workspace name, deployment name, or numeric workspace ID. Mutually exclusive with workspaces."),
 		field.WithDisplayName("Exclude Workspaces"),
 	)
+	AzureTenantIdField = field.StringField(
+		"azure-tenant-id",
+		field.WithDescription("The Entra ID tenant ID of the service principal used to connect to Azure Databricks"),
+		field.WithRequired(true),
+		field.WithDisplayName("Azure Tenant ID"),
+	)
+	AzureClientIdField = field.StringField(
+		"azure-client-id",
+		field.WithDescription("The Entra ID application (client) ID of the service principal used to connect to Azure Databricks"),
+		field.WithRequired(true),
+		field.WithDisplayName("Azure Client ID"),
+	)
+	AzureClientSecretField = field.StringField(
+		"azure-client-secret",
+		field.WithDescription("The Entra ID client secret of the service principal. Mutually exclusive with azure-client-certificate."),
+		field.WithIsSecret(true),
+		field.WithDisplayName("Azure Client Secret"),
+	)
+	AzureClientCertificateField = field.StringField(
+		"azure-client-certificate",
+		field.WithDescription(
+			"PEM-encoded certificate and unencrypted RSA private key registered on the Entra ID application. "+
+				"Mutually exclusive with azure-client-secret.",
+		),
+		field.WithIsSecret(true),
+		field.WithDisplayName("Azure Client Certificate"),
+	)
+	OIDCFederationClientIdField = field.StringField(
+		"oidc-federation-client-id",
+		field.WithDescription(
+			"The application ID of the Databricks service principal whose federation policy trusts the OIDC token. "+
+				"Leave empty to use an account-wide federation policy.",
+		),
+		field.WithDisplayName("Federated Service Principal Application ID"),
+	)
+	OIDCTokenFileField = field.StringField(
+		"oidc-token-file",
+		field.WithDescription("Path to a file holding the workload's OIDC token, e.g. a projected Kubernetes service account token. Re-read on every token exchange."),
+		field.WithDisplayName("OIDC Token File"),
+	)
+	OIDCTokenEnvField = field.StringField(
+		"oidc-token-env",
+		field.WithDescription("Name of the environment variable holding the workload's OIDC token. Used when oidc-token-file is not set."),
+		field.WithDisplayName("OIDC Token Environment Variable"),
+	)
+	GCPServiceAccountKeyField = field.StringField(
+		"gcp-service-account-key",
+		field.WithDescription(
+			"Contents of the Google service account JSON key file used to connect to Databricks on Google Cloud. "+
+				"Leave empty to use the GCE/GKE metadata server with gcp-impersonate-service-account.",
+		),
+		field.WithIsSecret(true),
+		field.WithDisplayName("GCP Service Account Key"),
+	)
+	GCPImpersonateServiceAccountField = field.StringField(
+		"gcp-impersonate-service-account",
+		field.WithDescription("Email of the Google service account to impersonate, added as a user or service principal in the Databricks account"),
+		field.WithDisplayName("GCP Service Account to Impersonate"),
+	)
+	DatabricksConfigProfileField = field.StringField(
+		"databricks-config-profile",
+		field.WithDescription(
+			"Name of the ~/.databrickscfg profile (or $DATABRICKS_CONFIG_FILE) to read the host, account ID, "+
+				"and client ID/secret or token from",
+		),
+		field.WithRequired(true),
+		field.WithDisplayName("Databricks Config Profile"),
+		field.WithExportTarget(field.ExportTargetCLIOnly),
+	)
+	FlattenNestedGroupsField = field.BoolField(
+		"flatten-nested-groups",
+		field.WithDescription(
+			"Resolve nested group membership during sync and emit a direct membership grant for every user and service principal "+
+				"reached through a nested group, for consumers that don't run grant expansion.",
+		),
+		field.WithDisplayName("Flatten Nested Groups"),
+	)
+	RetryMaxAttemptsField = field.IntField(
+		"retry-max-attempts",
+		field.WithDescription("Maximum attempts for a request that was throttled (429) or failed with a 5xx. Set to 1 to disable retries."),
+		field.WithDefaultValue(4),
+		field.WithDisplayName("Retry Max Attempts"),
+	)
+	RetryMaxWaitField = field.IntField(
+		"retry-max-wait",
+		field.WithDescription("Longest wait before a retry, in seconds. Requests whose Retry-After is longer fail instead."),
+		field.WithDefaultValue(30),
+		field.WithDisplayName("Retry Max Wait (seconds)"),
+	)
+	RateLimitSCIMUsersField = field.IntField(
+		"rate-limit-scim-users",
+		field.WithDescription("Requests per second sent to the SCIM Users and ServicePrincipals APIs of each host. Set to 0 to disable the limit."),
+		field.WithDefaultValue(20),
+		field.WithDisplayName("SCIM Users Rate Limit"),
+	)
+	RateLimitSCIMGroupsField = field.IntField(
+		"rate-limit-scim-groups",
+		field.WithDescription("Requests per second sent to the SCIM Groups API of each host. Set to 0 to disable the limit."),
+		field.WithDefaultValue(20),
+		field.WithDisplayName("SCIM Groups Rate Limit"),
+	)
+	RateLimitRuleSetsField = field.IntField(
+		"rate-limit-rule-sets",
+		field.WithDescription("Requests per second sent to the access control rule sets API of each host. Set to 0 to disable the limit."),
+		field.WithDefaultValue(10),
+		field.WithDisplayName("Rule Sets Rate Limit"),
+	)
+	RateLimitPermissionAssignmentsField = field.IntField(
+		"rate-limit-permission-assignments",
+		field.WithDescription("Requests per second sent to the workspace permission assignments API. Set to 0 to disable the limit."),
+		field.WithDefaultValue(10),
+		field.WithDisplayName("Permission Assignments Rate Limit"),
+	)
+	PageSizeField = field.IntField(
+		"page-size",
+		field.WithDescription(fmt.Sprintf("Number of users, groups and service principals requested per SCIM page, up to %d. Set to 0 to use the default of 50.", MaxPageSize)),
+		field.WithDefaultValue(50),
+		field.WithDisplayName("Page Size"),
+	)
+	PageConcurrencyField = field.IntField(
+		"page-concurrency",
+		field.WithDescription("Number of SCIM pages of users, groups and service principals fetched concurrently. Set to 1 to fetch pages one at a time."),
+		field.WithDefaultValue(1),
+		field.WithDisplayName("Page Concurrency"),
+	)
+	IncrementalSyncField = field.BoolField(
+		"incremental-sync",
+		field.WithDescription(
+			"Re-fetch only the users, groups and service principals changed since the previous sync. "+
+				"What was synced is kept in memory, so the first sync after a restart fetches everything unless incremental-state-dir is set.",
+		),
+		field.WithDefaultValue(false),
+		field.WithDisplayName("Incremental Sync"),
+	)
+	IncrementalStateDirField = field.StringField(
+		"incremental-state-dir",
+		field.WithDescription("Directory where incremental syncs keep what was synced between runs, one file per listing. Setting it enables incremental-sync."),
+		field.WithDisplayName("Incremental Sync State Directory"),
+		field.WithExportTarget(field.ExportTargetCLIOnly),
+	)
+	IncrementalFullSyncIntervalField = field.IntField(
+		"incremental-full-sync-interval",
+		field.WithDescription("Hours after which an incremental sync fetches everything again."),
+		field.WithDefaultValue(24),
+		field.WithDisplayName("Incremental Full Sync Interval (hours)"),
+	)
+	AuditWarehouseIdField = field.StringField(
+		"audit-warehouse-id",
+		field.WithDescription("ID of a SQL warehouse used to read the system.access.audit table. When set, the connector provides an event feed of logins and permission changes."),
+		field.WithDisplayName("Audit Log SQL Warehouse ID"),
+	)
+	AuditWorkspaceField = field.StringField(
+		"audit-workspace",
+		field.WithDescription("Deployment name of the workspace hosting the audit log SQL warehouse, e.g. dbc-a1b2c3d4-e5f6."),
+		field.WithDisplayName("Audit Log Workspace"),
+	)
+	SyncLastLoginField = field.BoolField(
+		"sync-last-login",
+		field.WithDescription("Set the last login of users and service principals from the audit log, read through the audit log SQL warehouse or from audit-export-file."),
+		field.WithDefaultValue(false),
+		field.WithDisplayName("Sync Last Login"),
+	)
+	AuditExportFileField = field.StringField(
+		"audit-export-file",
+		field.WithDescription("Path of a file of Databricks audit log delivery records, one JSON object per line, to read last logins from instead of the audit log SQL warehouse."),
+		field.WithDisplayName("Audit Log Export File"),
+		field.WithExportTarget(field.ExportTargetCLIOnly),
+	)
+	OfflineExportDirField = field.StringField(
+		"offline-export-dir",
+		field.WithDescription("Path of a directory of JSON exports of the Databricks APIs to sync from instead of the live API. See the README for its layout."),
+		field.WithRequired(true),
+		field.WithDisplayName("Offline Export Directory"),
+		field.WithExportTarget(field.ExportTargetCLIOnly),
+	)
 	configFields = []field.SchemaField{
 		AccountHostnameField,
 		AccountIdField,
 		DatabricksClientIdField,
 		DatabricksClientSecretField,
+		WorkspaceOAuthField,
 		HostnameField,
 		WorkspacesField,
 		WorkspaceTokensField,
+		PATWorkspacesField,
+		PATWorkspaceTokensField,
 		BaseURLField,
+		RecordDirField,
 		ExcludeWorkspacesField,
+		WorkspaceURLsField,
+		FlattenNestedGroupsField,
+		RetryMaxAttemptsField,
+		RetryMaxWaitField,
+		RateLimitSCIMUsersField,
+		RateLimitSCIMGroupsField,
+		RateLimitRuleSetsField,
+		RateLimitPermissionAssignmentsField,
+		PageSizeField,
+		PageConcurrencyField,
+		IncrementalSyncField,
+		IncrementalStateDirField,
+		IncrementalFullSyncIntervalField,
+		AuditWarehouseIdField,
+		AuditWorkspaceField,
+		SyncLastLoginField,
+		AuditExportFileField,
+		OfflineExportDirField,
+		AzureTenantIdField,
+		AzureClientIdField,
+		AzureClientSecretField,
+		AzureClientCertificateField,
+		OIDCFederationClientIdField,
+		OIDCTokenFileField,
+		OIDCTokenEnvField,
+		GCPServiceAccountKeyField,
+		GCPImpersonateServiceAccountField,
+		DatabricksConfigProfileField,
 	}
 )
 
@@ -99,8 +352,12 @@ var Config = field.NewConfiguration(
 			DisplayName: "OAuth2",
 			HelpText:    "Authenticate as a service principal using an OAuth2 client ID and secret.",
 			Fields: []field.SchemaField{
-				AccountIdField, DatabricksClientIdField, DatabricksClientSecretField,
-				HostnameField, AccountHostnameField, WorkspacesField, BaseURLField, ExcludeWorkspacesField,
+				AccountIdField, DatabricksClientIdField, DatabricksClientSecretField, WorkspaceOAuthField,
+				HostnameField, AccountHostnameField, WorkspacesField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
 			},
 			Default: true,
 		},
@@ -108,14 +365,103 @@ var Config = field.NewConfiguration(
 			Name:        DatabricksWorkspaceTokenGroup,
 			DisplayName: "Workspace token",
 			HelpText:    "Authenticate with a personal access token scoped to each workspace.",
-			Fields:      []field.SchemaField{AccountIdField, WorkspacesField, WorkspaceTokensField, HostnameField, AccountHostnameField, BaseURLField, ExcludeWorkspacesField},
+			Fields: []field.SchemaField{
+				AccountIdField, WorkspacesField, WorkspaceTokensField, HostnameField, AccountHostnameField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
+			},
 			Default:     false,
 		},
+		{
+			Name:        DatabricksMixedGroup,
+			DisplayName: "OAuth2 with workspace tokens",
+			HelpText:    "Authenticate with OAuth2 for the account and most workspaces, and with personal access tokens for selected workspaces.",
+			Fields: []field.SchemaField{
+				AccountIdField, DatabricksClientIdField, DatabricksClientSecretField, WorkspaceOAuthField,
+				PATWorkspacesField, PATWorkspaceTokensField,
+				HostnameField, AccountHostnameField, WorkspacesField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
+			},
+			Default: false,
+		},
+		{
+			Name:        DatabricksAzureADGroup,
+			DisplayName: "Azure Entra ID",
+			HelpText:    "Authenticate to Azure Databricks as an Entra ID service principal using a client secret or certificate.",
+			Fields: []field.SchemaField{
+				AccountIdField, AzureTenantIdField, AzureClientIdField, AzureClientSecretField, AzureClientCertificateField,
+				HostnameField, AccountHostnameField, WorkspacesField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
+			},
+			Default: false,
+		},
+		{
+			Name:        DatabricksOIDCFederationGroup,
+			DisplayName: "OIDC workload identity federation",
+			HelpText:    "Exchange an OIDC token issued to the workload (Kubernetes, GitHub Actions, ...) for a Databricks OAuth token, without static secrets.",
+			Fields: []field.SchemaField{
+				AccountIdField, OIDCFederationClientIdField, OIDCTokenFileField, OIDCTokenEnvField,
+				HostnameField, AccountHostnameField, WorkspacesField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
+			},
+			Default: false,
+		},
+		{
+			Name:        DatabricksGCPGroup,
+			DisplayName: "Google Cloud service account",
+			HelpText:    "Authenticate to Databricks on Google Cloud as a Google service account, using a key or impersonation.",
+			Fields: []field.SchemaField{
+				AccountIdField, GCPServiceAccountKeyField, GCPImpersonateServiceAccountField,
+				HostnameField, AccountHostnameField, WorkspacesField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
+			},
+			Default: false,
+		},
+		{
+			Name:        DatabricksConfigProfileGroup,
+			DisplayName: "Databricks CLI profile",
+			HelpText:    "Read credentials from a profile of the Databricks CLI config file, for local syncs.",
+			Fields: []field.SchemaField{
+				DatabricksConfigProfileField, AccountIdField, WorkspaceOAuthField, WorkspacesField, BaseURLField, RecordDirField, ExcludeWorkspacesField, WorkspaceURLsField,
+				FlattenNestedGroupsField, RetryMaxAttemptsField, RetryMaxWaitField,
+				RateLimitSCIMUsersField, RateLimitSCIMGroupsField, RateLimitRuleSetsField, RateLimitPermissionAssignmentsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				AuditWarehouseIdField, AuditWorkspaceField, SyncLastLoginField, AuditExportFileField,
+			},
+			Default: false,
+		},
+		{
+			Name:        DatabricksOfflineExportGroup,
+			DisplayName: "Offline export",
+			HelpText:    "Sync from JSON exports of the Databricks APIs in a directory, for accounts the connector can't reach.",
+			Fields: []field.SchemaField{
+				AccountIdField, OfflineExportDirField, WorkspacesField, ExcludeWorkspacesField,
+				FlattenNestedGroupsField,
+				PageSizeField, PageConcurrencyField, IncrementalSyncField, IncrementalStateDirField, IncrementalFullSyncIntervalField,
+				SyncLastLoginField, AuditExportFileField,
+			},
+			Default: false,
+		},
 	}),
 )
 
 // ValidateConfig enforces what field groups can't: OAuth/token exclusion when no
-// auth method is set, and equal-length workspaces/workspace-tokens.
+// auth method is set, equal-length workspace/token lists, and exactly one
+// Entra ID credential or federated token source, and a Google identity for GCP.
 func ValidateConfig(ctx context.Context, cfg *Databricks, authMethod string) error {
 	// A merged/stored config can carry both groups' fields; once authMethod picks one,
 	// prepareClientAuth only reads that group, so the other group's leftovers are inert.
@@ -131,5 +477,57 @@ func ValidateConfig(ctx context.Context, cfg *Databricks, authMethod string) err
 		)
 	}
 
+	if cfg.RetryMaxAttempts < 0 || cfg.RetryMaxWait < 0 {
+		return fmt.Errorf("databricks-connector: retry-max-attempts and retry-max-wait must not be negative")
+	}
+
+	if cfg.RateLimitScimUsers < 0 || cfg.RateLimitScimGroups < 0 || cfg.RateLimitRuleSets < 0 || cfg.RateLimitPermissionAssignments < 0 {
+		return fmt.Errorf("databricks-connector: rate limits must not be negative")
+	}
+
+	if cfg.PageSize < 0 || cfg.PageSize > MaxPageSize {
+		return fmt.Errorf("databricks-connector: page-size must be between 0 and %d, where 0 uses the default page size", MaxPageSize)
+	}
+
+	if cfg.PageConcurrency < 0 {
+		return fmt.Errorf("databricks-connector: page-concurrency must not be negative")
+	}
+
+	if cfg.IncrementalFullSyncInterval < 0 {
+		return fmt.Errorf("databricks-connector: incremental-full-sync-interval must not be negative")
+	}
+
+	if (cfg.AuditWarehouseId == "") != (cfg.AuditWorkspace == "") {
+		return fmt.Errorf("databricks-connector: audit-warehouse-id and audit-workspace must be set together")
+	}
+
+	if authMethod == DatabricksOfflineExportGroup && cfg.AuditWarehouseId != "" {
+		return fmt.Errorf("databricks-connector: audit-warehouse-id can't be used with offline exports, use audit-export-file")
+	}
+
+	if cfg.SyncLastLogin && cfg.AuditWarehouseId == "" && cfg.AuditExportFile == "" {
+		return fmt.Errorf("databricks-connector: sync-last-login requires audit-warehouse-id or audit-export-file")
+	}
+
+	if authMethod == DatabricksMixedGroup && len(cfg.PatWorkspaces) != len(cfg.PatWorkspaceTokens) {
+		return fmt.Errorf(
+			"databricks-connector: pat-workspaces and pat-workspace-tokens must be the same length, got %d workspaces and %d tokens",
+			len(cfg.PatWorkspaces),
+			len(cfg.PatWorkspaceTokens),
+		)
+	}
+
+	if authMethod == DatabricksAzureADGroup && (cfg.AzureClientSecret == "") == (cfg.AzureClientCertificate == "") {
+		return fmt.Errorf("databricks-connector: exactly one of azure-client-secret and azure-client-certificate must be set")
+	}
+
+	if authMethod == DatabricksOIDCFederationGroup && (cfg.OidcTokenFile == "") == (cfg.OidcTokenEnv == "") {
+		return fmt.Errorf("databricks-connector: exactly one of oidc-token-file and oidc-token-env must be set")
+	}
+
+	if authMethod == DatabricksGCPGroup && cfg.GcpServiceAccountKey == "" && cfg.GcpImpersonateServiceAccount == "" {
+		return fmt.Errorf("databricks-connector: gcp-service-account-key or gcp-impersonate-service-account must be set")
+	}
+
 	return nil
 }
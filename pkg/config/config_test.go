@@ -80,3 +80,127 @@ func TestValidateConfigTrustsExplicitAuthMethod(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 }
+
+func TestValidateConfigOIDCFederationTokenSource(t *testing.T) {
+	cases := []struct {
+		name      string
+		tokenFile string
+		tokenEnv  string
+		wantErr   bool
+	}{
+		{"file", "/var/run/secrets/tokens/databricks", "", false},
+		{"env", "", "ACTIONS_ID_TOKEN", false},
+		{"neither", "", "", true},
+		{"both", "/var/run/secrets/tokens/databricks", "ACTIONS_ID_TOKEN", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Databricks{OidcTokenFile: tc.tokenFile, OidcTokenEnv: tc.tokenEnv}
+			err := ValidateConfig(context.Background(), cfg, DatabricksOIDCFederationGroup)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigGCPIdentity(t *testing.T) {
+	if err := ValidateConfig(context.Background(), &Databricks{}, DatabricksGCPGroup); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	cfg := &Databricks{GcpImpersonateServiceAccount: "baton@project.iam.gserviceaccount.com"}
+	if err := ValidateConfig(context.Background(), cfg, DatabricksGCPGroup); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateConfigMixedTokenLengths(t *testing.T) {
+	cfg := &Databricks{
+		DatabricksClientId: "client-id",
+		PatWorkspaces:      []string{"ws-1", "ws-2"},
+		PatWorkspaceTokens: []string{"tok-1"},
+	}
+	if err := ValidateConfig(context.Background(), cfg, DatabricksMixedGroup); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	cfg.PatWorkspaceTokens = append(cfg.PatWorkspaceTokens, "tok-2")
+	if err := ValidateConfig(context.Background(), cfg, DatabricksMixedGroup); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateConfigRejectsNegativeRetrySettings(t *testing.T) {
+	if err := ValidateConfig(context.Background(), &Databricks{RetryMaxAttempts: -1}, DatabricksOAuth2Group); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestValidateConfigRejectsNegativeRateLimits(t *testing.T) {
+	if err := ValidateConfig(context.Background(), &Databricks{RateLimitRuleSets: -1}, DatabricksOAuth2Group); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestValidateConfigPageSettings(t *testing.T) {
+	for _, cfg := range []*Databricks{
+		{PageSize: -1},
+		{PageSize: MaxPageSize + 1},
+		{PageConcurrency: -1},
+	} {
+		if err := ValidateConfig(context.Background(), cfg, DatabricksOAuth2Group); err == nil {
+			t.Fatalf("expected error for %+v, got nil", cfg)
+		}
+	}
+
+	for _, cfg := range []*Databricks{
+		{PageSize: MaxPageSize, PageConcurrency: 4},
+		{PageSize: 0},
+	} {
+		if err := ValidateConfig(context.Background(), cfg, DatabricksOAuth2Group); err != nil {
+			t.Fatalf("expected no error for %+v, got %v", cfg, err)
+		}
+	}
+}
+
+func TestValidateConfigAuditLog(t *testing.T) {
+	for _, cfg := range []*Databricks{
+		{AuditWarehouseId: "abc123"},
+		{AuditWorkspace: "dbc-abc"},
+	} {
+		if err := ValidateConfig(context.Background(), cfg, DatabricksOAuth2Group); err == nil {
+			t.Fatalf("expected error for %+v, got nil", cfg)
+		}
+	}
+
+	if err := ValidateConfig(context.Background(), &Databricks{AuditWarehouseId: "abc123", AuditWorkspace: "dbc-abc"}, DatabricksOAuth2Group); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateConfigSyncLastLogin(t *testing.T) {
+	if err := ValidateConfig(context.Background(), &Databricks{SyncLastLogin: true}, DatabricksOAuth2Group); err == nil {
+		t.Fatal("expected error without an audit log source, got nil")
+	}
+
+	if err := ValidateConfig(context.Background(), &Databricks{SyncLastLogin: true, AuditExportFile: "audit.json"}, DatabricksOAuth2Group); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateConfigOfflineExport(t *testing.T) {
+	cfg := &Databricks{OfflineExportDir: "export", AuditWarehouseId: "abc123", AuditWorkspace: "dbc-abc"}
+	if err := ValidateConfig(context.Background(), cfg, DatabricksOfflineExportGroup); err == nil {
+		t.Fatal("expected error for an audit warehouse with an offline export, got nil")
+	}
+
+	cfg = &Databricks{OfflineExportDir: "export", SyncLastLogin: true, AuditExportFile: "audit.json"}
+	if err := ValidateConfig(context.Background(), cfg, DatabricksOfflineExportGroup); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -3,12 +3,13 @@ package connector
 import (
 	"context"
 	"fmt"
-	"slices"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/conductorone/baton-databricks/pkg/databricks"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
@@ -19,14 +20,23 @@ import (
 
 const (
 	// Roles relevant to Account API (Grantable to User or ServicePrincipal).
-	AccountAdminRole     = "account_admin"
-	MarketplaceAdminRole = "marketplace.admin"
+	AccountAdminRole = "account_admin"
+
+	// PasswordLoginAllowedEntitlement marks account users who can log in to the
+	// account console with a password, bypassing SSO. It reflects the account's
+	// SSO configuration and can't be provisioned.
+	PasswordLoginAllowedEntitlement = "password_login_allowed"
+
+	// Account roles other than account_admin are granted through the account
+	// rule set, where they are named "roles/<name>".
+	ruleSetRolePrefix = "roles/"
 
 	// Roles (or Entitlements) relevant to Workspace API (Grantable to User, Group or ServicePrincipal).
 	WorkspaceAccessRole    = "workspace-access"
 	SQLAccessRole          = "databricks-sql-access"
 	ClusterCreateRole      = "allow-cluster-create"
 	InstancePoolCreateRole = "allow-instance-pool-create"
+	WorkspaceConsumeRole   = "workspace-consume"
 
 	UsersType             = "users"
 	GroupsType            = "groups"
@@ -36,6 +46,54 @@ const (
 type accountBuilder struct {
 	client       *databricks.Client
 	resourceType *v2.ResourceType
+	sso          ssoSettingsCache
+}
+
+// ssoSettingsTTL is how long SSO settings read without a sync ID are kept.
+const ssoSettingsTTL = 5 * time.Minute
+
+// ssoSettingsCache keeps the account SSO settings for the length of a sync, so
+// listing the account, its entitlements and its grants reads them once.
+// Without a sync ID they are kept for ssoSettingsTTL instead.
+type ssoSettingsCache struct {
+	mu       sync.Mutex
+	read     bool
+	readAt   time.Time
+	syncID   string
+	settings *databricks.SSOSettings
+}
+
+// ssoSettings returns the account SSO settings, read at most once per sync. A
+// nil result with a nil error means the settings aren't available: the account
+// doesn't serve them, or the credentials can't read them. The account then
+// syncs without the password login entitlement. Other failures aren't kept,
+// so the next call tries again.
+func (a *accountBuilder) ssoSettings(ctx context.Context, syncID string) (*databricks.SSOSettings, error) {
+	a.sso.mu.Lock()
+	defer a.sso.mu.Unlock()
+
+	if a.sso.read && syncID == a.sso.syncID && (syncID != "" || time.Since(a.sso.readAt) < ssoSettingsTTL) {
+		return a.sso.settings, nil
+	}
+
+	settings, _, err := a.client.GetSSOSettings(ctx)
+	if isNotFoundError(err) || isAccessDeniedError(err) {
+		ctxzap.Extract(ctx).Warn(
+			"databricks-connector: account SSO settings are unavailable, syncing without password login",
+			zap.Error(err),
+		)
+		settings, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.sso.read = true
+	a.sso.readAt = time.Now()
+	a.sso.syncID = syncID
+	a.sso.settings = settings
+
+	return settings, nil
 }
 
 func (a *accountBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -43,7 +101,7 @@ func (a *accountBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 }
 
 // The Account API check below mirrors groupGrantParent (helpers.go); keep both in sync.
-func (a *accountBuilder) accountResource(_ context.Context) (*v2.Resource, error) {
+func (a *accountBuilder) accountResource(ctx context.Context, syncID string) (*v2.Resource, error) {
 	accountId := a.client.GetAccountId()
 	children := []protoreflect.ProtoMessage{
 		&v2.ChildResourceType{ResourceTypeId: workspaceResourceType.Id},
@@ -58,11 +116,28 @@ func (a *accountBuilder) accountResource(_ context.Context) (*v2.Resource, error
 		)
 	}
 
+	options := []rs.ResourceOption{
+		rs.WithAnnotation(children...),
+	}
+
+	if a.client.IsAccountAPIAvailable() {
+		sso, err := a.ssoSettings(ctx, syncID)
+		if err != nil {
+			ctxzap.Extract(ctx).Warn("databricks-connector: failed to get account SSO settings", zap.Error(err))
+		} else if sso != nil {
+			options = append(options, rs.WithResourceProfile(map[string]interface{}{
+				"sso_enabled":           sso.Enabled,
+				"sso_protocol":          sso.Protocol,
+				"unified_login_enabled": sso.UnifiedLoginEnabled,
+			}))
+		}
+	}
+
 	resource, err := rs.NewResource(
 		accountId,
 		accountResourceType,
 		accountId,
-		rs.WithAnnotation(children...),
+		options...,
 	)
 
 	if err != nil {
@@ -72,8 +147,8 @@ func (a *accountBuilder) accountResource(_ context.Context) (*v2.Resource, error
 	return resource, nil
 }
 
-func (a *accountBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, _ rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
-	ur, err := a.accountResource(ctx)
+func (a *accountBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
+	ur, err := a.accountResource(ctx, attr.SyncID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -81,11 +156,13 @@ func (a *accountBuilder) List(ctx context.Context, parentResourceID *v2.Resource
 	return []*v2.Resource{ur}, nil, nil
 }
 
-// Entitlements returns slice of entitlements for marketplace admins under account.
+// Entitlements returns the password login entitlement of the account, when
+// the account serves its SSO settings. Account roles, marketplace.admin
+// included, are entitlements of role resources.
 func (a *accountBuilder) Entitlements(
-	_ context.Context,
+	ctx context.Context,
 	resource *v2.Resource,
-	_ rs.SyncOpAttrs,
+	attr rs.SyncOpAttrs,
 ) (
 	[]*v2.Entitlement,
 	*rs.SyncOpResults,
@@ -94,196 +171,164 @@ func (a *accountBuilder) Entitlements(
 	if !a.client.IsAccountAPIAvailable() {
 		return nil, nil, nil
 	}
+
+	sso, err := a.ssoSettings(ctx, attr.SyncID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("databricks-connector: failed to get account SSO settings: %w", err)
+	}
+	if sso == nil {
+		return nil, nil, nil
+	}
+
 	return []*v2.Entitlement{
 		ent.NewPermissionEntitlement(
 			resource,
-			MarketplaceAdminRole,
-			ent.WithGrantableTo(userResourceType, groupResourceType, servicePrincipalResourceType),
-			ent.WithDisplayName(fmt.Sprintf("%s %s role", resource.DisplayName, MarketplaceAdminRole)),
-			ent.WithDescription(fmt.Sprintf("%s %s role in Databricks", resource.DisplayName, MarketplaceAdminRole)),
+			PasswordLoginAllowedEntitlement,
+			ent.WithGrantableTo(userResourceType),
+			ent.WithDisplayName(fmt.Sprintf("%s password login allowed", resource.DisplayName)),
+			ent.WithDescription(fmt.Sprintf("Can log in to the %s account console with a password, bypassing SSO", resource.DisplayName)),
 		),
 	}, nil, nil
 }
 
-// Grants returns grants for marketplace admins under account.
-// To get marketplace admins, we can only use the account API.
-func (a *accountBuilder) Grants(ctx context.Context, resource *v2.Resource, _ rs.SyncOpAttrs) ([]*v2.Grant, *rs.SyncOpResults, error) {
+// Grants returns password login grants under account.
+// When SSO is disabled every account user can log in with a password, so the
+// password login grants then page through all account users.
+func (a *accountBuilder) Grants(ctx context.Context, resource *v2.Resource, attr rs.SyncOpAttrs) ([]*v2.Grant, *rs.SyncOpResults, error) {
+	a.client.SetSyncID(attr.SyncID)
+
 	if !a.client.IsAccountAPIAvailable() {
 		return nil, nil, nil
 	}
 
-	var rv []*v2.Grant
-
-	// list rule sets for the account
-	ruleSets, _, err := a.client.ListRuleSets(ctx, "", "", "")
+	bag, page, err := parsePageToken(attr.PageToken.Token, &v2.ResourceId{ResourceType: accountResourceType.Id})
 	if err != nil {
-		return nil, nil, fmt.Errorf("databricks-connector: failed to list rule sets for account %s: %w", resource.Id.Resource, err)
+		return nil, nil, fmt.Errorf("databricks-connector: failed to parse page token: %w", err)
 	}
 
-	for _, ruleSet := range ruleSets {
-		// rule set contains role and its principals, each one with resource type and resource id seperated by "/"
-		if strings.Contains(ruleSet.Role, MarketplaceAdminRole) {
-			for _, p := range ruleSet.Principals {
-				resourceId, err := prepareResourceId(ctx, a.client, "", p)
-				if err != nil {
-					return nil, nil, fmt.Errorf("databricks-connector: failed to prepare resource id for principal %s: %w", p, err)
-				}
-
-				var annotations []protoreflect.ProtoMessage
-				if resourceId.ResourceType == groupResourceType.Id {
-					// Grants already returned early above when the account API is unavailable,
-					// so groups reaching this point are always account-parented.
-					groupParentResourceId, err := rs.NewResourceID(accountResourceType, a.client.GetAccountId())
-					if err != nil {
-						return rv, nil, err
-					}
-					rid, expandAnnotation, err := groupGrantExpansion(ctx, resourceId.Resource, groupParentResourceId)
-					if err != nil {
-						return rv, nil, err
-					}
-					resourceId = rid
-					annotations = append(annotations, expandAnnotation)
-				}
-
-				rv = append(rv, grant.NewGrant(resource, MarketplaceAdminRole, resourceId, grant.WithAnnotation(annotations...)))
-			}
-		}
-	}
+	var rv []*v2.Grant
+	switch bag.ResourceTypeID() {
+	case accountResourceType.Id:
+		bag.Pop()
 
-	return rv, nil, nil
-}
+		passwordLoginGrants, allUsers, err := a.passwordLoginGrants(ctx, resource, attr.SyncID)
+		if err != nil {
+			return nil, nil, err
+		}
 
-func (a *accountBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
-	l := ctxzap.Extract(ctx)
+		rv = passwordLoginGrants
+		if allUsers {
+			bag.Push(pagination.PageState{
+				ResourceTypeID: userResourceType.Id,
+			})
+		}
 
-	if !isValidPrincipal(principal.Id) {
-		l.Warn(
-			"databricks-connector: only users, groups and service principals can be granted account permissions",
-			zap.String("principal_id", principal.Id.String()),
-			zap.String("principal_type", principal.Id.ResourceType),
+	case userResourceType.Id:
+		users, total, _, err := a.client.ListUsers(
+			ctx,
+			"",
+			databricks.NewPaginationVars(page, a.client.PageSize()),
+			databricks.NewUserAttrVars(),
 		)
-
-		return nil, fmt.Errorf("databricks-connector: only users, groups and service principals can be granted account permissions")
-	}
-
-	accID := entitlement.Resource.Id.Resource
-	ruleSets, _, err := a.client.ListRuleSets(ctx, "", "", "")
-	if err != nil {
-		return nil, fmt.Errorf("databricks-connector: failed to list rule sets for account %s: %w", accID, err)
-	}
-
-	principalID, err := preparePrincipalId(ctx, a.client, "", principal.Id.ResourceType, principal.Id.Resource)
-	if err != nil {
-		return nil, fmt.Errorf("databricks-connector: failed to prepare principal id for principal %s: %w", principal.Id.Resource, err)
-	}
-
-	found := false
-
-	for i, ruleSet := range ruleSets {
-		if !strings.Contains(ruleSet.Role, entitlement.Slug) {
-			continue
+		if err != nil {
+			return nil, nil, fmt.Errorf("databricks-connector: failed to list users: %w", err)
 		}
 
-		found = true
+		for _, u := range users {
+			if !u.Active {
+				continue
+			}
 
-		// check if it contains the principals and add principal to the rule set
-		if slices.Contains(ruleSet.Principals, principalID) {
-			l.Info(
-				"databricks-connector: account already has the entitlement",
-				zap.String("principal_id", principalID),
-				zap.String("entitlement", entitlement.Slug),
-			)
+			uID, err := rs.NewResourceID(userResourceType, u.ID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("databricks-connector: failed to create user resource id: %w", err)
+			}
 
-			return nil, nil
+			rv = append(rv, grant.NewGrant(resource, PasswordLoginAllowedEntitlement, uID))
 		}
 
-		// add the principal to the rule set
-		ruleSets[i].Principals = append(ruleSets[i].Principals, principalID)
-	}
+		err = bag.Next(prepareNextToken(page, len(users), total))
+		if err != nil {
+			return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
+		}
 
-	if !found {
-		ruleSets = append(ruleSets, databricks.RuleSet{
-			Role:       fmt.Sprintf("roles/%s", entitlement.Slug),
-			Principals: []string{principalID},
-		})
+	default:
+		return nil, nil, fmt.Errorf("databricks-connector: invalid resource type: %s", bag.ResourceTypeID())
 	}
 
-	_, err = a.client.UpdateRuleSets(ctx, "", "", "", ruleSets)
+	nextPage, err := bag.Marshal()
 	if err != nil {
-		return nil, fmt.Errorf("databricks-connector: failed to update rule sets for account %s: %w", accID, err)
+		return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
 	}
 
-	return nil, nil
+	return rv, &rs.SyncOpResults{NextPageToken: nextPage}, nil
 }
 
-func (a *accountBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+// passwordLoginGrants returns password login grants for the emergency access
+// users when SSO is enabled. It reports true instead when SSO is disabled and
+// every account user can log in with a password. Accounts whose SSO settings
+// are unavailable have no password login entitlement, and so no grants; any
+// other failure is returned, since no grants would claim nobody can log in
+// with a password.
+func (a *accountBuilder) passwordLoginGrants(ctx context.Context, resource *v2.Resource, syncID string) ([]*v2.Grant, bool, error) {
 	l := ctxzap.Extract(ctx)
 
-	principal := grant.Principal
-	entitlement := grant.Entitlement
-
-	if !isValidPrincipal(principal.Id) {
-		l.Warn(
-			"databricks-connector: only users and service principals can have account permissions revoked",
-			zap.String("principal_id", principal.Id.String()),
-			zap.String("principal_type", principal.Id.ResourceType),
-		)
-
-		return nil, fmt.Errorf("databricks-connector: only users and service principals can have account permissions revoked")
-	}
-
-	accID := entitlement.Resource.Id.Resource
-	ruleSets, _, err := a.client.ListRuleSets(ctx, "", "", "")
+	sso, err := a.ssoSettings(ctx, syncID)
 	if err != nil {
-		return nil, fmt.Errorf("databricks-connector: failed to list rule sets for account %s: %w", accID, err)
+		return nil, false, fmt.Errorf("databricks-connector: failed to get account SSO settings: %w", err)
+	}
+	if sso == nil {
+		return nil, false, nil
 	}
 
-	if len(ruleSets) == 0 {
-		l.Info(
-			"databricks-connector: account already does not have the entitlement",
-			zap.String("principal_id", principal.Id.Resource),
-			zap.String("entitlement", entitlement.Slug),
-		)
-
-		return nil, nil
+	if !sso.Enabled {
+		return nil, true, nil
 	}
 
-	principalID, err := preparePrincipalId(ctx, a.client, "", principal.Id.ResourceType, principal.Id.Resource)
+	usernames, _, err := a.client.ListEmergencyAccessUsers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("databricks-connector: failed to prepare principal id: %w", err)
+		return nil, false, fmt.Errorf("databricks-connector: failed to list emergency access users: %w", err)
 	}
 
-	for i, ruleSet := range ruleSets {
-		if strings.Contains(ruleSet.Role, entitlement.Slug) {
-			// check if it contains the principals and remove the principal to the rule set
-			if slices.Contains(ruleSet.Principals, principalID) {
-				// if there is only one principal, remove the whole rule set
-				if len(ruleSet.Principals) == 1 {
-					ruleSets = slices.Delete(ruleSets, i, i+1)
-					break
-				} else {
-					pI := slices.Index(ruleSet.Principals, principalID)
-					ruleSets[i].Principals = slices.Delete(ruleSet.Principals, pI, pI+1)
-					break
-				}
-			}
+	var rv []*v2.Grant
+	for _, username := range usernames {
+		userID, _, err := a.client.FindUserID(ctx, "", username)
+		if err != nil {
+			return nil, false, fmt.Errorf("databricks-connector: failed to find user %s: %w", username, err)
+		}
 
-			l.Info(
-				"databricks-connector: account already does not have the entitlement",
-				zap.String("principal_id", principalID),
-				zap.String("entitlement", entitlement.Slug),
-			)
+		if userID == "" {
+			l.Warn("databricks-connector: emergency access user not found in account", zap.String("username", username))
+			continue
+		}
 
-			return nil, nil
+		uID, err := rs.NewResourceID(userResourceType, userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("databricks-connector: failed to create user resource id: %w", err)
 		}
+
+		rv = append(rv, grant.NewGrant(resource, PasswordLoginAllowedEntitlement, uID))
 	}
 
-	_, err = a.client.UpdateRuleSets(ctx, "", "", "", ruleSets)
-	if err != nil {
-		return nil, fmt.Errorf("databricks-connector: failed to update rule sets for account %s: %w", accID, err)
+	return rv, false, nil
+}
+
+// Grant rejects every account entitlement: password login follows the account
+// SSO configuration, and account roles are granted on role resources.
+func (a *accountBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	if entitlement.Slug == PasswordLoginAllowedEntitlement {
+		return nil, fmt.Errorf("databricks-connector: %s follows the account SSO configuration and can't be granted", PasswordLoginAllowedEntitlement)
+	}
+
+	return nil, fmt.Errorf("databricks-connector: account entitlement %s can't be granted, grant the role resource instead", entitlement.Slug)
+}
+
+func (a *accountBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	if grant.Entitlement.Slug == PasswordLoginAllowedEntitlement {
+		return nil, fmt.Errorf("databricks-connector: %s follows the account SSO configuration and can't be revoked", PasswordLoginAllowedEntitlement)
 	}
 
-	return nil, nil
+	return nil, fmt.Errorf("databricks-connector: account entitlement %s can't be revoked, revoke the role resource instead", grant.Entitlement.Slug)
 }
 
 func newAccountBuilder(client *databricks.Client) *accountBuilder {
@@ -0,0 +1,193 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+func TestPasswordLoginGrants(t *testing.T) {
+	ctx := context.Background()
+	account := &v2.Resource{Id: &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}}
+
+	t.Run("sso enabled grants emergency access users", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/sso"):
+				_, _ = w.Write([]byte(`{"enabled": true}`))
+			case strings.HasSuffix(r.URL.Path, "/sso/emergency-access"):
+				_, _ = w.Write([]byte(`{"users": ["admin@example.com", "missing@example.com"]}`))
+			case strings.HasSuffix(r.URL.Path, "/scim/v2/Users"):
+				if strings.Contains(r.URL.Query().Get("filter"), "admin@example.com") {
+					_, _ = w.Write([]byte(`{"Resources": [{"id": "u1", "userName": "admin@example.com"}], "totalResults": 1}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"Resources": [], "totalResults": 0}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		grants, allUsers, err := newAccountBuilder(client).passwordLoginGrants(ctx, account, "")
+		if err != nil {
+			t.Fatalf("passwordLoginGrants: %v", err)
+		}
+		if allUsers {
+			t.Error("expected only emergency access users with SSO enabled")
+		}
+		if len(grants) != 1 || grants[0].Principal.Id.Resource != "u1" {
+			t.Fatalf("expected a single grant to u1, got %v", grants)
+		}
+	})
+
+	t.Run("sso disabled grants every user", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"enabled": false}`))
+		}))
+
+		grants, allUsers, err := newAccountBuilder(client).passwordLoginGrants(ctx, account, "")
+		if err != nil {
+			t.Fatalf("passwordLoginGrants: %v", err)
+		}
+		if !allUsers || len(grants) != 0 {
+			t.Errorf("expected to defer to all users, got allUsers=%v grants=%v", allUsers, grants)
+		}
+	})
+
+	t.Run("settings not served emits nothing", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "not found"}`))
+		}))
+
+		grants, allUsers, err := newAccountBuilder(client).passwordLoginGrants(ctx, account, "")
+		if err != nil {
+			t.Fatalf("passwordLoginGrants: %v", err)
+		}
+		if allUsers || len(grants) != 0 {
+			t.Errorf("expected no grants, got allUsers=%v grants=%v", allUsers, grants)
+		}
+	})
+
+	t.Run("settings forbidden emits nothing", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "forbidden"}`))
+		}))
+
+		grants, allUsers, err := newAccountBuilder(client).passwordLoginGrants(ctx, account, "")
+		if err != nil {
+			t.Fatalf("passwordLoginGrants: %v", err)
+		}
+		if allUsers || len(grants) != 0 {
+			t.Errorf("expected no grants, got allUsers=%v grants=%v", allUsers, grants)
+		}
+	})
+
+	t.Run("settings failure is an error", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message": "bad request"}`))
+		}))
+
+		if _, _, err := newAccountBuilder(client).passwordLoginGrants(ctx, account, ""); err == nil {
+			t.Error("expected an error instead of no grants")
+		}
+	})
+
+	t.Run("emergency access failure is an error", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/sso") {
+				_, _ = w.Write([]byte(`{"enabled": true}`))
+				return
+			}
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "forbidden"}`))
+		}))
+
+		if _, _, err := newAccountBuilder(client).passwordLoginGrants(ctx, account, ""); err == nil {
+			t.Error("expected an error instead of no grants")
+		}
+	})
+}
+
+func TestSSOSettingsReadOncePerSync(t *testing.T) {
+	ctx := context.Background()
+	var requests atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sso") {
+			requests.Add(1)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "not found"}`))
+	}))
+	client.UpdateAvailability(true, false)
+	b := newAccountBuilder(client)
+
+	resources, _, err := b.List(ctx, nil, rs.SyncOpAttrs{SyncID: "sync-1"})
+	if err != nil || len(resources) != 1 {
+		t.Fatalf("List = %v, %v", resources, err)
+	}
+	entitlements, _, err := b.Entitlements(ctx, resources[0], rs.SyncOpAttrs{SyncID: "sync-1"})
+	if err != nil {
+		t.Fatalf("Entitlements: %v", err)
+	}
+	if len(entitlements) != 0 {
+		t.Errorf("entitlements = %v, want none without SSO settings", entitlements)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("SSO settings requests = %d, want 1", n)
+	}
+
+	// Without a sync ID they are kept for a while too.
+	for range 2 {
+		if _, err := b.ssoSettings(ctx, ""); err != nil {
+			t.Fatalf("ssoSettings: %v", err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("SSO settings requests = %d, want 2", n)
+	}
+}
+
+func TestSSOSettingsTransientFailureNotKept(t *testing.T) {
+	ctx := context.Background()
+	var requests atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message": "try again"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"enabled": true}`))
+	}))
+	b := newAccountBuilder(client)
+
+	if _, err := b.ssoSettings(ctx, "sync-1"); err == nil {
+		t.Fatal("expected the first read to fail")
+	}
+	sso, err := b.ssoSettings(ctx, "sync-1")
+	if err != nil || sso == nil || !sso.Enabled {
+		t.Errorf("second read = %v, %v, want SSO enabled", sso, err)
+	}
+}
+
+func TestPasswordLoginAllowedIsNotProvisionable(t *testing.T) {
+	ctx := context.Background()
+	b := newAccountBuilder(nil)
+	account := &v2.Resource{Id: &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}}
+	user := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "u1"}}
+	entitlement := &v2.Entitlement{Resource: account, Slug: PasswordLoginAllowedEntitlement}
+
+	if _, err := b.Grant(ctx, user, entitlement); err == nil {
+		t.Error("expected Grant to fail")
+	}
+	if _, err := b.Revoke(ctx, &v2.Grant{Principal: user, Entitlement: entitlement}); err == nil {
+		t.Error("expected Revoke to fail")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/conductorone/baton-databricks/pkg/config"
 	"github.com/conductorone/baton-databricks/pkg/databricks"
@@ -16,19 +18,118 @@ import (
 )
 
 type Databricks struct {
-	client     *databricks.Client
-	workspaces []string
+	client              *databricks.Client
+	workspaces          []string
+	flattenNestedGroups bool
+	workspaceURLs       map[string]string
+	retryPolicy         databricks.RetryPolicy
+	rateLimits          map[databricks.EndpointFamily]int
+	pageSize            uint
+	pageConcurrency     int
+	incremental         *incrementalSync
+	auditWorkspace      string
+	auditWarehouseID    string
+	auditFeed           *auditFeed
+	lastLogin           bool
+	auditExportFile     string
+	logins              *loginActivity
+	recordDir           string
+}
+
+// Option configures optional connector behavior.
+type Option func(*Databricks)
+
+// WithFlattenNestedGroups makes group grants resolve nested groups and emit a
+// direct membership grant for every user and service principal they contain.
+func WithFlattenNestedGroups(flatten bool) Option {
+	return func(d *Databricks) {
+		d.flattenNestedGroups = flatten
+	}
+}
+
+// WithWorkspaceURLs overrides the URL of workspaces, by deployment name.
+func WithWorkspaceURLs(urls map[string]string) Option {
+	return func(d *Databricks) {
+		d.workspaceURLs = urls
+	}
+}
+
+// WithRetryPolicy sets how throttled and failed requests are retried.
+func WithRetryPolicy(p databricks.RetryPolicy) Option {
+	return func(d *Databricks) {
+		d.retryPolicy = p
+	}
+}
+
+// WithRateLimits sets the requests per second allowed for endpoint families
+// on each host.
+func WithRateLimits(limits map[databricks.EndpointFamily]int) Option {
+	return func(d *Databricks) {
+		d.rateLimits = limits
+	}
+}
+
+// WithPageSize sets the number of SCIM resources requested per page.
+func WithPageSize(size uint) Option {
+	return func(d *Databricks) {
+		d.pageSize = size
+	}
+}
+
+// WithPageConcurrency makes the user, group and service principal syncers
+// fetch up to n SCIM pages at once, once the first page reports the total.
+func WithPageConcurrency(n int) Option {
+	return func(d *Databricks) {
+		d.pageConcurrency = n
+	}
+}
+
+// WithIncrementalSync makes the user, group and service principal syncers
+// re-fetch only what changed since the previous sync, with a full fetch at
+// least every fullInterval. What was synced is kept in memory and, when dir
+// isn't empty, in files there, so that it outlives the process.
+func WithIncrementalSync(dir string, fullInterval time.Duration) Option {
+	return func(d *Databricks) {
+		d.incremental = newIncrementalSync(dir, fullInterval)
+	}
+}
+
+// WithAuditLog enables the audit log event feed, which queries
+// system.access.audit on the SQL warehouse warehouseID of workspace.
+func WithAuditLog(workspace, warehouseID string) Option {
+	return func(d *Databricks) {
+		d.auditWorkspace = workspace
+		d.auditWarehouseID = warehouseID
+	}
+}
+
+// WithLastLogin sets the last login of users and service principals from the
+// audit log, read from the audit log delivery file at exportFile or, when it is
+// empty, through the SQL warehouse configured with WithAuditLog.
+func WithLastLogin(exportFile string) Option {
+	return func(d *Databricks) {
+		d.lastLogin = true
+		d.auditExportFile = exportFile
+	}
+}
+
+// WithRecording saves sanitized API requests and responses to dir, for replay
+// in regression tests.
+func WithRecording(dir string) Option {
+	return func(d *Databricks) {
+		d.recordDir = dir
+	}
 }
 
 // ResourceSyncers returns a ResourceSyncerV2 for each resource type that should be synced from the upstream service.
 func (d *Databricks) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncerV2 {
 	syncers := []connectorbuilder.ResourceSyncerV2{
 		newAccountBuilder(d.client),
-		newGroupBuilder(d.client),
-		newServicePrincipalBuilder(d.client),
-		newUserBuilder(d.client),
+		newGroupBuilder(d.client, d.flattenNestedGroups, d.pageConcurrency, d.incremental),
+		newServicePrincipalBuilder(d.client, d.pageConcurrency, d.incremental, d.logins),
+		newUserBuilder(d.client, d.pageConcurrency, d.incremental, d.logins),
 		newWorkspaceBuilder(d.client, d.workspaces),
-		newRoleBuilder(d.client),
+		newRoleBuilder(d.client, d.pageConcurrency),
 	}
 
 	return syncers
@@ -161,21 +262,65 @@ func New(
 	auth databricks.Auth,
 	excludeWorkspaces []string,
 	workspaces []string,
+	opts ...Option,
 ) (*Databricks, error) {
 	httpClient, err := auth.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	client, err := databricks.NewClient(ctx, httpClient, hostname, accountHostname, accountID, baseURL, auth, excludeWorkspaces)
+	d := &Databricks{
+		workspaces:  workspaces,
+		retryPolicy: databricks.DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.recordDir != "" {
+		recorder, err := databricks.NewRecordingTransport(httpClient.Transport, d.recordDir)
+		if err != nil {
+			return nil, fmt.Errorf("databricks-connector: failed to record API requests: %w", err)
+		}
+
+		recording := *httpClient
+		recording.Transport = recorder
+		httpClient = &recording
+	}
+
+	d.client, err = databricks.NewClient(
+		ctx,
+		httpClient,
+		hostname,
+		accountHostname,
+		accountID,
+		baseURL,
+		auth,
+		excludeWorkspaces,
+		databricks.WithWorkspaceURLs(d.workspaceURLs),
+		databricks.WithRetryPolicy(d.retryPolicy),
+		databricks.WithRateLimits(d.rateLimits),
+		databricks.WithPageSize(d.pageSize),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Databricks{
-		client:     client,
-		workspaces: workspaces,
-	}, nil
+	if d.auditWarehouseID != "" {
+		d.auditFeed = newAuditFeed(d.client, d.workspaces, d.auditWorkspace, d.auditWarehouseID)
+	}
+
+	switch {
+	case !d.lastLogin:
+	case d.auditExportFile != "":
+		d.logins = newLoginActivity(exportLogins(d.auditExportFile))
+	case d.auditWarehouseID != "":
+		d.logins = newLoginActivity(warehouseLogins(d.client, d.auditWorkspace, d.auditWarehouseID, time.Now))
+	default:
+		return nil, fmt.Errorf("databricks-connector: last logins need an audit log SQL warehouse or export file")
+	}
+
+	return d, nil
 }
 
 // NewConnector returns a new connector builder from a configuration struct.
@@ -187,12 +332,63 @@ func NewConnector(ctx context.Context, cfg *config.Databricks, opts *cli.Connect
 		authMethod = opts.SelectedAuthMethod
 	}
 
+	if authMethod == config.DatabricksConfigProfileGroup {
+		var err error
+		authMethod, err = applyConfigProfile(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if authMethod == config.DatabricksOfflineExportGroup {
+		applyOfflineExport(cfg)
+	}
+
 	if err := config.ValidateConfig(ctx, cfg, authMethod); err != nil {
 		return nil, nil, err
 	}
 
 	accountHostname := getAccountHostname(cfg, cfg.Hostname)
-	auth := prepareClientAuth(ctx, cfg, authMethod, l)
+	auth, err := prepareClientAuth(ctx, cfg, authMethod, l)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workspaceURLs, err := parseWorkspaceURLs(cfg.WorkspaceUrls)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	connectorOpts := []Option{
+		WithFlattenNestedGroups(cfg.FlattenNestedGroups),
+		WithWorkspaceURLs(workspaceURLs),
+		WithRetryPolicy(databricks.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			MaxWait:     time.Duration(cfg.RetryMaxWait) * time.Second,
+			BaseDelay:   databricks.DefaultRetryPolicy.BaseDelay,
+		}),
+		WithRateLimits(map[databricks.EndpointFamily]int{
+			databricks.EndpointFamilySCIMUsers:             cfg.RateLimitScimUsers,
+			databricks.EndpointFamilySCIMGroups:            cfg.RateLimitScimGroups,
+			databricks.EndpointFamilyRuleSets:              cfg.RateLimitRuleSets,
+			databricks.EndpointFamilyPermissionAssignments: cfg.RateLimitPermissionAssignments,
+		}),
+		WithPageSize(uint(cfg.PageSize)), // #nosec G115 -- validated to be between 0 and config.MaxPageSize.
+		WithPageConcurrency(cfg.PageConcurrency),
+	}
+	if cfg.IncrementalSync || cfg.IncrementalStateDir != "" {
+		connectorOpts = append(connectorOpts, WithIncrementalSync(cfg.IncrementalStateDir, time.Duration(cfg.IncrementalFullSyncInterval)*time.Hour))
+	}
+	if cfg.AuditWarehouseId != "" {
+		connectorOpts = append(connectorOpts, WithAuditLog(cfg.AuditWorkspace, cfg.AuditWarehouseId))
+	}
+	if cfg.SyncLastLogin {
+		connectorOpts = append(connectorOpts, WithLastLogin(cfg.AuditExportFile))
+	}
+	if cfg.RecordDir != "" {
+		l.Warn("recording API requests and responses", zap.String("dir", cfg.RecordDir))
+		connectorOpts = append(connectorOpts, WithRecording(cfg.RecordDir))
+	}
 
 	cb, err := New(
 		ctx,
@@ -203,6 +399,7 @@ func NewConnector(ctx context.Context, cfg *config.Databricks, opts *cli.Connect
 		auth,
 		cfg.DatabricksExcludeWorkspaces,
 		cfg.Workspaces,
+		connectorOpts...,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -211,10 +408,67 @@ func NewConnector(ctx context.Context, cfg *config.Databricks, opts *cli.Connect
 	return cb, nil, nil
 }
 
-func prepareClientAuth(_ context.Context, cfg *config.Databricks, authMethod string, l *zap.Logger) databricks.Auth {
-	if authMethod == config.DatabricksWorkspaceTokenGroup {
+func prepareClientAuth(_ context.Context, cfg *config.Databricks, authMethod string, l *zap.Logger) (databricks.Auth, error) {
+	switch authMethod {
+	case config.DatabricksWorkspaceTokenGroup:
 		l.Debug("using workspace token auth", zap.String("account-id", cfg.AccountId))
-		return databricks.NewTokenAuth(cfg.Workspaces, cfg.WorkspaceTokens)
+		return databricks.NewTokenAuth(cfg.Workspaces, cfg.WorkspaceTokens), nil
+
+	case config.DatabricksMixedGroup:
+		l.Debug("using oauth with workspace tokens", zap.String("account-id", cfg.AccountId), zap.Strings("pat-workspaces", cfg.PatWorkspaces))
+		return databricks.NewMixedAuth(prepareOAuth2(cfg, l), databricks.NewTokenAuth(cfg.PatWorkspaces, cfg.PatWorkspaceTokens)), nil
+
+	case config.DatabricksAzureADGroup:
+		l.Debug("using azure entra id auth", zap.String("account-id", cfg.AccountId), zap.String("tenant-id", cfg.AzureTenantId))
+		if cfg.AzureClientCertificate != "" {
+			auth, err := databricks.NewAzureADWithCertificate(cfg.AzureTenantId, cfg.AzureClientId, cfg.AzureClientCertificate)
+			if err != nil {
+				return nil, fmt.Errorf("databricks-connector: failed to prepare azure entra id auth: %w", err)
+			}
+
+			return auth, nil
+		}
+
+		return databricks.NewAzureADWithSecret(cfg.AzureTenantId, cfg.AzureClientId, cfg.AzureClientSecret), nil
+
+	case config.DatabricksOIDCFederationGroup:
+		l.Debug("using oidc workload identity federation", zap.String("account-id", cfg.AccountId))
+		return databricks.NewOIDCFederation(
+			cfg.AccountId,
+			cfg.OidcFederationClientId,
+			getAccountHostname(cfg, cfg.Hostname),
+			cfg.OidcTokenFile,
+			cfg.OidcTokenEnv,
+		), nil
+
+	case config.DatabricksOfflineExportGroup:
+		l.Debug("using offline export", zap.String("account-id", cfg.AccountId), zap.String("dir", cfg.OfflineExportDir))
+		return databricks.NewOfflineAuth(cfg.OfflineExportDir), nil
+
+	case config.DatabricksGCPGroup:
+		l.Debug("using gcp service account auth", zap.String("account-id", cfg.AccountId), zap.String("impersonate", cfg.GcpImpersonateServiceAccount))
+		auth, err := databricks.NewGCP(cfg.GcpServiceAccountKey, cfg.GcpImpersonateServiceAccount)
+		if err != nil {
+			return nil, fmt.Errorf("databricks-connector: failed to prepare gcp auth: %w", err)
+		}
+
+		return auth, nil
+	}
+
+	return prepareOAuth2(cfg, l), nil
+}
+
+// prepareOAuth2 returns OAuth secret auth, with one token for the account or
+// one per workspace.
+func prepareOAuth2(cfg *config.Databricks, l *zap.Logger) databricks.Auth {
+	if cfg.WorkspaceOauth {
+		l.Debug("using per-workspace oauth", zap.String("account-id", cfg.AccountId))
+		return databricks.NewWorkspaceOAuth2(
+			cfg.AccountId,
+			cfg.DatabricksClientId,
+			cfg.DatabricksClientSecret,
+			getAccountHostname(cfg, cfg.Hostname),
+		)
 	}
 
 	l.Debug("using oauth", zap.String("account-id", cfg.AccountId))
@@ -226,6 +480,80 @@ func prepareClientAuth(_ context.Context, cfg *config.Databricks, authMethod str
 	)
 }
 
+// applyOfflineExport points the client at the hosts offline exports are served
+// under. Workspace URLs don't apply, since no request leaves the host.
+func applyOfflineExport(cfg *config.Databricks) {
+	cfg.Hostname = databricks.OfflineHostname
+	cfg.AccountHostname = databricks.OfflineAccountHostname
+	cfg.BaseUrl = databricks.OfflineBaseURL
+	cfg.WorkspaceUrls = nil
+}
+
+// applyConfigProfile fills cfg from the selected Databricks CLI profile and
+// returns the auth method matching the profile's credentials. The host always
+// comes from the profile; an account ID already set in cfg wins. A workspace
+// profile limits the sync to that workspace, unless workspaces are set: with
+// a token it is synced with that token, and with an OAuth secret through the
+// workspace's own token endpoint.
+func applyConfigProfile(cfg *config.Databricks) (string, error) {
+	profile, err := databricks.LoadConfigProfile(cfg.DatabricksConfigProfile)
+	if err != nil {
+		return "", fmt.Errorf("databricks-connector: failed to load databricks config profile: %w", err)
+	}
+
+	if cfg.AccountId == "" {
+		cfg.AccountId = profile.AccountID
+	}
+
+	if profile.IsAccountProfile() {
+		cfg.AccountHostname = profile.Host
+		cfg.Hostname = strings.TrimPrefix(profile.Host, "accounts.")
+	} else {
+		deployment, hostname := databricks.SplitWorkspaceHost(profile.Host)
+		cfg.Hostname = hostname
+
+		if profile.Token != "" {
+			cfg.Workspaces = []string{deployment}
+			cfg.WorkspaceTokens = []string{profile.Token}
+			return config.DatabricksWorkspaceTokenGroup, nil
+		}
+
+		if len(cfg.Workspaces) == 0 {
+			cfg.Workspaces = []string{deployment}
+		}
+		cfg.WorkspaceOauth = true
+	}
+
+	if profile.ClientID == "" {
+		return "", fmt.Errorf("databricks-connector: profile %q needs a token, or client_id and client_secret", profile.Name)
+	}
+
+	if cfg.AccountId == "" {
+		return "", fmt.Errorf("databricks-connector: profile %q has client_id and client_secret but no account_id; add account_id to the profile or set account-id", profile.Name)
+	}
+
+	cfg.DatabricksClientId = profile.ClientID
+	cfg.DatabricksClientSecret = profile.ClientSecret
+
+	return config.DatabricksOAuth2Group, nil
+}
+
+// parseWorkspaceURLs parses deployment-name=URL pairs.
+func parseWorkspaceURLs(pairs []string) (map[string]string, error) {
+	urls := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		deploymentName, u, ok := strings.Cut(pair, "=")
+		deploymentName, u = strings.TrimSpace(deploymentName), strings.TrimSpace(u)
+		if !ok || deploymentName == "" || u == "" {
+			return nil, fmt.Errorf("databricks-connector: invalid workspace-urls entry %q, expected deployment-name=URL", pair)
+		}
+
+		urls[deploymentName] = u
+	}
+
+	return urls, nil
+}
+
 // getAccountHostname returns the account hostname from config if set, otherwise calculates it from hostname.
 func getAccountHostname(cfg *config.Databricks, hostname string) string {
 	if cfg.AccountHostname != "" {
@@ -1,11 +1,41 @@
 package connector
 
 import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/conductorone/baton-databricks/pkg/config"
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/cli"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"go.uber.org/zap"
 )
 
+// newTestClient returns a client whose account API is served by handler.
+func newTestClient(t *testing.T, handler http.Handler, opts ...databricks.ClientOption) *databricks.Client {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := databricks.NewClient(context.Background(), srv.Client(), "example.com", srv.Listener.Addr().String(), "acc-1", "", &databricks.NoAuth{}, nil, opts...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	c.UpdateAvailability(true, false)
+
+	return c
+}
+
 // Regression for CXH-2165: with account-hostname unset the Azure/GCP hostname
 // calculation must run. A non-empty field default here silently masks it.
 func TestGetAccountHostname(t *testing.T) {
@@ -35,3 +65,131 @@ func TestAccountHostnameFieldHasNoDefault(t *testing.T) {
 		t.Errorf("account-hostname field must have no default, got %q", v)
 	}
 }
+
+func TestApplyConfigProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "databrickscfg")
+	err := os.WriteFile(path, []byte(`
+[account]
+host          = https://accounts.azuredatabricks.net
+account_id    = acc-1
+client_id     = sp-client
+client_secret = sp-secret
+
+[workspace]
+host  = https://adb-123.4.azuredatabricks.net
+token = dapi123
+
+[workspace-oauth]
+host          = https://dbc-a1b2c3.cloud.databricks.com
+client_id     = sp-client
+client_secret = sp-secret
+`), 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv(databricks.ConfigFileEnv, path)
+
+	cfg := &config.Databricks{DatabricksConfigProfile: "account", Hostname: "cloud.databricks.com"}
+	authMethod, err := applyConfigProfile(cfg)
+	if err != nil {
+		t.Fatalf("applyConfigProfile: %v", err)
+	}
+	if authMethod != config.DatabricksOAuth2Group ||
+		cfg.AccountId != "acc-1" || cfg.Hostname != "azuredatabricks.net" || cfg.AccountHostname != "accounts.azuredatabricks.net" ||
+		cfg.DatabricksClientId != "sp-client" || cfg.DatabricksClientSecret != "sp-secret" {
+		t.Errorf("account profile: got %q, %+v", authMethod, cfg)
+	}
+
+	cfg = &config.Databricks{DatabricksConfigProfile: "workspace", Hostname: "cloud.databricks.com"}
+	authMethod, err = applyConfigProfile(cfg)
+	if err != nil {
+		t.Fatalf("applyConfigProfile: %v", err)
+	}
+	if authMethod != config.DatabricksWorkspaceTokenGroup || cfg.Hostname != "azuredatabricks.net" ||
+		!slices.Equal(cfg.Workspaces, []string{"adb-123.4"}) || !slices.Equal(cfg.WorkspaceTokens, []string{"dapi123"}) {
+		t.Errorf("workspace profile: got %q, %+v", authMethod, cfg)
+	}
+
+	// OAuth needs the account ID, which a workspace profile may not carry.
+	cfg = &config.Databricks{DatabricksConfigProfile: "workspace-oauth"}
+	if _, err := applyConfigProfile(cfg); err == nil || !strings.Contains(err.Error(), "add account_id to the profile") {
+		t.Errorf("expected an error naming the missing account_id, got %v", err)
+	}
+
+	cfg = &config.Databricks{DatabricksConfigProfile: "workspace-oauth", AccountId: "acc-2"}
+	authMethod, err = applyConfigProfile(cfg)
+	if err != nil {
+		t.Fatalf("applyConfigProfile: %v", err)
+	}
+	if authMethod != config.DatabricksOAuth2Group || cfg.AccountId != "acc-2" || cfg.Hostname != "cloud.databricks.com" ||
+		!cfg.WorkspaceOauth || !slices.Equal(cfg.Workspaces, []string{"dbc-a1b2c3"}) {
+		t.Errorf("workspace oauth profile: got %q, %+v", authMethod, cfg)
+	}
+
+	// The service principal gets tokens from the workspace's own endpoint.
+	auth, err := prepareClientAuth(context.Background(), cfg, authMethod, zap.NewNop())
+	if err != nil {
+		t.Fatalf("prepareClientAuth: %v", err)
+	}
+	if _, ok := auth.(*databricks.WorkspaceOAuth2); !ok {
+		t.Errorf("workspace oauth profile auth = %T, want workspace OAuth", auth)
+	}
+}
+
+func TestParseWorkspaceURLs(t *testing.T) {
+	got, err := parseWorkspaceURLs([]string{"dbc-a=https://a.privatelink.example.com", " adb-1.2 = adb-1.2.example.net "})
+	if err != nil {
+		t.Fatalf("parseWorkspaceURLs: %v", err)
+	}
+
+	want := map[string]string{"dbc-a": "https://a.privatelink.example.com", "adb-1.2": "adb-1.2.example.net"}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseWorkspaceURLs() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"dbc-a", "=https://a.example.com", "dbc-a="} {
+		if _, err := parseWorkspaceURLs([]string{bad}); err == nil {
+			t.Errorf("parseWorkspaceURLs(%q): expected error", bad)
+		}
+	}
+}
+
+func TestNewConnectorOfflineExport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"workspaces.json":    `[{"workspace_id": 123, "deployment_name": "dbc-abc"}]`,
+		"account/users.json": `[{"id": "u-1", "userName": "alice@example.com", "active": true}]`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	cfg := &config.Databricks{AccountId: "acc-1", OfflineExportDir: dir, PageSize: 50, PageConcurrency: 1}
+	cb, _, err := NewConnector(ctx, cfg, &cli.ConnectorOpts{SelectedAuthMethod: config.DatabricksOfflineExportGroup})
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+
+	d := cb.(*Databricks)
+	if _, err := d.Validate(ctx); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !d.client.IsAccountAPIAvailable() {
+		t.Error("expected the account API of the export to be available")
+	}
+
+	users, _, err := newUserBuilder(d.client, 1, nil, nil).List(ctx, &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != 1 || users[0].GetId().GetResource() != "u-1" {
+		t.Errorf("users = %v, want u-1", users)
+	}
+}
@@ -0,0 +1,546 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	auditFeedID = "databricks_audit_log"
+
+	auditDefaultPageSize = 100
+	auditMaxPageSize     = 1000
+	// auditDefaultLookback is how far back the feed starts when the caller
+	// gives no earliest event.
+	auditDefaultLookback = 24 * time.Hour
+)
+
+// auditEventKind is how an audit log action translates into baton events.
+type auditEventKind int
+
+const (
+	auditGroupMemberAdded auditEventKind = iota
+	auditGroupMemberRemoved
+	auditGroupChanged
+	auditUserChanged
+	auditAdminGranted
+	auditAdminRevoked
+	auditWorkspaceChanged
+	auditUsage
+)
+
+type auditAction struct {
+	service string
+	action  string
+}
+
+// auditActions are the audit log actions the feed reports. Every action of the
+// permissions service changes object ACLs, so the whole service is included.
+var auditActions = map[auditAction]auditEventKind{
+	{"accounts", "addPrincipalToGroup"}:      auditGroupMemberAdded,
+	{"accounts", "removePrincipalFromGroup"}: auditGroupMemberRemoved,
+	{"accounts", "createGroup"}:              auditGroupChanged,
+	{"accounts", "updateGroup"}:              auditGroupChanged,
+	{"accounts", "removeGroup"}:              auditGroupChanged,
+	{"accounts", "add"}:                      auditUserChanged,
+	{"accounts", "updateUser"}:               auditUserChanged,
+	{"accounts", "delete"}:                   auditUserChanged,
+	{"accounts", "setAdmin"}:                 auditAdminGranted,
+	{"accounts", "removeAdmin"}:              auditAdminRevoked,
+	{"accounts", "login"}:                    auditUsage,
+	{"accounts", "tokenLogin"}:               auditUsage,
+	{"unityCatalog", "updatePermissions"}:    auditWorkspaceChanged,
+}
+
+const permissionsService = "permissions"
+
+// auditQuery selects the events after a cursor, oldest first. Ties on
+// event_time are broken by event_id so a page can end between them.
+const auditQuery = `SELECT event_id, event_time, service_name, action_name, user_identity.email, to_json(request_params), workspace_id
+FROM system.access.audit
+WHERE response.status_code = 200
+  AND (service_name = '` + permissionsService + `' OR (service_name, action_name) IN (%s))
+  AND (event_time > :since OR (event_time = :since AND event_id > :after_id))
+ORDER BY event_time, event_id
+LIMIT %d`
+
+// auditCursor is the position of the last event returned by the feed.
+type auditCursor struct {
+	EventTime string `json:"event_time"`
+	EventID   string `json:"event_id"`
+}
+
+// auditEvent is a row of system.access.audit.
+type auditEvent struct {
+	ID            string
+	Time          string
+	Service       string
+	Action        string
+	Email         string
+	RequestParams map[string]string
+	WorkspaceID   string
+}
+
+// auditFeed reports permission and membership changes, and logins, from the
+// system.access.audit table, queried on a SQL warehouse of a workspace. Events
+// are only as fresh as the table, which Databricks updates within minutes.
+type auditFeed struct {
+	client      *databricks.Client
+	workspace   string
+	warehouseID string
+	now         func() time.Time
+
+	// workspaces maps numeric workspace IDs, as logged, to deployment names.
+	mu         sync.Mutex
+	workspaces map[string]string
+}
+
+// newAuditFeed returns the feed of the audit log queried on a warehouse of
+// workspace. The configured workspaces name the workspaces whose IDs can't be
+// listed without the Account API.
+func newAuditFeed(client *databricks.Client, configured []string, workspace, warehouseID string) *auditFeed {
+	f := &auditFeed{
+		client:      client,
+		workspace:   workspace,
+		warehouseID: warehouseID,
+		now:         time.Now,
+		workspaces:  make(map[string]string),
+	}
+	for _, name := range configured {
+		if id, ok := deploymentWorkspaceID(name); ok {
+			f.workspaces[id] = name
+		}
+	}
+
+	return f
+}
+
+// deploymentNameIDPattern matches the deployment names of Azure and GCP
+// workspaces, which embed the workspace ID, e.g. "adb-123.4" and "123.4".
+var deploymentNameIDPattern = regexp.MustCompile(`^(?:adb-)?(\d+)\.\d+$`)
+
+// deploymentWorkspaceID returns the workspace ID a deployment name embeds.
+func deploymentWorkspaceID(deploymentName string) (string, bool) {
+	m := deploymentNameIDPattern.FindStringSubmatch(deploymentName)
+	if m == nil {
+		return "", false
+	}
+
+	return m[1], true
+}
+
+// EventFeeds returns the audit log feed when a SQL warehouse is configured.
+func (d *Databricks) EventFeeds(ctx context.Context) []connectorbuilder.EventFeed {
+	if d.auditFeed == nil {
+		return nil
+	}
+
+	return []connectorbuilder.EventFeed{d.auditFeed}
+}
+
+func (f *auditFeed) EventFeedMetadata(ctx context.Context) *v2.EventFeedMetadata {
+	return v2.EventFeedMetadata_builder{
+		Id: auditFeedID,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_USAGE,
+			v2.EventType_EVENT_TYPE_RESOURCE_CHANGE,
+			v2.EventType_EVENT_TYPE_CREATE_GRANT,
+			v2.EventType_EVENT_TYPE_CREATE_REVOKE,
+		},
+	}.Build()
+}
+
+func (f *auditFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	cursor, err := f.startCursor(earliestEvent, pToken.Cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	size := pToken.Size
+	if size <= 0 {
+		size = auditDefaultPageSize
+	}
+	size = min(size, auditMaxPageSize)
+
+	// One extra row tells whether there are more events.
+	rows, err := f.query(ctx, cursor, size+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	hasMore := len(rows) > size
+	if hasMore {
+		rows = rows[:size]
+	}
+
+	var events []*v2.Event
+	for _, row := range rows {
+		event, err := f.event(ctx, row)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if event != nil {
+			events = append(events, event)
+		}
+
+		cursor = auditCursor{EventTime: row.Time, EventID: row.ID}
+	}
+
+	next, err := json.Marshal(cursor)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("databricks-connector: failed to create event cursor: %w", err)
+	}
+
+	return events, &pagination.StreamState{Cursor: string(next), HasMore: hasMore}, nil, nil
+}
+
+func (f *auditFeed) startCursor(earliestEvent *timestamppb.Timestamp, token string) (auditCursor, error) {
+	if token != "" {
+		var cursor auditCursor
+		if err := json.Unmarshal([]byte(token), &cursor); err != nil {
+			return auditCursor{}, fmt.Errorf("databricks-connector: failed to parse event cursor: %w", err)
+		}
+
+		return cursor, nil
+	}
+
+	since := f.now().Add(-auditDefaultLookback)
+	if earliestEvent != nil {
+		since = earliestEvent.AsTime()
+	}
+
+	return auditCursor{EventTime: since.UTC().Format(time.RFC3339Nano)}, nil
+}
+
+func (f *auditFeed) query(ctx context.Context, cursor auditCursor, limit int) ([]auditEvent, error) {
+	actions := make([]string, 0, len(auditActions))
+	for a := range auditActions {
+		// Service and action names are constants, not input.
+		actions = append(actions, fmt.Sprintf("('%s', '%s')", a.service, a.action))
+	}
+	slices.Sort(actions)
+
+	res, err := f.client.ExecuteStatement(
+		ctx,
+		f.workspace,
+		f.warehouseID,
+		fmt.Sprintf(auditQuery, strings.Join(actions, ", "), limit),
+		databricks.StatementParameter{Name: "since", Value: cursor.EventTime, Type: "TIMESTAMP"},
+		databricks.StatementParameter{Name: "after_id", Value: cursor.EventID, Type: "STRING"},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to query audit log: %w", err)
+	}
+
+	rv := make([]auditEvent, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		if len(row) < 7 {
+			return nil, fmt.Errorf("databricks-connector: unexpected audit log row with %d columns", len(row))
+		}
+
+		e := auditEvent{
+			ID:          stringValue(row[0]),
+			Time:        stringValue(row[1]),
+			Service:     stringValue(row[2]),
+			Action:      stringValue(row[3]),
+			Email:       stringValue(row[4]),
+			WorkspaceID: stringValue(row[6]),
+		}
+		if params := stringValue(row[5]); params != "" {
+			if err := json.Unmarshal([]byte(params), &e.RequestParams); err != nil {
+				return nil, fmt.Errorf("databricks-connector: failed to parse request params of audit event %s: %w", e.ID, err)
+			}
+		}
+
+		rv = append(rv, e)
+	}
+
+	return rv, nil
+}
+
+// parseAuditTime parses an event_time, which the Statement Execution API
+// returns in ISO 8601, with or without the T separator.
+func parseAuditTime(v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02 15:04:05.999999999Z07:00", v)
+}
+
+func stringValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+
+	return *v
+}
+
+// event translates an audit log row into a baton event, or nil if it doesn't
+// concern resources the connector syncs.
+func (f *auditFeed) event(ctx context.Context, e auditEvent) (*v2.Event, error) {
+	occurredAt, err := parseAuditTime(e.Time)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to parse time of audit event %s: %w", e.ID, err)
+	}
+
+	kind, ok := auditActions[auditAction{service: e.Service, action: e.Action}]
+	if !ok {
+		if e.Service != permissionsService {
+			return nil, nil
+		}
+		kind = auditWorkspaceChanged
+	}
+
+	workspace, err := f.workspaceName(ctx, e.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == "" && !f.client.IsAccountAPIAvailable() {
+		// Without the Account API only the configured workspaces are
+		// synced, and account principals can't be looked up, so events
+		// of the account and of other workspaces refer to nothing synced.
+		return nil, nil
+	}
+
+	event := v2.Event_builder{
+		Id:         e.ID,
+		OccurredAt: timestamppb.New(occurredAt),
+	}
+
+	switch kind {
+	case auditGroupMemberAdded, auditGroupMemberRemoved:
+		groupId, memberId := e.RequestParams["targetGroupId"], e.RequestParams["targetUserId"]
+		if groupId == "" || memberId == "" {
+			return nil, nil
+		}
+
+		group, err := f.groupResourceId(ctx, workspace, groupId)
+		if err != nil {
+			return nil, err
+		}
+
+		principal, err := f.principalResourceId(ctx, workspace, memberId)
+		if err != nil || principal == nil {
+			return nil, err
+		}
+
+		entitlement := ent.NewAssignmentEntitlement(&v2.Resource{Id: group}, groupMemberEntitlement)
+		setGrantEvent(&event, kind == auditGroupMemberAdded, entitlement, principal)
+
+	case auditAdminGranted, auditAdminRevoked:
+		principalId := e.RequestParams["targetUserId"]
+		if principalId == "" {
+			return nil, nil
+		}
+
+		principal, err := f.principalResourceId(ctx, "", principalId)
+		if err != nil || principal == nil {
+			return nil, err
+		}
+
+		role, err := roleResource(ctx, AccountAdminRole, &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: f.client.GetAccountId()})
+		if err != nil {
+			return nil, err
+		}
+
+		entitlement := ent.NewAssignmentEntitlement(role, RoleMemberEntitlement)
+		setGrantEvent(&event, kind == auditAdminGranted, entitlement, principal)
+
+	case auditGroupChanged:
+		groupId := e.RequestParams["targetGroupId"]
+		if groupId == "" {
+			return nil, nil
+		}
+
+		group, err := f.groupResourceId(ctx, workspace, groupId)
+		if err != nil {
+			return nil, err
+		}
+
+		event.ResourceChangeEvent = v2.ResourceChangeEvent_builder{ResourceId: group}.Build()
+
+	case auditUserChanged:
+		userId := e.RequestParams["targetUserId"]
+		if userId == "" {
+			return nil, nil
+		}
+
+		event.ResourceChangeEvent = v2.ResourceChangeEvent_builder{
+			ResourceId: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId},
+		}.Build()
+
+	case auditWorkspaceChanged:
+		if workspace == "" {
+			return nil, nil
+		}
+
+		event.ResourceChangeEvent = v2.ResourceChangeEvent_builder{
+			ResourceId: &v2.ResourceId{ResourceType: workspaceResourceType.Id, Resource: workspace},
+		}.Build()
+
+	case auditUsage:
+		actor, err := f.userResourceId(ctx, e.Email)
+		if err != nil || actor == nil {
+			return nil, err
+		}
+
+		target := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: f.client.GetAccountId()}
+		if workspace != "" {
+			target = &v2.ResourceId{ResourceType: workspaceResourceType.Id, Resource: workspace}
+		}
+
+		event.UsageEvent = v2.UsageEvent_builder{
+			TargetResource: &v2.Resource{Id: target},
+			ActorResource:  &v2.Resource{Id: actor},
+		}.Build()
+	}
+
+	return event.Build(), nil
+}
+
+// setGrantEvent makes event a grant, or a revoke, of entitlement to principal.
+func setGrantEvent(event *v2.Event_builder, granted bool, entitlement *v2.Entitlement, principal *v2.ResourceId) {
+	if granted {
+		event.CreateGrantEvent = v2.CreateGrantEvent_builder{Entitlement: entitlement, Principal: &v2.Resource{Id: principal}}.Build()
+	} else {
+		event.CreateRevokeEvent = v2.CreateRevokeEvent_builder{Entitlement: entitlement, Principal: &v2.Resource{Id: principal}}.Build()
+	}
+}
+
+// principalResourceId returns the ID of the user, service principal or group
+// with the given SCIM ID, which audit events log without its type, or nil if
+// there is none anymore. Principals are looked up in the account, or in the
+// workspace the event was logged in without the Account API.
+func (f *auditFeed) principalResourceId(ctx context.Context, workspace, id string) (*v2.ResourceId, error) {
+	var scimWorkspace string
+	if !f.client.IsAccountAPIAvailable() {
+		scimWorkspace = workspace
+	}
+
+	username, _, err := f.client.FindUsername(ctx, scimWorkspace, id)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to find principal %s: %w", id, err)
+	}
+	if username != "" {
+		return rs.NewResourceID(userResourceType, id)
+	}
+
+	appID, _, err := f.client.FindServicePrincipalAppID(ctx, scimWorkspace, id)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to find principal %s: %w", id, err)
+	}
+	if appID != "" {
+		return rs.NewResourceID(servicePrincipalResourceType, id)
+	}
+
+	displayName, _, err := f.client.FindGroupDisplayName(ctx, scimWorkspace, id)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to find principal %s: %w", id, err)
+	}
+	if displayName != "" {
+		return f.groupResourceId(ctx, workspace, id)
+	}
+
+	ctxzap.Extract(ctx).Debug("databricks-connector: audit event principal no longer exists", zap.String("principal_id", id))
+	return nil, nil
+}
+
+// groupResourceId returns the ID of a group synced under the account, or
+// under the workspace the event was logged in without the Account API.
+func (f *auditFeed) groupResourceId(ctx context.Context, workspace, groupId string) (*v2.ResourceId, error) {
+	parent, err := groupGrantParent(f.client.IsAccountAPIAvailable(), f.client.GetAccountId(), workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.NewResourceID(groupResourceType, groupResourceId(ctx, groupId, parent))
+}
+
+// userResourceId returns the ID of the account user with the given email, or
+// nil if there is none, e.g. for service principals.
+func (f *auditFeed) userResourceId(ctx context.Context, email string) (*v2.ResourceId, error) {
+	if email == "" {
+		return nil, nil
+	}
+
+	userId, _, err := f.client.FindUserID(ctx, "", email)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil
+		}
+
+		ctxzap.Extract(ctx).Debug("databricks-connector: failed to find audit event actor", zap.String("email", email), zap.Error(err))
+		return nil, nil
+	}
+	if userId == "" {
+		return nil, nil
+	}
+
+	return &v2.ResourceId{ResourceType: userResourceType.Id, Resource: userId}, nil
+}
+
+// workspaceName returns the deployment name of a workspace by its numeric ID,
+// or "" for account events, which are logged with workspace ID 0, and for
+// workspaces that aren't known. Workspace tokens can't list workspaces, so
+// they only know the configured workspaces whose deployment names embed
+// their IDs.
+func (f *auditFeed) workspaceName(ctx context.Context, workspaceId string) (string, error) {
+	if workspaceId == "" || workspaceId == "0" {
+		return "", nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if name, ok := f.workspaces[workspaceId]; ok {
+		return name, nil
+	}
+
+	if !f.client.IsTokenAuth() {
+		workspaces, _, err := f.client.ListWorkspaces(ctx)
+		switch {
+		case isAccessDeniedError(err):
+			ctxzap.Extract(ctx).Debug("databricks-connector: can't list workspaces to name audit events", zap.Error(err))
+		case err != nil:
+			return "", fmt.Errorf("databricks-connector: failed to list workspaces: %w", err)
+		}
+
+		for _, w := range workspaces {
+			f.workspaces[strconv.Itoa(w.ID)] = w.DeploymentName
+		}
+	}
+
+	// Remember unknown workspaces too, e.g. excluded ones, so they don't
+	// relist workspaces for every event.
+	name := f.workspaces[workspaceId]
+	f.workspaces[workspaceId] = name
+	if name == "" {
+		ctxzap.Extract(ctx).Debug("databricks-connector: audit event of an unknown workspace", zap.String("workspace_id", workspaceId))
+	}
+
+	return name, nil
+}
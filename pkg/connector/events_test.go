@@ -0,0 +1,295 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newAuditTestFeed returns an audit feed whose account API and audit workspace
+// "dbc-audit" are both served by a fake returning rows for each statement.
+func newAuditTestFeed(t *testing.T, rows func(params map[string]string) [][]*string) *auditFeed {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/2.0/sql/statements", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Statement  string                          `json:"statement"`
+			Parameters []databricks.StatementParameter `json:"parameters"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if !strings.Contains(body.Statement, "system.access.audit") {
+			t.Errorf("unexpected statement %q", body.Statement)
+		}
+
+		params := make(map[string]string)
+		for _, p := range body.Parameters {
+			params[p.Name] = p.Value
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"statement_id": "st-1",
+			"status":       map[string]any{"state": "SUCCEEDED"},
+			"result":       map[string]any{"data_array": rows(params)},
+		})
+	})
+	mux.HandleFunc("GET /api/2.0/accounts/acc-1/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"workspace_id": 123, "deployment_name": "dbc-abc"}]`))
+	})
+	mux.HandleFunc("GET /api/2.0/accounts/acc-1/scim/v2/Users", func(w http.ResponseWriter, r *http.Request) {
+		switch filter := r.URL.Query().Get("filter"); {
+		case strings.Contains(filter, "alice@example.com"):
+			_, _ = w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "u-1", "userName": "alice@example.com"}]}`))
+		case strings.Contains(filter, `"u-2"`):
+			_, _ = w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "u-2", "userName": "carol@example.com"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"totalResults": 0, "Resources": []}`))
+		}
+	})
+	mux.HandleFunc("GET /api/2.0/accounts/acc-1/scim/v2/ServicePrincipals", func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Query().Get("filter"), `"sp-1"`) {
+			_, _ = w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "sp-1", "applicationId": "app-1"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"totalResults": 0, "Resources": []}`))
+	})
+	mux.HandleFunc("GET /api/2.0/accounts/acc-1/scim/v2/Groups", func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Query().Get("filter"), `"g-2"`) {
+			_, _ = w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "g-2", "displayName": "nested"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"totalResults": 0, "Resources": []}`))
+	})
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	c, err := databricks.NewClient(
+		context.Background(), srv.Client(), "example.com", srv.Listener.Addr().String(), "acc-1", "", &databricks.NoAuth{}, nil,
+		databricks.WithWorkspaceURLs(map[string]string{"dbc-audit": srv.URL}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.UpdateAvailability(true, false)
+
+	return newAuditFeed(c, nil, "dbc-audit", "wh-1")
+}
+
+func auditRow(id, eventTime, service, action, email, params, workspaceId string) []*string {
+	return []*string{&id, &eventTime, &service, &action, &email, &params, &workspaceId}
+}
+
+func TestAuditFeedMetadataIsValid(t *testing.T) {
+	if err := newAuditFeed(nil, nil, "dbc-audit", "wh-1").EventFeedMetadata(context.Background()).Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestAuditFeedListEvents(t *testing.T) {
+	f := newAuditTestFeed(t, func(params map[string]string) [][]*string {
+		return [][]*string{
+			auditRow("e-1", "2024-05-01T10:00:00.000Z", "accounts", "addPrincipalToGroup", "admin@example.com", `{"targetGroupId":"g-1","targetUserId":"u-2"}`, "0"),
+			auditRow("e-2", "2024-05-01T10:01:00.000Z", "accounts", "removePrincipalFromGroup", "admin@example.com", `{"targetGroupId":"g-1","targetUserId":"u-2"}`, "0"),
+			auditRow("e-3", "2024-05-01T10:02:00.000Z", "accounts", "login", "alice@example.com", `{"user":"alice@example.com"}`, "123"),
+			auditRow("e-4", "2024-05-01T10:03:00.000Z", "permissions", "changeClusterAcl", "admin@example.com", `{}`, "123"),
+			auditRow("e-5", "2024-05-01T10:04:00.000Z", "accounts", "login", "bob@example.com", `{}`, "0"),
+		}
+	})
+
+	events, state, _, err := f.ListEvents(context.Background(), nil, &pagination.StreamToken{Size: 10})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if state.HasMore {
+		t.Error("expected no more events")
+	}
+
+	// bob isn't an account user, so his login is dropped.
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+
+	grant := events[0].GetCreateGrantEvent()
+	if grant == nil {
+		t.Fatalf("event e-1 isn't a grant: %v", events[0])
+	}
+	if got := grant.GetEntitlement().GetId(); got != "group:account/acc-1/group/g-1:member" {
+		t.Errorf("grant entitlement = %q", got)
+	}
+	if got := grant.GetPrincipal().GetId().GetResource(); got != "u-2" {
+		t.Errorf("grant principal = %q", got)
+	}
+	if events[1].GetCreateRevokeEvent() == nil {
+		t.Errorf("event e-2 isn't a revoke: %v", events[1])
+	}
+
+	usage := events[2].GetUsageEvent()
+	if usage == nil {
+		t.Fatalf("event e-3 isn't a usage event: %v", events[2])
+	}
+	if got := usage.GetActorResource().GetId().GetResource(); got != "u-1" {
+		t.Errorf("usage actor = %q", got)
+	}
+	if got := usage.GetTargetResource().GetId(); got.GetResourceType() != workspaceResourceType.Id || got.GetResource() != "dbc-abc" {
+		t.Errorf("usage target = %v", got)
+	}
+
+	change := events[3].GetResourceChangeEvent()
+	if change == nil || change.GetResourceId().GetResource() != "dbc-abc" {
+		t.Errorf("event e-4 isn't a change of workspace dbc-abc: %v", events[3])
+	}
+
+	var cursor auditCursor
+	if err := json.Unmarshal([]byte(state.Cursor), &cursor); err != nil {
+		t.Fatalf("parse cursor: %v", err)
+	}
+	if cursor.EventID != "e-5" || cursor.EventTime != "2024-05-01T10:04:00.000Z" {
+		t.Errorf("cursor = %+v, want the last row", cursor)
+	}
+}
+
+func TestAuditFeedPrincipalTypes(t *testing.T) {
+	f := newAuditTestFeed(t, func(params map[string]string) [][]*string {
+		return [][]*string{
+			auditRow("e-1", "2024-05-01T10:00:00Z", "accounts", "addPrincipalToGroup", "", `{"targetGroupId":"g-1","targetUserId":"sp-1"}`, "0"),
+			auditRow("e-2", "2024-05-01T10:01:00Z", "accounts", "removePrincipalFromGroup", "", `{"targetGroupId":"g-1","targetUserId":"g-2"}`, "0"),
+			auditRow("e-3", "2024-05-01T10:02:00Z", "accounts", "addPrincipalToGroup", "", `{"targetGroupId":"g-1","targetUserId":"gone"}`, "0"),
+			auditRow("e-4", "2024-05-01T10:03:00Z", "accounts", "setAdmin", "", `{"targetUserId":"u-2"}`, "0"),
+			auditRow("e-5", "2024-05-01T10:04:00Z", "accounts", "removeAdmin", "", `{"targetUserId":"sp-1"}`, "0"),
+		}
+	})
+
+	events, _, _, err := f.ListEvents(context.Background(), nil, &pagination.StreamToken{Size: 10})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+
+	// The principal of e-3 no longer exists, so its type is unknown.
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+
+	if got := events[0].GetCreateGrantEvent().GetPrincipal().GetId(); got.GetResourceType() != servicePrincipalResourceType.Id || got.GetResource() != "sp-1" {
+		t.Errorf("principal of e-1 = %v, want service principal sp-1", got)
+	}
+	if got := events[1].GetCreateRevokeEvent().GetPrincipal().GetId(); got.GetResourceType() != groupResourceType.Id || got.GetResource() != "account/acc-1/group/g-2" {
+		t.Errorf("principal of e-2 = %v, want group g-2", got)
+	}
+
+	grant := events[2].GetCreateGrantEvent()
+	if got := grant.GetEntitlement().GetId(); got != "role:account_admin:member" {
+		t.Errorf("entitlement of e-4 = %q, want the account admin role", got)
+	}
+	if got := grant.GetPrincipal().GetId(); got.GetResourceType() != userResourceType.Id || got.GetResource() != "u-2" {
+		t.Errorf("principal of e-4 = %v, want user u-2", got)
+	}
+	if got := events[3].GetCreateRevokeEvent().GetPrincipal().GetId(); got.GetResourceType() != servicePrincipalResourceType.Id {
+		t.Errorf("principal of e-5 = %v, want a service principal", got)
+	}
+}
+
+func TestAuditFeedWithTokenAuth(t *testing.T) {
+	rows := [][]*string{
+		auditRow("e-1", "2024-05-01T10:00:00Z", "accounts", "addPrincipalToGroup", "", `{"targetGroupId":"g-1","targetUserId":"u-1"}`, "123"),
+		auditRow("e-2", "2024-05-01T10:01:00Z", "accounts", "addPrincipalToGroup", "", `{"targetGroupId":"g-1","targetUserId":"u-1"}`, "999"),
+		auditRow("e-3", "2024-05-01T10:02:00Z", "accounts", "setAdmin", "", `{"targetUserId":"u-1"}`, "0"),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/2.0/sql/statements", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"statement_id": "st-1",
+			"status":       map[string]any{"state": "SUCCEEDED"},
+			"result":       map[string]any{"data_array": rows},
+		})
+	})
+	mux.HandleFunc("GET /api/2.0/accounts/acc-1/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("workspaces listed with workspace tokens")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message": "unauthorized"}`))
+	})
+	mux.HandleFunc("GET /api/2.0/preview/scim/v2/Users", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"totalResults": 1, "Resources": [{"id": "u-1", "userName": "alice@example.com"}]}`))
+	})
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	// The Azure deployment name embeds the ID the audit log records.
+	workspaces := []string{"dbc-audit", "adb-123.4"}
+	c, err := databricks.NewClient(
+		context.Background(), srv.Client(), "example.com", srv.Listener.Addr().String(), "acc-1", "",
+		databricks.NewTokenAuth(workspaces, []string{"token-audit", "token-123"}), nil,
+		databricks.WithWorkspaceURLs(map[string]string{"dbc-audit": srv.URL, "adb-123.4": srv.URL}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.UpdateAvailability(false, true)
+
+	f := newAuditFeed(c, workspaces, "dbc-audit", "wh-1")
+	events, _, _, err := f.ListEvents(context.Background(), nil, &pagination.StreamToken{Size: 10})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+
+	// Events of the account and of unknown workspaces refer to nothing synced.
+	if len(events) != 1 || events[0].GetId() != "e-1" {
+		t.Fatalf("events = %v, want only e-1", events)
+	}
+	if got := events[0].GetCreateGrantEvent().GetPrincipal().GetId().GetResource(); got != "u-1" {
+		t.Errorf("principal of e-1 = %q, want u-1", got)
+	}
+}
+
+func TestAuditFeedPagination(t *testing.T) {
+	var got []map[string]string
+	f := newAuditTestFeed(t, func(params map[string]string) [][]*string {
+		got = append(got, params)
+		return [][]*string{
+			auditRow("e-1", "2024-05-01T10:00:00Z", "accounts", "updateUser", "", `{"targetUserId":"u-1"}`, "0"),
+			auditRow("e-2", "2024-05-01T10:00:00Z", "accounts", "updateUser", "", `{"targetUserId":"u-2"}`, "0"),
+		}
+	})
+
+	earliest := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	events, state, _, err := f.ListEvents(context.Background(), timestamppb.New(earliest), &pagination.StreamToken{Size: 1})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || !state.HasMore {
+		t.Fatalf("got %d events with more %v, want 1 with more", len(events), state.HasMore)
+	}
+	if got[0]["since"] != "2024-05-01T00:00:00Z" || got[0]["after_id"] != "" {
+		t.Errorf("first query params = %v", got[0])
+	}
+
+	if _, _, _, err := f.ListEvents(context.Background(), timestamppb.New(earliest), &pagination.StreamToken{Size: 1, Cursor: state.Cursor}); err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if got[1]["since"] != "2024-05-01T10:00:00Z" || got[1]["after_id"] != "e-1" {
+		t.Errorf("second query params = %v, want to resume after e-1", got[1])
+	}
+}
+
+func TestEventFeedsRequireWarehouse(t *testing.T) {
+	if feeds := (&Databricks{}).EventFeeds(context.Background()); feeds != nil {
+		t.Errorf("expected no feeds, got %v", feeds)
+	}
+
+	d := &Databricks{auditFeed: newAuditFeed(nil, nil, "dbc-audit", "wh-1")}
+	if feeds := d.EventFeeds(context.Background()); len(feeds) != 1 {
+		t.Errorf("got %d feeds, want 1", len(feeds))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"slices"
 	"strings"
+	"sync"
 
 	"errors"
 
@@ -17,6 +18,7 @@ import (
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
@@ -26,6 +28,94 @@ const groupManagerEntitlement = "roles/group.manager"
 type groupBuilder struct {
 	client       *databricks.Client
 	resourceType *v2.ResourceType
+	// flattenNested emits direct grants for members of nested groups instead
+	// of relying on grant expansion.
+	flattenNested bool
+	// pageConcurrency is the number of SCIM pages List fetches at once.
+	pageConcurrency int
+	// incremental, when set, makes List re-fetch only what changed since the
+	// previous sync.
+	incremental *incrementalSync
+	// nestedMembers shares the members of nested groups between the groups
+	// of a sync when flattening.
+	nestedMembers *nestedGroupMembers
+}
+
+// nestedGroupMembers keeps the members of the groups visited while flattening
+// nested groups, so a group nested in many others is fetched once per sync.
+// Anything left is dropped when a new sync starts, and without a sync ID
+// nothing is kept.
+type nestedGroupMembers struct {
+	mu     sync.Mutex
+	syncID string
+	// members maps workspace and group IDs to the group's members, or to
+	// nil for groups that no longer exist.
+	members map[string][]databricks.Member
+	loads   singleflight.Group
+}
+
+func newNestedGroupMembers() *nestedGroupMembers {
+	return &nestedGroupMembers{members: make(map[string][]databricks.Member)}
+}
+
+func (n *nestedGroupMembers) cached(syncID, key string) ([]databricks.Member, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if syncID != n.syncID {
+		n.syncID = syncID
+		n.members = make(map[string][]databricks.Member)
+	}
+
+	members, ok := n.members[key]
+	return members, ok
+}
+
+func (n *nestedGroupMembers) store(syncID, key string, members []databricks.Member) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if syncID == n.syncID {
+		n.members[key] = members
+	}
+}
+
+// get returns the members of a group, or nil if it no longer exists, loading
+// them at most once per sync.
+func (n *nestedGroupMembers) get(
+	ctx context.Context,
+	syncID string,
+	workspaceId string,
+	groupId string,
+	load func(ctx context.Context) ([]databricks.Member, error),
+) ([]databricks.Member, error) {
+	if syncID == "" {
+		return load(ctx)
+	}
+
+	key := workspaceId + "/" + groupId
+	if members, ok := n.cached(syncID, key); ok {
+		return members, nil
+	}
+
+	v, err, _ := n.loads.Do(syncID+"/"+key, func() (interface{}, error) {
+		if members, ok := n.cached(syncID, key); ok {
+			return members, nil
+		}
+
+		members, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		n.store(syncID, key, members)
+		return members, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]databricks.Member), nil
 }
 
 func (g *groupBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -74,6 +164,8 @@ func groupResource(ctx context.Context, group *databricks.Group, parent *v2.Reso
 // List returns all the groups from the database as resource objects.
 // Groups include a GroupTrait because they are the 'shape' of a standard group.
 func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
+	g.client.SetSyncID(attr.SyncID)
+
 	if parentResourceID == nil {
 		return nil, nil, nil
 	}
@@ -88,12 +180,26 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 		return nil, nil, fmt.Errorf("databricks-connector: failed to parse page token: %w", err)
 	}
 
-	groups, total, _, err := g.client.ListGroups(
-		ctx,
-		workspaceId,
-		databricks.NewPaginationVars(page, ResourcesPageSize),
-		databricks.NewGroupAttrVars(),
+	list := func(ctx context.Context, start uint, vars ...databricks.Vars) ([]databricks.Group, uint, error) {
+		groups, total, _, err := g.client.ListGroups(
+			ctx,
+			workspaceId,
+			append([]databricks.Vars{databricks.NewPaginationVars(start, g.client.PageSize())}, vars...)...,
+		)
+		return groups, total, err
+	}
+
+	var (
+		groups []databricks.Group
+		token  string
 	)
+	if g.incremental != nil {
+		groups, token, err = listIncremental(ctx, g.incremental, attr.SyncID, incrementalKey(groupResourceType.Id, workspaceId), page, g.client.PageSize(), g.pageConcurrency, list, databricks.NewGroupAttrVars())
+	} else {
+		groups, token, err = listPages(ctx, page, g.pageConcurrency, func(ctx context.Context, start uint) ([]databricks.Group, uint, error) {
+			return list(ctx, start, databricks.NewGroupAttrVars())
+		})
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to list groups: %w", err)
 	}
@@ -110,7 +216,6 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 		rv = append(rv, gr)
 	}
 
-	token := prepareNextToken(page, len(groups), total)
 	nextPage, err := bag.NextToken(token)
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
@@ -119,6 +224,39 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 	return rv, &rs.SyncOpResults{NextPageToken: nextPage}, nil
 }
 
+// Get returns a single group, for targeted syncs. Group resource IDs carry
+// their parent, which takes precedence over the given one.
+func (g *groupBuilder) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	parent, groupId := parentResourceId, resourceId.Resource
+	if idParent, id, err := parseResourceId(resourceId.Resource); err == nil {
+		groupId = id.Resource
+		if idParent != nil {
+			parent = idParent
+		}
+	}
+
+	parent, workspaceId, err := principalParent(g.client, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	group, _, err := g.client.GetGroup(ctx, workspaceId, groupId, databricks.NewGroupAttrVars())
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("databricks-connector: failed to get group %s: %w", groupId, err)
+	}
+
+	rv, err := groupResource(ctx, group, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rv, nil, nil
+}
+
 // Entitlements return all entitlements relevant to the group.
 // Group can have members, which represent membership entitlements,
 // it can have permissions assigned to it, which represent role permissions entitlements,
@@ -173,7 +311,7 @@ func (g *groupBuilder) Entitlements(ctx context.Context, resource *v2.Resource,
 
 // Grants return all grants relevant to the group.
 // Databricks Groups have membership and role permissions grants (granting identity resource some permission to this specific group, e.g. group manager).
-func (g *groupBuilder) Grants(ctx context.Context, resource *v2.Resource, _ rs.SyncOpAttrs) ([]*v2.Grant, *rs.SyncOpResults, error) {
+func (g *groupBuilder) Grants(ctx context.Context, resource *v2.Resource, attr rs.SyncOpAttrs) ([]*v2.Grant, *rs.SyncOpResults, error) {
 	l := ctxzap.Extract(ctx)
 
 	var rv []*v2.Grant
@@ -204,35 +342,28 @@ func (g *groupBuilder) Grants(ctx context.Context, resource *v2.Resource, _ rs.S
 
 	l.Debug("grants: group resource", zap.String("group_id", groupId.Resource), zap.Int("member_count", len(group.Members)))
 	for _, member := range group.Members {
-		// member.Ref contains the type and ID separated by "/", e.g., "Users/123" or "Groups/456"
-		pp := strings.Split(member.Ref, "/")
-		if len(pp) != 2 {
-			return nil, nil, fmt.Errorf("databricks-connector: invalid member format of %s", member.Ref)
+		resourceId, expandable, err := memberResourceId(ctx, member, parentId)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		memberType, memberID := pp[0], pp[1]
-		var resourceId *v2.ResourceId
 		var anns []protoreflect.ProtoMessage
-
-		switch memberType {
-		case "Users":
-			resourceId = &v2.ResourceId{ResourceType: userResourceType.Id, Resource: memberID}
-		case "Groups":
-			rid, expandAnnotation, err := groupGrantExpansion(ctx, memberID, parentId)
-			if err != nil {
-				return rv, nil, err
-			}
-			resourceId = rid
-			anns = append(anns, expandAnnotation)
-		case "ServicePrincipals":
-			resourceId = &v2.ResourceId{ResourceType: servicePrincipalResourceType.Id, Resource: memberID}
-		default:
-			return nil, nil, fmt.Errorf("databricks-connector: invalid member type: %s", memberType)
+		if expandable != nil && !g.flattenNested {
+			anns = append(anns, expandable)
 		}
 
 		rv = append(rv, grant.NewGrant(resource, groupMemberEntitlement, resourceId, grant.WithAnnotation(anns...)))
 	}
 
+	if g.flattenNested {
+		nested, err := g.nestedMemberGrants(ctx, attr.SyncID, resource, workspaceId, parentId, group)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rv = append(rv, nested...)
+	}
+
 	// role permissions grants
 	ruleSets, rateLimitDataRuleSets, err := g.client.ListRuleSets(ctx, workspaceId, GroupsType, groupId.Resource)
 	if err != nil {
@@ -554,9 +685,136 @@ func (g *groupBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations
 	return nil, nil
 }
 
-func newGroupBuilder(client *databricks.Client) *groupBuilder {
+// memberResourceId returns the resource ID of a group member. member.Ref
+// contains the type and ID separated by "/", e.g., "Users/123" or "Groups/456";
+// nested groups share the parent of the group they belong to, and come with
+// the expansion of their membership.
+func memberResourceId(ctx context.Context, member databricks.Member, parentId *v2.ResourceId) (*v2.ResourceId, *v2.GrantExpandable, error) {
+	pp := strings.Split(member.Ref, "/")
+	if len(pp) != 2 {
+		return nil, nil, fmt.Errorf("databricks-connector: invalid member format of %s", member.Ref)
+	}
+
+	memberType, memberID := pp[0], pp[1]
+	switch memberType {
+	case "Users":
+		return &v2.ResourceId{ResourceType: userResourceType.Id, Resource: memberID}, nil, nil
+	case "Groups":
+		return groupGrantExpansion(ctx, memberID, parentId)
+	case "ServicePrincipals":
+		return &v2.ResourceId{ResourceType: servicePrincipalResourceType.Id, Resource: memberID}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("databricks-connector: invalid member type: %s", memberType)
+	}
+}
+
+// nestedMemberGrants walks the groups nested in group breadth-first and returns
+// a membership grant for every user and service principal found in them. Each
+// grant records the groups it was reached through; principals that are direct
+// members, or were already reached through a shorter path, are skipped.
+func (g *groupBuilder) nestedMemberGrants(
+	ctx context.Context,
+	syncID string,
+	resource *v2.Resource,
+	workspaceId string,
+	parentId *v2.ResourceId,
+	group *databricks.Group,
+) ([]*v2.Grant, error) {
+	l := ctxzap.Extract(ctx)
+
+	type nestedGroup struct {
+		id   string
+		path []string
+	}
+
+	var rv []*v2.Grant
+	seen := map[string]struct{}{}
+	visited := map[string]struct{}{group.ID: {}}
+	var queue []nestedGroup
+
+	for _, member := range group.Members {
+		resourceId, expandable, err := memberResourceId(ctx, member, parentId)
+		if err != nil {
+			return nil, err
+		}
+
+		if expandable == nil {
+			seen[resourceId.String()] = struct{}{}
+			continue
+		}
+
+		if _, ok := visited[member.ID]; !ok {
+			visited[member.ID] = struct{}{}
+			queue = append(queue, nestedGroup{id: member.ID, path: []string{resourceId.Resource}})
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		members, err := g.nestedMembers.get(ctx, syncID, workspaceId, current.id, func(ctx context.Context) ([]databricks.Member, error) {
+			nested, _, err := g.client.GetGroup(ctx, workspaceId, current.id, databricks.NewGroupMembersAttrVars())
+			if err != nil {
+				var apiErr *databricks.APIError
+				if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+					l.Warn("databricks-connector: skipping nested group that no longer exists", zap.String("group_id", current.id))
+					return nil, nil
+				}
+
+				return nil, fmt.Errorf("databricks-connector: failed to get nested group %s: %w", current.id, err)
+			}
+
+			return nested.Members, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range members {
+			resourceId, expandable, err := memberResourceId(ctx, member, parentId)
+			if err != nil {
+				return nil, err
+			}
+
+			if expandable != nil {
+				if _, ok := visited[member.ID]; !ok {
+					visited[member.ID] = struct{}{}
+					queue = append(queue, nestedGroup{id: member.ID, path: append(slices.Clone(current.path), resourceId.Resource)})
+				}
+
+				continue
+			}
+
+			if _, ok := seen[resourceId.String()]; ok {
+				continue
+			}
+			seen[resourceId.String()] = struct{}{}
+
+			path := make([]interface{}, 0, len(current.path))
+			for _, p := range current.path {
+				path = append(path, p)
+			}
+
+			rv = append(rv, grant.NewGrant(
+				resource,
+				groupMemberEntitlement,
+				resourceId,
+				grant.WithGrantMetadata(map[string]interface{}{"nested_group_path": path}),
+			))
+		}
+	}
+
+	return rv, nil
+}
+
+func newGroupBuilder(client *databricks.Client, flattenNested bool, pageConcurrency int, incremental *incrementalSync) *groupBuilder {
 	return &groupBuilder{
-		client:       client,
-		resourceType: groupResourceType,
+		client:          client,
+		resourceType:    groupResourceType,
+		flattenNested:   flattenNested,
+		pageConcurrency: pageConcurrency,
+		incremental:     incremental,
+		nestedMembers:   newNestedGroupMembers(),
 	}
 }
@@ -0,0 +1,166 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+// newGroupsTestClient serves account SCIM groups from the given fixtures, and
+// no rule sets. fetched, if set, is called with the ID of every group served.
+func newGroupsTestClient(t *testing.T, groups map[string][]databricks.Member, fetched func(id string)) *databricks.Client {
+	t.Helper()
+
+	return newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := path.Base(r.URL.Path)
+		if id == "rule-sets" {
+			_, _ = w.Write([]byte(`{"grant_rules": []}`))
+			return
+		}
+		if fetched != nil {
+			fetched(id)
+		}
+
+		members, ok := groups[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"detail": "group not found"}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(databricks.Group{
+			BaseResponse: databricks.BaseResponse{ID: id},
+			DisplayName:  id,
+			Members:      members,
+		})
+	}))
+}
+
+func TestGroupGrantsFlattenNestedGroups(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	client := newGroupsTestClient(t, map[string][]databricks.Member{
+		"a": {{ID: "u1", Ref: "Users/u1"}, {ID: "b", Ref: "Groups/b"}},
+		"b": {{ID: "u2", Ref: "Users/u2"}, {ID: "c", Ref: "Groups/c"}, {ID: "u1", Ref: "Users/u1"}, {ID: "gone", Ref: "Groups/gone"}},
+		"c": {{ID: "sp1", Ref: "ServicePrincipals/sp1"}, {ID: "a", Ref: "Groups/a"}, {ID: "u2", Ref: "Users/u2"}},
+		"d": {{ID: "b", Ref: "Groups/b"}},
+	}, func(id string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches[id]++
+	})
+	client.UpdateAvailability(true, false)
+
+	account := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+	b := newGroupBuilder(client, true, 1, nil)
+	attr := rs.SyncOpAttrs{SyncID: "sync-1"}
+
+	// nestedGrants returns the grants of a group that came through nested
+	// groups, which carry the path they were reached through.
+	nestedGrants := func(id string) []*v2.Grant {
+		resource, err := groupResource(ctx, &databricks.Group{BaseResponse: databricks.BaseResponse{ID: id}, DisplayName: id}, account)
+		if err != nil {
+			t.Fatalf("groupResource: %v", err)
+		}
+
+		grants, _, err := b.Grants(ctx, resource, attr)
+		if err != nil {
+			t.Fatalf("Grants: %v", err)
+		}
+
+		var rv []*v2.Grant
+		for _, g := range grants {
+			annos := annotations.Annotations(g.GetAnnotations())
+			if annos.Contains(&v2.GrantMetadata{}) {
+				rv = append(rv, g)
+			}
+		}
+
+		return rv
+	}
+
+	// u1 is a direct member, u2 is reached through b before c, the cycle back
+	// to a and the missing group are skipped.
+	grants := nestedGrants("a")
+
+	// b is nested in d too, and its members were already fetched in the sync.
+	nestedGrants("d")
+	if fetches["b"] != 1 || fetches["c"] != 1 || fetches["gone"] != 1 {
+		t.Errorf("group fetches = %v, want each nested group once", fetches)
+	}
+
+	groupB := groupResourceId(ctx, "b", account)
+	groupC := groupResourceId(ctx, "c", account)
+	want := map[string][]string{
+		"user:u2":               {groupB},
+		"service_principal:sp1": {groupB, groupC},
+	}
+
+	if len(grants) != len(want) {
+		t.Fatalf("got %d grants, want %d", len(grants), len(want))
+	}
+
+	for _, g := range grants {
+		key := g.Principal.Id.ResourceType + ":" + g.Principal.Id.Resource
+		wantPath, ok := want[key]
+		if !ok {
+			t.Errorf("unexpected grant for %s", key)
+			continue
+		}
+
+		annos := annotations.Annotations(g.GetAnnotations())
+		md := &v2.GrantMetadata{}
+		if ok, err := annos.Pick(md); err != nil || !ok {
+			t.Fatalf("grant for %s has no metadata", key)
+		}
+
+		var gotPath []string
+		for _, v := range md.GetMetadata().GetFields()["nested_group_path"].GetListValue().GetValues() {
+			gotPath = append(gotPath, v.GetStringValue())
+		}
+
+		if len(gotPath) != len(wantPath) {
+			t.Errorf("path for %s = %v, want %v", key, gotPath, wantPath)
+			continue
+		}
+		for i := range gotPath {
+			if gotPath[i] != wantPath[i] {
+				t.Errorf("path for %s = %v, want %v", key, gotPath, wantPath)
+			}
+		}
+	}
+}
+
+func TestGroupGet(t *testing.T) {
+	ctx := context.Background()
+	client := newGroupsTestClient(t, map[string][]databricks.Member{"a": nil}, nil)
+
+	var _ connectorbuilder.ResourceTargetedSyncerLimited = newGroupBuilder(client, false, 1, nil)
+
+	account := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+	id := &v2.ResourceId{ResourceType: groupResourceType.Id, Resource: groupResourceId(ctx, "a", account)}
+
+	resource, _, err := newGroupBuilder(client, false, 1, nil).Get(ctx, id, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if resource.GetId().GetResource() != id.GetResource() || resource.GetParentResourceId().GetResource() != "acc-1" {
+		t.Fatalf("unexpected resource: %v", resource)
+	}
+
+	id.Resource = groupResourceId(ctx, "gone", account)
+	resource, _, err = newGroupBuilder(client, false, 1, nil).Get(ctx, id, nil)
+	if err != nil || resource != nil {
+		t.Fatalf("expected no resource for a missing group, got %v, %v", resource, err)
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/conductorone/baton-databricks/pkg/databricks"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -124,10 +126,13 @@ func prepareResourceType(principal *databricks.WorkspacePrincipal) (*v2.Resource
 }
 
 // preparePrincipalId prepares a principal ID for a user, group, or service principal.
-// It's used when we need edit rule sets with new principals.
+// It's used when we need edit rule sets with new principals, so it looks the
+// principal up afresh rather than trusting names cached during the sync.
 func preparePrincipalId(ctx context.Context, c *databricks.Client, workspaceId, principalType, principalId string) (string, error) {
 	var result string
 
+	ctx = databricks.WithFreshLookups(ctx)
+
 	switch principalType {
 	case userResourceType.Id:
 		username, _, err := c.FindUsername(ctx, workspaceId, principalId)
@@ -157,6 +162,44 @@ func preparePrincipalId(ctx context.Context, c *databricks.Client, workspaceId,
 	return result, nil
 }
 
+// isNotFoundError reports whether the API answered with a 404.
+func isNotFoundError(err error) bool {
+	var apiErr *databricks.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// isAccessDeniedError reports whether the credentials aren't allowed to make a
+// request.
+func isAccessDeniedError(err error) bool {
+	var apiErr *databricks.APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// principalParent returns the parent of a user, group or service principal
+// fetched on its own, and the workspace to query it in ("" for the account).
+// Without a parent, principals belong to the account, which requires the
+// Account API.
+func principalParent(c *databricks.Client, parent *v2.ResourceId) (*v2.ResourceId, string, error) {
+	if parent == nil {
+		if !c.IsAccountAPIAvailable() {
+			return nil, "", fmt.Errorf("databricks-connector: a parent workspace is required without the account API")
+		}
+
+		accountId, err := rs.NewResourceID(accountResourceType, c.GetAccountId())
+		if err != nil {
+			return nil, "", err
+		}
+
+		return accountId, "", nil
+	}
+
+	if parent.ResourceType == workspaceResourceType.Id {
+		return parent, parent.Resource, nil
+	}
+
+	return parent, "", nil
+}
+
 // isGroupNotFoundError matches the rule-sets/roles API's response for a group ID
 // it doesn't recognize (e.g. an orphaned or stale workspace SCIM group), distinct
 // from other 400s.
@@ -191,41 +234,198 @@ func getParentInfoFromProfile(profile *structpb.Struct) (string, string, error)
 	return parentType, parentID, nil
 }
 
+// permissionSources returns where a principal's role (account) or entitlement
+// (workspace) comes from.
+func permissionSources(perms databricks.Permissions, isWorkspaceRole bool, name string) databricks.PermissionSources {
+	if isWorkspaceRole {
+		return perms.EntitlementSources(name)
+	}
+
+	return perms.RoleSources(name)
+}
+
+// grantSourceOptions returns the grant options recording that a permission is
+// inherited: metadata naming the source groups and, when the principal has no
+// direct assignment, a GrantImmutable annotation pointing at the first source group.
+func grantSourceOptions(ctx context.Context, sources databricks.PermissionSources, groupParent *v2.ResourceId) []grant.GrantOption {
+	source := "group"
+	if sources.Direct {
+		source = "direct_and_group"
+	}
+
+	groupIds := make([]string, 0, len(sources.Groups))
+	groups := make([]interface{}, 0, len(sources.Groups))
+	for _, g := range sources.Groups {
+		id := groupResourceId(ctx, g, groupParent)
+		groupIds = append(groupIds, id)
+		groups = append(groups, id)
+	}
+
+	opts := []grant.GrantOption{
+		grant.WithGrantMetadata(map[string]interface{}{
+			"source":        source,
+			"source_groups": groups,
+		}),
+	}
+
+	if !sources.Direct {
+		immutable := &v2.GrantImmutable{}
+		if len(groupIds) > 0 {
+			immutable.SourceId = groupIds[0]
+		}
+
+		opts = append(opts, grant.WithAnnotation(immutable))
+	}
+
+	return opts
+}
+
+// addPermissions adds the role (account) or entitlement (workspace) to the
+// principal's permissions, unless it's already there.
 func addPermissions(isWorkspaceRole bool, perms *databricks.Permissions, entitlement string) {
-	if !isWorkspaceRole {
-		perms.Roles = append(perms.Roles, databricks.PermissionValue{
-			Value: entitlement,
-		})
-	} else {
-		perms.Entitlements = append(perms.Entitlements, databricks.PermissionValue{
-			Value: entitlement,
-		})
+	values := &perms.Roles
+	if isWorkspaceRole {
+		values = &perms.Entitlements
+	}
+
+	if slices.ContainsFunc(*values, func(v databricks.PermissionValue) bool { return v.Value == entitlement }) {
+		return
 	}
+
+	*values = append(*values, databricks.PermissionValue{
+		Value: entitlement,
+	})
 }
 
+// removePermissions removes every occurrence of the role (account) or
+// entitlement (workspace) from the principal's permissions.
 func removePermissions(isWorkspaceRole bool, perms *databricks.Permissions, entitlement string) {
-	if !isWorkspaceRole {
-		for i, r := range perms.Roles {
-			if r.Value == entitlement {
-				perms.Roles = slices.Delete(perms.Roles, i, i+1)
-				break
+	values := &perms.Roles
+	if isWorkspaceRole {
+		values = &perms.Entitlements
+	}
+
+	*values = slices.DeleteFunc(*values, func(v databricks.PermissionValue) bool { return v.Value == entitlement })
+}
+
+// ruleSetRole returns the rule-set role ("roles/<name>") that grants the given
+// account role.
+func ruleSetRole(role string) string {
+	return ruleSetRolePrefix + role
+}
+
+// ruleSetGrants returns a grant of the given entitlement for every principal
+// bound to role in the account rule sets. Groups are always account-parented,
+// since account rule sets are only readable through the account API.
+func ruleSetGrants(
+	ctx context.Context,
+	c *databricks.Client,
+	resource *v2.Resource,
+	entitlement string,
+	role string,
+	ruleSets []databricks.RuleSet,
+) ([]*v2.Grant, error) {
+	var rv []*v2.Grant
+
+	for _, ruleSet := range ruleSets {
+		if ruleSet.Role != role {
+			continue
+		}
+
+		// rule set contains role and its principals, each one with resource type and resource id seperated by "/"
+		for _, p := range ruleSet.Principals {
+			resourceId, err := prepareResourceId(ctx, c, "", p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare resource id for principal %s: %w", p, err)
+			}
+
+			var annos []protoreflect.ProtoMessage
+			if resourceId.ResourceType == groupResourceType.Id {
+				groupParentResourceId, err := rs.NewResourceID(accountResourceType, c.GetAccountId())
+				if err != nil {
+					return nil, err
+				}
+
+				rid, expandAnnotation, err := groupGrantExpansion(ctx, resourceId.Resource, groupParentResourceId)
+				if err != nil {
+					return nil, err
+				}
+
+				resourceId = rid
+				annos = append(annos, expandAnnotation)
 			}
+
+			rv = append(rv, grant.NewGrant(resource, entitlement, resourceId, grant.WithAnnotation(annos...)))
 		}
-	} else {
-		for i, e := range perms.Entitlements {
-			if e.Value == entitlement {
-				perms.Entitlements = slices.Delete(perms.Entitlements, i, i+1)
-				break
+	}
+
+	return rv, nil
+}
+
+// addRuleSetPrincipal binds principalID to role, creating the grant rule if
+// the rule sets don't have one yet. It reports false if the principal was
+// already bound to the role.
+func addRuleSetPrincipal(ruleSets []databricks.RuleSet, role, principalID string) ([]databricks.RuleSet, bool) {
+	for i, ruleSet := range ruleSets {
+		if ruleSet.Role != role {
+			continue
+		}
+
+		if slices.Contains(ruleSet.Principals, principalID) {
+			return ruleSets, false
+		}
+
+		ruleSets[i].Principals = append(ruleSets[i].Principals, principalID)
+		return ruleSets, true
+	}
+
+	return append(ruleSets, databricks.RuleSet{
+		Role:       role,
+		Principals: []string{principalID},
+	}), true
+}
+
+// removeRuleSetPrincipal unbinds principalID from role, dropping grant rules
+// left without principals. It reports false if the principal wasn't bound to
+// the role.
+func removeRuleSetPrincipal(ruleSets []databricks.RuleSet, role, principalID string) ([]databricks.RuleSet, bool) {
+	removed := false
+	rv := make([]databricks.RuleSet, 0, len(ruleSets))
+
+	for _, ruleSet := range ruleSets {
+		if ruleSet.Role == role && slices.Contains(ruleSet.Principals, principalID) {
+			removed = true
+			ruleSet.Principals = slices.DeleteFunc(slices.Clone(ruleSet.Principals), func(p string) bool {
+				return p == principalID
+			})
+
+			if len(ruleSet.Principals) == 0 {
+				continue
 			}
 		}
+
+		rv = append(rv, ruleSet)
 	}
+
+	return rv, removed
 }
 
+// prepareWorkspaceRole returns the entitlement part of a workspace role ID ("<workspace>:<entitlement>").
 func prepareWorkspaceRole(entitlement string) string {
-	parts := strings.Split(entitlement, ":")
+	parts := strings.SplitN(entitlement, ":", 2)
 	if len(parts) != 2 {
 		return ""
 	}
 
 	return parts[1]
 }
+
+// workspaceRoleName returns the entitlement a workspace role resource represents,
+// preferring the role_name kept in its profile over parsing the resource ID.
+func workspaceRoleName(resource *v2.Resource) string {
+	if name, ok := rs.GetProfileStringValue(rs.GetProfile(resource), "role_name"); ok && name != "" {
+		return name
+	}
+
+	return prepareWorkspaceRole(resource.Id.Resource)
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"reflect"
 	"testing"
 
 	"github.com/conductorone/baton-databricks/pkg/databricks"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 )
 
 // CXH-2166 regression: under token auth (no account API), groups sync parented
@@ -97,3 +100,157 @@ func TestIsGroupNotFoundError(t *testing.T) {
 		})
 	}
 }
+
+func TestAddRuleSetPrincipal(t *testing.T) {
+	t.Run("appends to existing grant rule", func(t *testing.T) {
+		ruleSets := []databricks.RuleSet{{Role: "roles/billing.admin", Principals: []string{"users/a@example.com"}}}
+
+		got, changed := addRuleSetPrincipal(ruleSets, "roles/billing.admin", "groups/admins")
+		if !changed {
+			t.Fatal("expected rule sets to change")
+		}
+		if !reflect.DeepEqual(got, []databricks.RuleSet{{Role: "roles/billing.admin", Principals: []string{"users/a@example.com", "groups/admins"}}}) {
+			t.Errorf("unexpected rule sets: %+v", got)
+		}
+	})
+
+	t.Run("creates missing grant rule", func(t *testing.T) {
+		ruleSets := []databricks.RuleSet{{Role: "roles/marketplace.admin", Principals: []string{"users/a@example.com"}}}
+
+		got, changed := addRuleSetPrincipal(ruleSets, "roles/marketplace.admin.viewer", "users/b@example.com")
+		if !changed {
+			t.Fatal("expected rule sets to change")
+		}
+		if len(got) != 2 || got[1].Role != "roles/marketplace.admin.viewer" {
+			t.Errorf("expected a new exact-match grant rule, got %+v", got)
+		}
+	})
+
+	t.Run("already bound", func(t *testing.T) {
+		ruleSets := []databricks.RuleSet{{Role: "roles/billing.admin", Principals: []string{"users/a@example.com"}}}
+
+		if _, changed := addRuleSetPrincipal(ruleSets, "roles/billing.admin", "users/a@example.com"); changed {
+			t.Error("expected no change for an existing binding")
+		}
+	})
+}
+
+func TestRemoveRuleSetPrincipal(t *testing.T) {
+	ruleSets := []databricks.RuleSet{
+		{Role: "roles/billing.admin", Principals: []string{"users/a@example.com", "users/b@example.com"}},
+		{Role: "roles/marketplace.admin", Principals: []string{"users/a@example.com"}},
+	}
+
+	got, changed := removeRuleSetPrincipal(ruleSets, "roles/marketplace.admin", "users/a@example.com")
+	if !changed {
+		t.Fatal("expected rule sets to change")
+	}
+	if !reflect.DeepEqual(got, ruleSets[:1]) {
+		t.Errorf("expected the emptied grant rule to be dropped, got %+v", got)
+	}
+
+	got, changed = removeRuleSetPrincipal(ruleSets, "roles/billing.admin", "users/a@example.com")
+	if !changed {
+		t.Fatal("expected rule sets to change")
+	}
+	if !reflect.DeepEqual(got[0].Principals, []string{"users/b@example.com"}) {
+		t.Errorf("unexpected principals: %v", got[0].Principals)
+	}
+	if !reflect.DeepEqual(ruleSets[0].Principals, []string{"users/a@example.com", "users/b@example.com"}) {
+		t.Errorf("input rule sets were mutated: %v", ruleSets[0].Principals)
+	}
+
+	if _, changed := removeRuleSetPrincipal(ruleSets, "roles/billing.admin", "users/c@example.com"); changed {
+		t.Error("expected no change for a missing binding")
+	}
+}
+
+func TestAddPermissions(t *testing.T) {
+	perms := databricks.Permissions{
+		Entitlements: []databricks.PermissionValue{{Value: WorkspaceAccessRole}},
+	}
+
+	addPermissions(true, &perms, WorkspaceConsumeRole)
+	addPermissions(true, &perms, WorkspaceConsumeRole)
+	addPermissions(false, &perms, AccountAdminRole)
+
+	wantEntitlements := []databricks.PermissionValue{{Value: WorkspaceAccessRole}, {Value: WorkspaceConsumeRole}}
+	if !reflect.DeepEqual(perms.Entitlements, wantEntitlements) {
+		t.Errorf("entitlements = %v, want %v", perms.Entitlements, wantEntitlements)
+	}
+	if !reflect.DeepEqual(perms.Roles, []databricks.PermissionValue{{Value: AccountAdminRole}}) {
+		t.Errorf("roles = %v, want only %s", perms.Roles, AccountAdminRole)
+	}
+}
+
+func TestRemovePermissions(t *testing.T) {
+	perms := databricks.Permissions{
+		Entitlements: []databricks.PermissionValue{
+			{Value: "some-future-entitlement"},
+			{Value: WorkspaceAccessRole},
+			{Value: "some-future-entitlement"},
+		},
+	}
+
+	removePermissions(true, &perms, "some-future-entitlement")
+
+	if !reflect.DeepEqual(perms.Entitlements, []databricks.PermissionValue{{Value: WorkspaceAccessRole}}) {
+		t.Errorf("entitlements = %v, want only %s", perms.Entitlements, WorkspaceAccessRole)
+	}
+}
+
+func TestPrepareWorkspaceRole(t *testing.T) {
+	tests := map[string]string{
+		"dbc-abc:workspace-consume": "workspace-consume",
+		"dbc-abc:custom:value":      "custom:value",
+		"workspace-access":          "",
+	}
+
+	for in, want := range tests {
+		if got := prepareWorkspaceRole(in); got != want {
+			t.Errorf("prepareWorkspaceRole(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGrantSourceOptions(t *testing.T) {
+	ctx := context.Background()
+	parent := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+	role := &v2.Resource{Id: &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: "dbc-abc:databricks-sql-access"}}
+	principal := &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "user-1"}
+
+	t.Run("inherited only is immutable", func(t *testing.T) {
+		opts := grantSourceOptions(ctx, databricks.PermissionSources{Inherited: true, Groups: []string{"100"}}, parent)
+		g := grant.NewGrant(role, RoleMemberEntitlement, principal, opts...)
+		annos := annotations.Annotations(g.GetAnnotations())
+
+		immutable := &v2.GrantImmutable{}
+		ok, err := annos.Pick(immutable)
+		if err != nil || !ok {
+			t.Fatalf("expected GrantImmutable annotation, ok=%v err=%v", ok, err)
+		}
+		if want := groupResourceId(ctx, "100", parent); immutable.SourceId != want {
+			t.Errorf("source id = %q, want %q", immutable.SourceId, want)
+		}
+
+		md := &v2.GrantMetadata{}
+		if ok, _ := annos.Pick(md); !ok || md.GetMetadata().GetFields()["source"].GetStringValue() != "group" {
+			t.Errorf("expected group source metadata, got %v", md.GetMetadata())
+		}
+	})
+
+	t.Run("direct and inherited stays revocable", func(t *testing.T) {
+		opts := grantSourceOptions(ctx, databricks.PermissionSources{Direct: true, Inherited: true, Groups: []string{"100"}}, parent)
+		g := grant.NewGrant(role, RoleMemberEntitlement, principal, opts...)
+		annos := annotations.Annotations(g.GetAnnotations())
+
+		if annos.Contains(&v2.GrantImmutable{}) {
+			t.Error("grant with a direct assignment must not be immutable")
+		}
+
+		md := &v2.GrantMetadata{}
+		if ok, _ := annos.Pick(md); !ok || md.GetMetadata().GetFields()["source"].GetStringValue() != "direct_and_group" {
+			t.Errorf("expected direct_and_group source metadata, got %v", md.GetMetadata())
+		}
+	})
+}
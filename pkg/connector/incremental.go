@@ -0,0 +1,446 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// incrementalSync lets the user, group and service principal syncers re-fetch
+// only what changed since the previous sync. A sync still has to return every
+// resource, so each listing keeps the objects it returned, along with the
+// latest meta.lastModified seen as its watermark. The next sync lists the
+// objects modified since the watermark, applies them to the kept objects and
+// checks the result against the listing's total count: a shortfall means
+// objects were deleted, and the listing is fetched in full instead.
+//
+// What a listing kept is held in memory, so long-running connectors sync
+// incrementally without any storage, and also written to one file per listing
+// in dir, when set, so that it outlives the process.
+//
+// Listings fall back to a full fetch when they have no state, when their last
+// full fetch is older than fullInterval, when the endpoint doesn't return
+// lastModified or can't filter on it, and when the count doesn't add up.
+type incrementalSync struct {
+	dir          string
+	fullInterval time.Duration
+	now          func() time.Time
+
+	mu     sync.Mutex
+	states map[string]*listingState
+	// syncID and plans hold the listings of the current sync, so that they
+	// are computed once and then returned a page at a time.
+	syncID string
+	plans  map[string]*listingPlan
+}
+
+func newIncrementalSync(dir string, fullInterval time.Duration) *incrementalSync {
+	return &incrementalSync{
+		dir:          dir,
+		fullInterval: fullInterval,
+		now:          time.Now,
+		states:       make(map[string]*listingState),
+		plans:        make(map[string]*listingPlan),
+	}
+}
+
+// listingState is the state of one listing, e.g. the users of a workspace.
+type listingState struct {
+	FullSyncAt time.Time       `json:"full_sync_at"`
+	Watermark  string          `json:"watermark"`
+	Items      json.RawMessage `json:"items"`
+}
+
+// listingPlan is how a listing is returned during a sync. A full listing is
+// fetched a page at a time and collected, to become the next state if every
+// page was seen in order. An incremental listing is computed up front and
+// then returned a page at a time.
+type listingPlan struct {
+	full       bool
+	fullSyncAt time.Time
+	watermark  string
+	// items are the objects of an incremental listing, or those collected
+	// so far by a full one, as a []T.
+	items any
+	// next is the start of the page a full listing expects next, or 0 once
+	// a page was missed and it can't become the next state.
+	next uint
+}
+
+// scimObject is a SCIM resource with the ID and metadata incremental syncs
+// track.
+type scimObject interface {
+	GetID() string
+	GetMeta() databricks.Meta
+}
+
+// listFunc fetches the page of a SCIM listing starting at start, with vars
+// selecting attributes or filtering.
+type listFunc[T any] func(ctx context.Context, start uint, vars ...databricks.Vars) ([]T, uint, error)
+
+func incrementalKey(resourceTypeId, workspaceId string) string {
+	if workspaceId == "" {
+		return resourceTypeId
+	}
+
+	return resourceTypeId + "/" + workspaceId
+}
+
+// statePath is the file holding the state of a listing.
+func (s *incrementalSync) statePath(key string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(key, "/", "_")+".json")
+}
+
+// load returns the state of a listing, reading it from its file the first
+// time. A missing file is no state.
+func (s *incrementalSync) load(key string) (*listingState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.states[key]; ok || s.dir == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(s.statePath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		s.states[key] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to read incremental sync state: %w", err)
+	}
+
+	var state listingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to parse incremental sync state: %w", err)
+	}
+
+	s.states[key] = &state
+	return &state, nil
+}
+
+// save replaces the state of a listing. Its file is rewritten through a
+// temporary file so a failed write doesn't lose the previous state; a write
+// that fails anyway is logged, since the state in memory still serves the
+// syncs of this process.
+func (s *incrementalSync) save(ctx context.Context, key string, state *listingState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[key] = state
+	if s.dir == "" {
+		return
+	}
+
+	if err := writeState(s.statePath(key), state); err != nil {
+		ctxzap.Extract(ctx).Warn(
+			"databricks-connector: failed to write incremental sync state, keeping it in memory only",
+			zap.String("listing", key),
+			zap.Error(err),
+		)
+	}
+}
+
+func writeState(path string, state *listingState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// plan returns the plan of a listing in the current sync, if it has one.
+// Plans of earlier syncs are dropped. Without a sync ID nothing is kept.
+func (s *incrementalSync) plan(syncID, key string) (*listingPlan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if syncID != s.syncID {
+		s.syncID = syncID
+		s.plans = make(map[string]*listingPlan)
+	}
+
+	p, ok := s.plans[key]
+	return p, ok
+}
+
+func (s *incrementalSync) setPlan(syncID, key string, p *listingPlan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if syncID != "" && syncID == s.syncID {
+		s.plans[key] = p
+	}
+}
+
+func (s *incrementalSync) dropPlan(syncID, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if syncID == s.syncID {
+		delete(s.plans, key)
+	}
+}
+
+// listIncremental returns the page of a listing starting at start, and the
+// token of the next page, fetching only the objects changed since the previous
+// sync when its state allows. attrs selects the attributes of the returned
+// objects and must include meta. Pages of an incremental listing hold
+// pageSize*concurrency objects, as many as a full listing fetches at once.
+func listIncremental[T scimObject](
+	ctx context.Context,
+	s *incrementalSync,
+	syncID string,
+	key string,
+	start uint,
+	pageSize uint,
+	concurrency int,
+	list listFunc[T],
+	attrs databricks.Vars,
+) ([]T, string, error) {
+	p, ok := s.plan(syncID, key)
+	if !ok {
+		var err error
+		p, err = planListing(ctx, s, key, start, concurrency, list, attrs)
+		if err != nil {
+			return nil, "", err
+		}
+		s.setPlan(syncID, key, p)
+	}
+
+	if p.full {
+		items, token, err := listPages(ctx, start, concurrency, func(ctx context.Context, start uint) ([]T, uint, error) {
+			return list(ctx, start, attrs)
+		})
+		if err != nil {
+			s.dropPlan(syncID, key)
+			return nil, "", err
+		}
+
+		collected, _ := p.items.([]T)
+		if p.next == start {
+			p.items = append(collected, items...)
+			p.next = start + uint(len(items))
+		} else {
+			p.items, p.next = nil, 0
+		}
+
+		if token == "" {
+			if p.next != 0 {
+				commitListing(ctx, s, key, p.fullSyncAt, "", p.items.([]T))
+			}
+			s.dropPlan(syncID, key)
+		}
+
+		return items, token, nil
+	}
+
+	items := p.items.([]T)
+	first := min(int(start)-1, len(items))                           // #nosec G115 -- page numbers come from our own tokens.
+	last := min(first+int(pageSize)*max(concurrency, 1), len(items)) // #nosec G115 -- the page size is at most config.MaxPageSize.
+	token := prepareNextToken(start, last-first, uint(len(items)))
+	if token == "" {
+		commitListing(ctx, s, key, p.fullSyncAt, p.watermark, items)
+		s.dropPlan(syncID, key)
+	}
+
+	return items[first:last], token, nil
+}
+
+// planListing decides how a listing is returned in this sync, computing an
+// incremental listing up front.
+func planListing[T scimObject](
+	ctx context.Context,
+	s *incrementalSync,
+	key string,
+	start uint,
+	concurrency int,
+	list listFunc[T],
+	attrs databricks.Vars,
+) (*listingPlan, error) {
+	l := ctxzap.Extract(ctx).With(zap.String("listing", key))
+	now := s.now()
+
+	// A full listing can only become the next state when it's collected
+	// from its first page.
+	full := &listingPlan{full: true, fullSyncAt: now}
+	if start == 1 {
+		full.next = 1
+	}
+
+	state, err := s.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case state == nil:
+		l.Debug("databricks-connector: no incremental sync state, fetching everything")
+		return full, nil
+	case now.Sub(state.FullSyncAt) >= s.fullInterval:
+		l.Debug("databricks-connector: incremental sync state is due for a full fetch", zap.Time("full_sync_at", state.FullSyncAt))
+		return full, nil
+	case state.Watermark == "":
+		l.Debug("databricks-connector: endpoint doesn't return lastModified, fetching everything")
+		return full, nil
+	}
+
+	items, ok, err := refreshListing(ctx, state, concurrency, list, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return full, nil
+	}
+
+	return &listingPlan{fullSyncAt: state.FullSyncAt, watermark: state.Watermark, items: items}, nil
+}
+
+// commitListing makes a listing returned in full the state of the next sync.
+func commitListing[T scimObject](ctx context.Context, s *incrementalSync, key string, fullSyncAt time.Time, watermark string, items []T) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("databricks-connector: failed to encode incremental sync state", zap.String("listing", key), zap.Error(err))
+		return
+	}
+
+	s.save(ctx, key, &listingState{
+		FullSyncAt: fullSyncAt,
+		Watermark:  latestModified(items, watermark),
+		Items:      data,
+	})
+}
+
+// refreshListing applies the changes since the state's watermark to its
+// objects. It returns false when the result can't be trusted and the listing
+// has to be fetched in full.
+func refreshListing[T scimObject](
+	ctx context.Context,
+	state *listingState,
+	concurrency int,
+	list listFunc[T],
+	attrs databricks.Vars,
+) ([]T, bool, error) {
+	l := ctxzap.Extract(ctx)
+
+	var previous []T
+	if err := json.Unmarshal(state.Items, &previous); err != nil {
+		l.Warn("databricks-connector: discarding unreadable incremental sync state", zap.Error(err))
+		return nil, false, nil
+	}
+
+	// The total is read before the changes, so objects created in between
+	// show up as changes and make the count come out long rather than go
+	// missing.
+	_, total, err := list(ctx, 1, databricks.NewIDAttrVars(), databricks.NewPaginationVars(1, 1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed, err := listAll(ctx, concurrency, func(ctx context.Context, start uint) ([]T, uint, error) {
+		return list(ctx, start, attrs, databricks.NewFilterVars(databricks.Ge("meta.lastModified", state.Watermark)))
+	})
+	if err != nil {
+		l.Warn("databricks-connector: failed to list changes since the last sync, fetching everything", zap.Error(err))
+		return nil, false, nil
+	}
+
+	rv := previous
+	index := make(map[string]int, len(previous))
+	for i, item := range previous {
+		index[item.GetID()] = i
+	}
+	for _, item := range changed {
+		if i, ok := index[item.GetID()]; ok {
+			rv[i] = item
+			continue
+		}
+
+		index[item.GetID()] = len(rv)
+		rv = append(rv, item)
+	}
+
+	// Every object counted in the total is either kept or changed, so the two
+	// only add up to more than the total when objects were deleted or created
+	// after it was read.
+	if uint(len(rv)) != total {
+		l.Debug("databricks-connector: listing changed beyond its modifications, fetching everything", zap.Int("kept", len(rv)), zap.Uint("total", total))
+		return nil, false, nil
+	}
+
+	// An object deleted after the total was read is still counted in it, so
+	// agreeing counts can hide a deletion; the current IDs can't.
+	current, err := listAll(ctx, concurrency, func(ctx context.Context, start uint) ([]T, uint, error) {
+		return list(ctx, start, databricks.NewIDAttrVars())
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(current) != len(rv) {
+		l.Debug("databricks-connector: listing changed while it was refreshed, fetching everything", zap.Int("kept", len(rv)), zap.Int("current", len(current)))
+		return nil, false, nil
+	}
+	for _, item := range current {
+		if _, ok := index[item.GetID()]; !ok {
+			l.Debug("databricks-connector: listing changed while it was refreshed, fetching everything", zap.String("id", item.GetID()))
+			return nil, false, nil
+		}
+	}
+
+	l.Debug("databricks-connector: refreshed listing incrementally", zap.Int("changed", len(changed)), zap.Int("total", len(rv)))
+
+	return rv, true, nil
+}
+
+// latestModified returns the latest lastModified among items and since, or ""
+// if any item has none, since changes to it couldn't be detected.
+func latestModified[T scimObject](items []T, since string) string {
+	latest, latestTime := since, time.Time{}
+	if since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return ""
+		}
+		latestTime = t
+	}
+
+	for _, item := range items {
+		modified := item.GetMeta().LastModified
+		t, err := time.Parse(time.RFC3339Nano, modified)
+		if err != nil {
+			return ""
+		}
+
+		if t.After(latestTime) {
+			latest, latestTime = modified, t
+		}
+	}
+
+	return latest
+}
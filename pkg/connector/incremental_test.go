@@ -0,0 +1,231 @@
+package connector
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+)
+
+// fakeUsers serves a users listing in pages of pageSize, or of the requested
+// count, filtering on meta.lastModified ge and counting full fetches, i.e.
+// first pages neither filtered nor limited to IDs. afterCount, if set, runs
+// once after a request with count=1.
+type fakeUsers struct {
+	users       []databricks.User
+	pageSize    int
+	fullFetches int
+	requests    int
+	afterCount  func()
+}
+
+func (f *fakeUsers) list(_ context.Context, start uint, vars ...databricks.Vars) ([]databricks.User, uint, error) {
+	params := url.Values{}
+	for _, v := range vars {
+		v.Apply(&params)
+	}
+
+	f.requests++
+	filter := params.Get("filter")
+	idsOnly := slices.Equal(params["attributes"], []string{"id"})
+	if filter == "" && !idsOnly && start == 1 {
+		f.fullFetches++
+	}
+
+	var rv []databricks.User
+	for _, u := range f.users {
+		if since, ok := strings.CutPrefix(filter, `meta.lastModified ge "`); ok && u.Meta.LastModified < strings.TrimSuffix(since, `"`) {
+			continue
+		}
+		if idsOnly {
+			u = databricks.User{BaseResponse: databricks.BaseResponse{ID: u.ID}}
+		}
+		rv = append(rv, u)
+	}
+
+	pageSize := f.pageSize
+	if count, err := strconv.Atoi(params.Get("count")); err == nil {
+		pageSize = count
+	}
+	if params.Get("count") == "1" && f.afterCount != nil {
+		defer f.afterCount()
+		f.afterCount = nil
+	}
+
+	total := uint(len(rv))
+	first := min(int(start)-1, len(rv))
+	return rv[first:min(first+pageSize, len(rv))], total, nil
+}
+
+func fakeUser(id, name, lastModified string) databricks.User {
+	return databricks.User{
+		BaseResponse: databricks.BaseResponse{ID: id, Meta: databricks.Meta{LastModified: lastModified}},
+		UserName:     name,
+	}
+}
+
+func userNames(users []databricks.User) []string {
+	var rv []string
+	for _, u := range users {
+		rv = append(rv, u.UserName)
+	}
+	return rv
+}
+
+// listAllIncremental pages through a listing the way the SDK does, returning
+// the names of the listed users and the number of pages.
+func listAllIncremental(t *testing.T, s *incrementalSync, syncID string, f *fakeUsers) ([]string, int) {
+	t.Helper()
+
+	var (
+		names []string
+		pages int
+	)
+	start := uint(1)
+	for {
+		users, token, err := listIncremental(context.Background(), s, syncID, "user", start, uint(f.pageSize), 1, f.list, databricks.NewUserAttrVars())
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, userNames(users)...)
+		pages++
+
+		if token == "" {
+			return names, pages
+		}
+		start, err = convertPageToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListIncremental(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := newIncrementalSync("", 24*time.Hour)
+	s.now = func() time.Time { return now }
+
+	f := &fakeUsers{pageSize: 2, users: []databricks.User{
+		fakeUser("1", "alice", "2024-04-01T00:00:00Z"),
+		fakeUser("2", "bob", "2024-04-02T00:00:00Z"),
+		fakeUser("3", "carol", "2024-04-03T00:00:00Z"),
+	}}
+
+	got, pages := listAllIncremental(t, s, "sync-1", f)
+	if !slices.Equal(got, []string{"alice", "bob", "carol"}) || pages != 2 || f.fullFetches != 1 {
+		t.Fatalf("first sync: got %v in %d pages with %d full fetches", got, pages, f.fullFetches)
+	}
+
+	// bob is renamed and dave created.
+	f.users = []databricks.User{
+		fakeUser("1", "alice", "2024-04-01T00:00:00Z"),
+		fakeUser("2", "robert", "2024-04-05T00:00:00Z"),
+		fakeUser("3", "carol", "2024-04-03T00:00:00Z"),
+		fakeUser("4", "dave", "2024-04-06T00:00:00Z"),
+	}
+	now = now.Add(time.Hour)
+	f.requests = 0
+	got, pages = listAllIncremental(t, s, "sync-2", f)
+	if !slices.Equal(got, []string{"alice", "robert", "carol", "dave"}) || pages != 2 || f.fullFetches != 1 {
+		t.Fatalf("incremental sync: got %v in %d pages with %d full fetches", got, pages, f.fullFetches)
+	}
+	// One request for the count, two pages of changes, carol included since
+	// she was modified at the watermark, and two pages of IDs. Unchanged
+	// objects aren't requested in full.
+	if f.requests != 5 {
+		t.Errorf("incremental sync made %d requests, want 5", f.requests)
+	}
+
+	// carol is deleted, so the count comes out short.
+	f.users = slices.Delete(f.users, 2, 3)
+	now = now.Add(time.Hour)
+	got, _ = listAllIncremental(t, s, "sync-3", f)
+	if !slices.Equal(got, []string{"alice", "robert", "dave"}) || f.fullFetches != 2 {
+		t.Fatalf("sync after a deletion: got %v with %d full fetches", got, f.fullFetches)
+	}
+
+	// The last full fetch is too old.
+	now = now.Add(24 * time.Hour)
+	if got, _ := listAllIncremental(t, s, "sync-4", f); len(got) != 3 || f.fullFetches != 3 {
+		t.Fatalf("sync after the interval: got %v with %d full fetches", got, f.fullFetches)
+	}
+}
+
+func TestListIncrementalDeletionAfterCount(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := newIncrementalSync("", 24*time.Hour)
+	s.now = func() time.Time { return now }
+
+	f := &fakeUsers{pageSize: 10, users: []databricks.User{
+		fakeUser("1", "alice", "2024-04-01T00:00:00Z"),
+		fakeUser("2", "bob", "2024-04-02T00:00:00Z"),
+	}}
+	listAllIncremental(t, s, "sync-1", f)
+
+	// carol is created before the count is read and bob deleted after, so
+	// the count agrees with the state plus the changes.
+	f.users = append(f.users, fakeUser("3", "carol", "2024-04-03T00:00:00Z"))
+	f.afterCount = func() { f.users = slices.Delete(f.users, 1, 2) }
+	now = now.Add(time.Hour)
+	got, _ := listAllIncremental(t, s, "sync-2", f)
+	if !slices.Equal(got, []string{"alice", "carol"}) || f.fullFetches != 2 {
+		t.Fatalf("sync after a deletion cancelled out: got %v with %d full fetches", got, f.fullFetches)
+	}
+}
+
+func TestListIncrementalStateDir(t *testing.T) {
+	dir := t.TempDir()
+	f := &fakeUsers{pageSize: 10, users: []databricks.User{
+		fakeUser("1", "alice", "2024-04-01T00:00:00Z"),
+		fakeUser("2", "bob", "2024-04-02T00:00:00Z"),
+	}}
+
+	listAllIncremental(t, newIncrementalSync(dir, 24*time.Hour), "sync-1", f)
+
+	// A new process picks up the state written by the previous one.
+	if got, _ := listAllIncremental(t, newIncrementalSync(dir, 24*time.Hour), "sync-2", f); len(got) != 2 || f.fullFetches != 1 {
+		t.Fatalf("sync in a new process: got %v with %d full fetches", got, f.fullFetches)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "user.json")); err != nil {
+		t.Errorf("state file: %v", err)
+	}
+}
+
+func TestListIncrementalPartialFullFetch(t *testing.T) {
+	s := newIncrementalSync("", 24*time.Hour)
+	f := &fakeUsers{pageSize: 1, users: []databricks.User{
+		fakeUser("1", "alice", "2024-04-01T00:00:00Z"),
+		fakeUser("2", "bob", "2024-04-02T00:00:00Z"),
+	}}
+
+	// A full listing picked up from its second page, e.g. after a restart,
+	// didn't see every object and can't become the next state.
+	if _, _, err := listIncremental(context.Background(), s, "sync-1", "user", 2, 1, 1, f.list, databricks.NewUserAttrVars()); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := s.load("user"); state != nil {
+		t.Errorf("state after a partial listing = %+v, want none", state)
+	}
+}
+
+func TestListIncrementalWithoutLastModified(t *testing.T) {
+	s := newIncrementalSync("", 24*time.Hour)
+	f := &fakeUsers{pageSize: 10, users: []databricks.User{
+		fakeUser("1", "alice", "2024-04-01T00:00:00Z"),
+		fakeUser("2", "bob", ""),
+	}}
+
+	listAllIncremental(t, s, "sync-1", f)
+	listAllIncremental(t, s, "sync-2", f)
+
+	if f.fullFetches != 2 {
+		t.Fatalf("expected every sync to fetch everything, got %d full fetches", f.fullFetches)
+	}
+}
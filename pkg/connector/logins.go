@@ -0,0 +1,291 @@
+package connector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// loginLookback bounds the audit log scanned for logins. It matches the
+// retention of system.access.audit.
+const loginLookback = 365 * 24 * time.Hour
+
+// loginActions are the audit log actions of the accounts service that
+// authenticate a principal.
+var loginActions = []string{
+	"aadBrowserLogin",
+	"aadTokenLogin",
+	"jwtLogin",
+	"login",
+	"mfaLogin",
+	"oidcBrowserLogin",
+	"oidcTokenAuthorization",
+	"samlLogin",
+	"tokenLogin",
+}
+
+// loginQuery returns the latest successful login of every identity since
+// :since, where %s narrows it down to some identities. The audit log identifies
+// users by username and service principals by application ID.
+var loginQuery = fmt.Sprintf(`SELECT user_identity.email, max(event_time)
+FROM system.access.audit
+WHERE service_name = 'accounts'
+  AND action_name IN ('%s')
+  AND response.status_code = 200
+  AND event_time >= :since%%s
+GROUP BY user_identity.email`, strings.Join(loginActions, "', '"))
+
+// loginIdentityFilter narrows loginQuery down to the identity :identity.
+const loginIdentityFilter = `
+  AND lower(user_identity.email) = :identity`
+
+// loginLoader returns the last logins, keyed by lowercased username or
+// application ID, of every identity, or only of identity when it isn't empty.
+type loginLoader func(ctx context.Context, identity string) (map[string]time.Time, error)
+
+// loginActivity shares the last logins read from the audit log between the
+// user and service principal syncers of a sync. Like principalSnapshots, it
+// keeps them until a new sync starts, and keeps nothing without a sync ID.
+type loginActivity struct {
+	load loginLoader
+
+	mu     sync.Mutex
+	syncID string
+	logins map[string]time.Time
+	loads  singleflight.Group
+}
+
+func newLoginActivity(load loginLoader) *loginActivity {
+	return &loginActivity{load: load}
+}
+
+func (l *loginActivity) cached(syncID string) (map[string]time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if syncID != l.syncID {
+		l.syncID = syncID
+		l.logins = nil
+	}
+
+	return l.logins, l.logins != nil
+}
+
+func (l *loginActivity) store(syncID string, logins map[string]time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if syncID == l.syncID {
+		l.logins = logins
+	}
+}
+
+// get returns the last logins for the sync, loading them at most once per
+// sync. A nil loginActivity has no logins. Last logins are optional, so a
+// failure to load them, e.g. a stopped warehouse or a missing grant on
+// system.access.audit, is logged and the sync goes on without them.
+func (l *loginActivity) get(ctx context.Context, syncID string) map[string]time.Time {
+	if l == nil {
+		return nil
+	}
+
+	if syncID == "" {
+		return l.loadOrWarn(ctx, "")
+	}
+
+	if logins, ok := l.cached(syncID); ok {
+		return logins
+	}
+
+	v, _, _ := l.loads.Do(syncID, func() (interface{}, error) {
+		if logins, ok := l.cached(syncID); ok {
+			return logins, nil
+		}
+
+		// A failure is kept for the sync as no logins, so every page
+		// doesn't retry it.
+		logins := l.loadOrWarn(ctx, "")
+		if logins == nil {
+			logins = make(map[string]time.Time)
+		}
+
+		l.store(syncID, logins)
+		return logins, nil
+	})
+
+	return v.(map[string]time.Time)
+}
+
+// lookup returns the last login of one identity, for targeted syncs, without
+// loading every identity's. A nil loginActivity has no logins.
+func (l *loginActivity) lookup(ctx context.Context, identity string) time.Time {
+	if l == nil {
+		return time.Time{}
+	}
+
+	identity = strings.ToLower(identity)
+	if identity == "" {
+		return time.Time{}
+	}
+
+	return l.loadOrWarn(ctx, identity)[identity]
+}
+
+// loadOrWarn loads last logins, or logs why they couldn't be and returns none.
+func (l *loginActivity) loadOrWarn(ctx context.Context, identity string) map[string]time.Time {
+	logins, err := l.load(ctx, identity)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("databricks-connector: failed to load last logins, syncing without them", zap.Error(err))
+		return nil
+	}
+
+	return logins
+}
+
+// recordLogin keeps the later of a login and the one already recorded.
+func recordLogin(logins map[string]time.Time, identity string, at time.Time) {
+	identity = strings.ToLower(identity)
+	if identity == "" {
+		return
+	}
+
+	if at.After(logins[identity]) {
+		logins[identity] = at
+	}
+}
+
+// warehouseLogins reads the last logins from system.access.audit on a SQL
+// warehouse of a workspace.
+func warehouseLogins(client *databricks.Client, workspace, warehouseID string, now func() time.Time) loginLoader {
+	return func(ctx context.Context, identity string) (map[string]time.Time, error) {
+		query := fmt.Sprintf(loginQuery, "")
+		params := []databricks.StatementParameter{
+			{Name: "since", Value: now().Add(-loginLookback).UTC().Format(time.RFC3339), Type: "TIMESTAMP"},
+		}
+		if identity != "" {
+			query = fmt.Sprintf(loginQuery, loginIdentityFilter)
+			params = append(params, databricks.StatementParameter{Name: "identity", Value: identity, Type: "STRING"})
+		}
+
+		res, err := client.ExecuteStatement(ctx, workspace, warehouseID, query, params...)
+		if err != nil {
+			return nil, fmt.Errorf("databricks-connector: failed to query logins from audit log: %w", err)
+		}
+
+		logins := make(map[string]time.Time, len(res.Rows))
+		for _, row := range res.Rows {
+			if len(row) < 2 || row[0] == nil || row[1] == nil {
+				continue
+			}
+
+			at, err := parseAuditTime(*row[1])
+			if err != nil {
+				return nil, fmt.Errorf("databricks-connector: failed to parse login time of %s: %w", *row[0], err)
+			}
+
+			recordLogin(logins, *row[0], at)
+		}
+
+		return logins, nil
+	}
+}
+
+// auditLogRecord is a line of a Databricks audit log delivery, in the fields
+// logins need.
+type auditLogRecord struct {
+	ServiceName  string `json:"serviceName"`
+	ActionName   string `json:"actionName"`
+	Timestamp    int64  `json:"timestamp"`
+	UserIdentity struct {
+		Email string `json:"email"`
+	} `json:"userIdentity"`
+	Response struct {
+		StatusCode int `json:"statusCode"`
+	} `json:"response"`
+}
+
+// exportLogins reads the last logins from a file of audit log delivery records,
+// one JSON object per line, as written to the storage of a log delivery. The
+// file is only parsed again once it changes, so targeted syncs looking up one
+// identity at a time don't each re-read it.
+func exportLogins(path string) loginLoader {
+	var (
+		mu      sync.Mutex
+		modTime time.Time
+		size    int64
+		parsed  map[string]time.Time
+	)
+
+	return func(ctx context.Context, identity string) (map[string]time.Time, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("databricks-connector: failed to open audit log export: %w", err)
+		}
+
+		if parsed == nil || !info.ModTime().Equal(modTime) || info.Size() != size {
+			parsed, err = parseExportLogins(path)
+			if err != nil {
+				return nil, err
+			}
+			modTime, size = info.ModTime(), info.Size()
+		}
+
+		if identity == "" {
+			return parsed, nil
+		}
+
+		logins := make(map[string]time.Time, 1)
+		if at, ok := parsed[identity]; ok {
+			logins[identity] = at
+		}
+
+		return logins, nil
+	}
+}
+
+func parseExportLogins(path string) (map[string]time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to open audit log export: %w", err)
+	}
+	defer f.Close()
+
+	logins := make(map[string]time.Time)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var record auditLogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("databricks-connector: failed to parse line %d of audit log export: %w", line, err)
+		}
+
+		if record.ServiceName != "accounts" || !slices.Contains(loginActions, record.ActionName) || record.Response.StatusCode != 200 {
+			continue
+		}
+
+		recordLogin(logins, record.UserIdentity.Email, time.UnixMilli(record.Timestamp))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to read audit log export: %w", err)
+	}
+
+	return logins, nil
+}
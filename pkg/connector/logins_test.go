@@ -0,0 +1,179 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+func TestExportLogins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	err := os.WriteFile(path, []byte(`{"serviceName":"accounts","actionName":"login","timestamp":1714557600000,"userIdentity":{"email":"Alice@example.com"},"response":{"statusCode":200}}
+{"serviceName":"accounts","actionName":"tokenLogin","timestamp":1714644000000,"userIdentity":{"email":"alice@example.com"},"response":{"statusCode":200}}
+{"serviceName":"accounts","actionName":"login","timestamp":1714730400000,"userIdentity":{"email":"alice@example.com"},"response":{"statusCode":401}}
+
+{"serviceName":"clusters","actionName":"create","timestamp":1714730400000,"userIdentity":{"email":"bob@example.com"},"response":{"statusCode":200}}
+{"serviceName":"accounts","actionName":"oidcTokenAuthorization","timestamp":1714557600000,"userIdentity":{"email":"0a1b2c3d-app"},"response":{"statusCode":200}}
+`), 0o600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	load := exportLogins(path)
+	logins, err := load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("exportLogins: %v", err)
+	}
+
+	want := map[string]time.Time{
+		"alice@example.com": time.UnixMilli(1714644000000),
+		"0a1b2c3d-app":      time.UnixMilli(1714557600000),
+	}
+	if len(logins) != len(want) {
+		t.Fatalf("got %v, want %v", logins, want)
+	}
+	for identity, at := range want {
+		if !logins[identity].Equal(at) {
+			t.Errorf("logins[%q] = %v, want %v", identity, logins[identity], at)
+		}
+	}
+
+	// An unchanged file isn't parsed again, so looking identities up one at
+	// a time doesn't re-read it; a changed one is. Garbage of the same size
+	// and modification time passes for unchanged.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", int(info.Size()))), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if logins, err := load(context.Background(), "alice@example.com"); err != nil || len(logins) != 1 {
+		t.Errorf("lookup of an unchanged export = %v, %v, want alice only", logins, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"serviceName":"accounts","actionName":"login","timestamp":1714730400000,"userIdentity":{"email":"bob@example.com"},"response":{"statusCode":200}}`+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if logins, err := load(context.Background(), "bob@example.com"); err != nil || !logins["bob@example.com"].Equal(time.UnixMilli(1714730400000)) {
+		t.Errorf("lookup of a changed export = %v, %v, want bob", logins, err)
+	}
+}
+
+func TestWarehouseLogins(t *testing.T) {
+	now := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	f := newAuditTestFeed(t, func(params map[string]string) [][]*string {
+		if params["since"] != "2023-05-03T00:00:00Z" {
+			t.Errorf("since = %q", params["since"])
+		}
+
+		user, userAt := "Alice@example.com", "2024-05-01T10:00:00.000Z"
+		app, appAt := "0a1b2c3d-app", "2024-04-01 08:00:00.000Z"
+		return [][]*string{{&user, &userAt}, {&app, &appAt}}
+	})
+
+	logins, err := warehouseLogins(f.client, "dbc-audit", "wh-1", func() time.Time { return now })(context.Background(), "")
+	if err != nil {
+		t.Fatalf("warehouseLogins: %v", err)
+	}
+
+	if got := logins["alice@example.com"]; !got.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("alice logged in at %v", got)
+	}
+	if got := logins["0a1b2c3d-app"]; !got.Equal(time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("app logged in at %v", got)
+	}
+}
+
+func TestWarehouseLoginLookup(t *testing.T) {
+	f := newAuditTestFeed(t, func(params map[string]string) [][]*string {
+		if params["identity"] != "alice@example.com" {
+			t.Errorf("identity = %q, want the lowercased username", params["identity"])
+		}
+
+		user, userAt := "Alice@example.com", "2024-05-01T10:00:00.000Z"
+		return [][]*string{{&user, &userAt}}
+	})
+
+	l := newLoginActivity(warehouseLogins(f.client, "dbc-audit", "wh-1", time.Now))
+	if at := l.lookup(context.Background(), "Alice@example.com"); !at.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("alice logged in at %v", at)
+	}
+}
+
+func TestLoginActivityLoadsOncePerSync(t *testing.T) {
+	loads := 0
+	l := newLoginActivity(func(ctx context.Context, identity string) (map[string]time.Time, error) {
+		loads++
+		return map[string]time.Time{}, nil
+	})
+
+	for _, syncID := range []string{"sync-1", "sync-1", "sync-2", "sync-2"} {
+		l.get(context.Background(), syncID)
+	}
+	if loads != 2 {
+		t.Errorf("loaded %d times, want once per sync", loads)
+	}
+
+	var none *loginActivity
+	if logins := none.get(context.Background(), "sync-1"); logins != nil {
+		t.Errorf("nil loginActivity returned %v", logins)
+	}
+}
+
+func TestLoginActivityFailureSyncsWithoutLogins(t *testing.T) {
+	loads := 0
+	l := newLoginActivity(func(ctx context.Context, identity string) (map[string]time.Time, error) {
+		loads++
+		return nil, errors.New("warehouse stopped")
+	})
+
+	for range 2 {
+		if logins := l.get(context.Background(), "sync-1"); len(logins) != 0 {
+			t.Errorf("logins = %v, want none", logins)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want the failure kept for the sync", loads)
+	}
+
+	if at := l.lookup(context.Background(), "alice@example.com"); !at.IsZero() {
+		t.Errorf("lookup = %v, want no login", at)
+	}
+}
+
+func TestLastLoginOnResources(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	parent := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+
+	user, err := newUserBuilder(nil, 1, nil, nil).userResource(context.Background(), &databricks.User{BaseResponse: databricks.BaseResponse{ID: "u-1"}, UserName: "alice@example.com"}, parent, at)
+	if err != nil {
+		t.Fatalf("userResource: %v", err)
+	}
+	trait, err := rs.GetUserTrait(user)
+	if err != nil {
+		t.Fatalf("GetUserTrait: %v", err)
+	}
+	if !trait.GetLastLogin().AsTime().Equal(at) {
+		t.Errorf("user last login = %v, want %v", trait.GetLastLogin().AsTime(), at)
+	}
+
+	sp, err := newServicePrincipalBuilder(nil, 1, nil, nil).servicePrincipalResource(context.Background(), &databricks.ServicePrincipal{BaseResponse: databricks.BaseResponse{ID: "sp-1"}, ApplicationID: "app"}, parent, at)
+	if err != nil {
+		t.Fatalf("servicePrincipalResource: %v", err)
+	}
+	if got, _ := rs.GetProfileStringValue(sp.GetProfile(), "last_login"); got != "2024-05-01T10:00:00Z" {
+		t.Errorf("service principal last_login = %q", got)
+	}
+}
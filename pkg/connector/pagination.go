@@ -1,15 +1,15 @@
 package connector
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"sync"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 )
 
-const ResourcesPageSize uint = 50
-
 func parsePageToken(i string, resourceID *v2.ResourceId) (*pagination.Bag, uint, error) {
 	b := &pagination.Bag{}
 	err := b.Unmarshal(i)
@@ -58,3 +58,56 @@ func prepareNextToken(page uint, pageTotal int, total uint) string {
 
 	return token
 }
+
+// fetchPageFunc fetches the page of a SCIM listing starting at start, returning
+// its resources and the total number of results.
+type fetchPageFunc[T any] func(ctx context.Context, start uint) ([]T, uint, error)
+
+// listPages fetches the page starting at start and returns its resources with
+// the next page token. With a concurrency above 1, once the first page reports
+// the total it also fetches up to concurrency-1 following pages in parallel and
+// returns them all, in order, as one page.
+func listPages[T any](ctx context.Context, start uint, concurrency int, fetch fetchPageFunc[T]) ([]T, string, error) {
+	first, total, err := fetch(ctx, start)
+	if err != nil {
+		return nil, "", err
+	}
+
+	step := uint(len(first))
+	if concurrency <= 1 || step == 0 {
+		return first, prepareNextToken(start, len(first), total), nil
+	}
+
+	var starts []uint
+	for next := start + step; next <= total && len(starts) < concurrency-1; next += step {
+		starts = append(starts, next)
+	}
+
+	pages := make([][]T, len(starts))
+	errs := make([]error, len(starts))
+	var wg sync.WaitGroup
+	for i, s := range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pages[i], _, errs[i] = fetch(ctx, s)
+		}()
+	}
+	wg.Wait()
+
+	rv := first
+	for i, page := range pages {
+		if errs[i] != nil {
+			return nil, "", errs[i]
+		}
+
+		rv = append(rv, page...)
+		// A short page means the listing changed while it was read; continue
+		// from its end so the next page doesn't skip anything.
+		if uint(len(page)) < step {
+			break
+		}
+	}
+
+	return rv, prepareNextToken(start, len(rv), total), nil
+}
@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeListing serves pages of a listing of total items numbered from 1.
+func fakeListing(total, pageSize uint, calls *atomic.Int32) fetchPageFunc[uint] {
+	return func(_ context.Context, start uint) ([]uint, uint, error) {
+		calls.Add(1)
+
+		var page []uint
+		for i := start; i <= total && i < start+pageSize; i++ {
+			page = append(page, i)
+		}
+
+		return page, total, nil
+	}
+}
+
+func TestListPagesSequential(t *testing.T) {
+	var calls atomic.Int32
+
+	items, token, err := listPages(context.Background(), 1, 1, fakeListing(120, 50, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 50 || token != "51" || calls.Load() != 1 {
+		t.Fatalf("got %d items, token %q and %d calls", len(items), token, calls.Load())
+	}
+}
+
+func TestListPagesParallel(t *testing.T) {
+	var calls atomic.Int32
+
+	items, token, err := listPages(context.Background(), 1, 4, fakeListing(120, 50, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only two pages are left after the first, so only three are fetched.
+	if calls.Load() != 3 || token != "" {
+		t.Fatalf("got token %q and %d calls", token, calls.Load())
+	}
+
+	for i, item := range items {
+		if item != uint(i+1) {
+			t.Fatalf("items out of order at %d: %v", i, items)
+		}
+	}
+	if len(items) != 120 {
+		t.Fatalf("expected 120 items, got %d", len(items))
+	}
+}
+
+func TestListPagesParallelResumes(t *testing.T) {
+	var calls atomic.Int32
+
+	items, token, err := listPages(context.Background(), 51, 2, fakeListing(300, 50, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 100 || items[0] != 51 || token != "151" {
+		t.Fatalf("got %d items from %d, token %q", len(items), items[0], token)
+	}
+}
+
+func TestListPagesShortPage(t *testing.T) {
+	fetch := func(_ context.Context, start uint) ([]uint, uint, error) {
+		if start == 51 {
+			return []uint{51, 52}, 200, nil
+		}
+		return slices.Repeat([]uint{start}, 50), 200, nil
+	}
+
+	items, token, err := listPages(context.Background(), 1, 4, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 52 || token != "53" {
+		t.Fatalf("got %d items, token %q", len(items), token)
+	}
+}
+
+func TestListPagesError(t *testing.T) {
+	errBoom := errors.New("boom")
+	fetch := func(_ context.Context, start uint) ([]uint, uint, error) {
+		if start > 1 {
+			return nil, 0, errBoom
+		}
+		return make([]uint, 50), 200, nil
+	}
+
+	if _, _, err := listPages(context.Background(), 1, 3, fetch); !errors.Is(err, errBoom) {
+		t.Fatalf("expected %v, got %v", errBoom, err)
+	}
+}
@@ -0,0 +1,224 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"golang.org/x/sync/singleflight"
+)
+
+// principalSnapshot is every user, group and service principal of a workspace,
+// or of the account, with their roles and entitlements.
+type principalSnapshot struct {
+	users             []databricks.User
+	groups            []databricks.Group
+	servicePrincipals []databricks.ServicePrincipal
+}
+
+// len returns the number of principals in the snapshot. Role grants index
+// them users first, then groups, then service principals.
+func (s *principalSnapshot) len() int {
+	return len(s.users) + len(s.groups) + len(s.servicePrincipals)
+}
+
+// principalSnapshots shares principal snapshots between the role resources of
+// a sync. Databricks can't list the members of a role, so without it every role
+// rescans all principals of its workspace. The SDK has no end-of-sync hook, so
+// a workspace's snapshot is released once the grants of every role listed for
+// it have been emitted, and anything left is dropped when a new sync starts.
+// Without a sync ID nothing is kept.
+type principalSnapshots struct {
+	mu          sync.Mutex
+	syncID      string
+	byWorkspace map[string]*principalSnapshot
+	// pending holds, per workspace, the roles listed in the sync whose
+	// grants haven't all been emitted yet.
+	pending map[string]map[string]bool
+	loads   singleflight.Group
+}
+
+func newPrincipalSnapshots() *principalSnapshots {
+	return &principalSnapshots{
+		byWorkspace: make(map[string]*principalSnapshot),
+		pending:     make(map[string]map[string]bool),
+	}
+}
+
+// resetLocked drops the state of earlier syncs. p.mu must be held.
+func (p *principalSnapshots) resetLocked(syncID string) {
+	if syncID == p.syncID {
+		return
+	}
+
+	p.syncID = syncID
+	p.byWorkspace = make(map[string]*principalSnapshot)
+	p.pending = make(map[string]map[string]bool)
+}
+
+// cached returns the snapshot of a workspace for the sync, dropping the
+// snapshots of earlier syncs.
+func (p *principalSnapshots) cached(syncID, workspaceId string) (*principalSnapshot, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.resetLocked(syncID)
+
+	s, ok := p.byWorkspace[workspaceId]
+	return s, ok
+}
+
+// expect records that the grants of a role will be read from the snapshot of
+// its workspace during the sync.
+func (p *principalSnapshots) expect(syncID, workspaceId, roleID string) {
+	if syncID == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.resetLocked(syncID)
+
+	if p.pending[workspaceId] == nil {
+		p.pending[workspaceId] = make(map[string]bool)
+	}
+	p.pending[workspaceId][roleID] = true
+}
+
+// release records that every grant of a role has been emitted, dropping the
+// snapshot of its workspace once no expected role is left. Snapshots of
+// workspaces whose roles weren't listed in the sync are kept until it ends.
+func (p *principalSnapshots) release(syncID, workspaceId, roleID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	roles, ok := p.pending[workspaceId]
+	if syncID != p.syncID || !ok {
+		return
+	}
+
+	delete(roles, roleID)
+	if len(roles) == 0 {
+		delete(p.pending, workspaceId)
+		delete(p.byWorkspace, workspaceId)
+	}
+}
+
+func (p *principalSnapshots) store(syncID, workspaceId string, s *principalSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if syncID == p.syncID {
+		p.byWorkspace[workspaceId] = s
+	}
+}
+
+// get returns the snapshot of a workspace ("" for the account), loading it at
+// most once per sync even when role resources are synced concurrently.
+func (p *principalSnapshots) get(
+	ctx context.Context,
+	syncID string,
+	workspaceId string,
+	load func(ctx context.Context, workspaceId string) (*principalSnapshot, error),
+) (*principalSnapshot, error) {
+	if syncID == "" {
+		return load(ctx, workspaceId)
+	}
+
+	if s, ok := p.cached(syncID, workspaceId); ok {
+		return s, nil
+	}
+
+	v, err, _ := p.loads.Do(syncID+"/"+workspaceId, func() (interface{}, error) {
+		if s, ok := p.cached(syncID, workspaceId); ok {
+			return s, nil
+		}
+
+		s, err := load(ctx, workspaceId)
+		if err != nil {
+			return nil, err
+		}
+
+		p.store(syncID, workspaceId, s)
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*principalSnapshot), nil
+}
+
+// listAll fetches every page of a SCIM listing.
+func listAll[T any](ctx context.Context, concurrency int, fetch fetchPageFunc[T]) ([]T, error) {
+	var rv []T
+	start := uint(1)
+	for {
+		items, token, err := listPages(ctx, start, concurrency, fetch)
+		if err != nil {
+			return nil, err
+		}
+
+		rv = append(rv, items...)
+		if token == "" || len(items) == 0 {
+			return rv, nil
+		}
+
+		start, err = convertPageToken(token)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// loadPrincipalSnapshot lists every principal of a workspace with its roles
+// and entitlements.
+func loadPrincipalSnapshot(ctx context.Context, client *databricks.Client, pageConcurrency int, workspaceId string) (*principalSnapshot, error) {
+	var (
+		s   principalSnapshot
+		err error
+	)
+
+	s.users, err = listAll(ctx, pageConcurrency, func(ctx context.Context, start uint) ([]databricks.User, uint, error) {
+		users, total, _, err := client.ListUsers(
+			ctx,
+			workspaceId,
+			databricks.NewPaginationVars(start, client.PageSize()),
+			databricks.NewUserRolesAttrVars(),
+		)
+		return users, total, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to list users: %w", err)
+	}
+
+	s.groups, err = listAll(ctx, pageConcurrency, func(ctx context.Context, start uint) ([]databricks.Group, uint, error) {
+		groups, total, _, err := client.ListGroups(
+			ctx,
+			workspaceId,
+			databricks.NewPaginationVars(start, client.PageSize()),
+			databricks.NewGroupRolesAttrVars(),
+		)
+		return groups, total, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to list groups: %w", err)
+	}
+
+	s.servicePrincipals, err = listAll(ctx, pageConcurrency, func(ctx context.Context, start uint) ([]databricks.ServicePrincipal, uint, error) {
+		servicePrincipals, total, _, err := client.ListServicePrincipals(
+			ctx,
+			workspaceId,
+			databricks.NewPaginationVars(start, client.PageSize()),
+			databricks.NewServicePrincipalRolesAttrVars(),
+		)
+		return servicePrincipals, total, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to list service principals: %w", err)
+	}
+
+	return &s, nil
+}
@@ -3,11 +3,13 @@ package connector
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/conductorone/baton-databricks/pkg/databricks"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
-	"github.com/conductorone/baton-sdk/pkg/pagination"
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
@@ -21,20 +23,24 @@ const (
 	EntitlementType       = "entitlement"
 )
 
-var roles = []string{
-	AccountAdminRole,
-}
-
+// entitlements are the workspace entitlements known to the connector. Each
+// workspace syncs these plus any other entitlement its principals carry, so
+// values Databricks introduces later show up without a connector release.
 var entitlements = []string{
 	WorkspaceAccessRole,
 	SQLAccessRole,
 	ClusterCreateRole,
 	InstancePoolCreateRole,
+	WorkspaceConsumeRole,
 }
 
 type roleBuilder struct {
 	client       *databricks.Client
 	resourceType *v2.ResourceType
+	// pageConcurrency is the number of SCIM pages fetched at once when
+	// loading principal snapshots.
+	pageConcurrency int
+	principals      *principalSnapshots
 }
 
 func (r *roleBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -75,30 +81,44 @@ func roleResource(ctx context.Context, role string, parent *v2.ResourceId) (*v2.
 
 // List returns all the roles from the database as resource objects.
 // Roles include a RoleTrait because they are the 'shape' of a standard role.
-func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, _ rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
+func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
 	if parentResourceID == nil {
 		return nil, nil, nil
 	}
 
 	var rv []*v2.Resource
 	if parentResourceID.ResourceType == accountResourceType.Id {
-		for _, role := range roles {
+		assignableRoles, _, err := r.client.ListRoles(ctx, "", "", "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("databricks-connector: failed to list account roles: %w", err)
+		}
+
+		for _, role := range accountRoleNames(assignableRoles) {
 			rr, err := roleResource(ctx, role, parentResourceID)
 			if err != nil {
 				return nil, nil, err
 			}
 
+			if !isRuleSetRole(parentResourceID.ResourceType, role) {
+				r.principals.expect(attr.SyncID, "", rr.Id.Resource)
+			}
 			rv = append(rv, rr)
 		}
 	}
 
 	if parentResourceID.ResourceType == workspaceResourceType.Id {
-		for _, ent := range entitlements {
+		workspaceEntitlements, err := r.workspaceEntitlements(ctx, attr.SyncID, parentResourceID.Resource)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, ent := range workspaceEntitlements {
 			er, err := roleResource(ctx, ent, parentResourceID)
 			if err != nil {
 				return nil, nil, err
 			}
 
+			r.principals.expect(attr.SyncID, parentResourceID.Resource, er.Id.Resource)
 			rv = append(rv, er)
 		}
 	}
@@ -106,6 +126,102 @@ func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	return rv, nil, nil
 }
 
+// grantSourceOptions annotates a role grant with where the permission comes
+// from. Direct-only grants are left as they were; grants inherited from groups
+// carry the source groups in their metadata, and grants that are only
+// inherited are marked immutable since they can't be revoked from the principal.
+func (r *roleBuilder) grantSourceOptions(ctx context.Context, workspaceId string, sources databricks.PermissionSources) ([]grant.GrantOption, error) {
+	if !sources.Inherited {
+		return nil, nil
+	}
+
+	groupParentResourceId, err := groupGrantParent(r.client.IsAccountAPIAvailable(), r.client.GetAccountId(), workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return grantSourceOptions(ctx, sources, groupParentResourceId), nil
+}
+
+// workspaceEntitlements returns the entitlements supported by a workspace.
+// Databricks has no API listing them, so the known set is extended with every
+// entitlement assigned to the workspace's users, groups and service
+// principals. Discovery is best effort: if the principals can't be listed the
+// known set is returned, unless the credentials aren't allowed to list them,
+// which would fail the role grants too.
+func (r *roleBuilder) workspaceEntitlements(ctx context.Context, syncID, workspaceId string) ([]string, error) {
+	l := ctxzap.Extract(ctx)
+
+	rv := slices.Clone(entitlements)
+	snapshot, err := r.principals.get(ctx, syncID, workspaceId, r.loadPrincipals)
+	if err != nil {
+		if isAccessDeniedError(err) {
+			return nil, fmt.Errorf("databricks-connector: failed to discover entitlements of workspace %s: %w", workspaceId, err)
+		}
+
+		l.Warn(
+			"databricks-connector: failed to discover workspace entitlements, using the known set",
+			zap.String("workspace_id", workspaceId),
+			zap.Error(err),
+		)
+
+		return rv, nil
+	}
+
+	for _, u := range snapshot.users {
+		rv = appendEntitlements(rv, u.Entitlements)
+	}
+	for _, g := range snapshot.groups {
+		rv = appendEntitlements(rv, g.Entitlements)
+	}
+	for _, sp := range snapshot.servicePrincipals {
+		rv = appendEntitlements(rv, sp.Entitlements)
+	}
+
+	return rv, nil
+}
+
+// loadPrincipals loads the principal snapshot role grants are computed from.
+func (r *roleBuilder) loadPrincipals(ctx context.Context, workspaceId string) (*principalSnapshot, error) {
+	return loadPrincipalSnapshot(ctx, r.client, r.pageConcurrency, workspaceId)
+}
+
+// appendEntitlements adds the entitlement values not yet in names.
+func appendEntitlements(names []string, values []databricks.PermissionValue) []string {
+	for _, v := range values {
+		if v.Value == "" || slices.Contains(names, v.Value) {
+			continue
+		}
+
+		names = append(names, v.Value)
+	}
+
+	return names
+}
+
+// accountRoleNames returns the account roles to sync: account_admin, which is
+// granted through SCIM roles, followed by every role the assignable-roles API
+// reports for the account, which are granted through the account rule set.
+func accountRoleNames(assignableRoles []databricks.Role) []string {
+	rv := []string{AccountAdminRole}
+	for _, role := range assignableRoles {
+		name := strings.TrimPrefix(role.Name, ruleSetRolePrefix)
+		if name == "" || slices.Contains(rv, name) {
+			continue
+		}
+
+		rv = append(rv, name)
+	}
+
+	return rv
+}
+
+// isRuleSetRole reports whether an account role is granted through the
+// account rule set rather than SCIM roles.
+func isRuleSetRole(parentType, roleName string) bool {
+	return parentType == accountResourceType.Id && roleName != AccountAdminRole
+}
+
 // Entitlements returns membership entitlements for a given role.
 func (r *roleBuilder) Entitlements(
 	_ context.Context,
@@ -130,7 +246,12 @@ func (r *roleBuilder) Entitlements(
 // Grants returns all the grants for a given role.
 // Since Databricks API does not support listing grants for a role, so that it aligns with the sdk API,
 // we have to go through all the users, groups and servicePrincipals to check if they have the role.
+// They are listed once per workspace and sync, and shared by all its roles
+// until each role has emitted its last page of grants or failed; pages pick up
+// the scan where the previous one stopped.
 func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, attr rs.SyncOpAttrs) ([]*v2.Grant, *rs.SyncOpResults, error) {
+	r.client.SetSyncID(attr.SyncID)
+
 	var rv []*v2.Grant
 
 	profile := rs.GetProfile(resource)
@@ -153,144 +274,175 @@ func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, attr rs
 		return nil, nil, fmt.Errorf("databricks-connector: failed to get role type from role profile")
 	}
 
+	if isRuleSetRole(parentType, roleName) {
+		ruleSets, _, err := r.client.ListRuleSets(ctx, "", "", "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("databricks-connector: failed to list rule sets for account %s: %w", parentID, err)
+		}
+
+		rv, err = ruleSetGrants(ctx, r.client, resource, RoleMemberEntitlement, ruleSetRole(roleName), ruleSets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("databricks-connector: failed to prepare grants for role %s: %w", roleName, err)
+		}
+
+		return rv, nil, nil
+	}
+
 	bag, page, err := parsePageToken(attr.PageToken.Token, &v2.ResourceId{ResourceType: roleResourceType.Id})
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to parse page token: %w", err)
 	}
 
-	switch bag.ResourceTypeID() {
-	case roleResourceType.Id:
-		bag.Pop()
-		bag.Push(pagination.PageState{
-			ResourceTypeID: userResourceType.Id,
-		})
-		bag.Push(pagination.PageState{
-			ResourceTypeID: groupResourceType.Id,
-		})
-		bag.Push(pagination.PageState{
-			ResourceTypeID: servicePrincipalResourceType.Id,
-		})
+	rv, next, err := r.pageSnapshotGrants(ctx, resource, attr.SyncID, workspaceId, isWorkspaceRole, roleName, int(page)-1) // #nosec G115 -- page numbers come from our own tokens.
+	if err != nil {
+		// The role won't be asked for its remaining pages, so it no longer
+		// holds on to the snapshot.
+		r.principals.release(attr.SyncID, workspaceId, resource.Id.Resource)
+		return nil, nil, err
+	}
 
-	case userResourceType.Id:
-		users, total, _, err := r.client.ListUsers(
-			ctx,
-			workspaceId,
-			databricks.NewPaginationVars(page, ResourcesPageSize),
-			databricks.NewUserRolesAttrVars(),
-		)
+	var token string
+	if next != 0 {
+		token = strconv.Itoa(next + 1)
+	} else {
+		r.principals.release(attr.SyncID, workspaceId, resource.Id.Resource)
+	}
+
+	nextPage, err := bag.NextToken(token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
+	}
+
+	return rv, &rs.SyncOpResults{NextPageToken: nextPage}, nil
+}
+
+// pageSnapshotGrants returns a page of the grants of a SCIM role, or workspace
+// entitlement, held by the principals of a snapshot, scanning them from the
+// principal at index from. It returns the index to continue from, or 0 once
+// every principal has been scanned, so each principal is looked at once per
+// role however many pages its grants take. Without a sync ID the snapshot
+// isn't kept between calls, so every grant is returned in one page.
+func (r *roleBuilder) pageSnapshotGrants(
+	ctx context.Context,
+	resource *v2.Resource,
+	syncID string,
+	workspaceId string,
+	isWorkspaceRole bool,
+	roleName string,
+	from int,
+) ([]*v2.Grant, int, error) {
+	snapshot, err := r.principals.get(ctx, syncID, workspaceId, r.loadPrincipals)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := int(r.client.PageSize()) // #nosec G115 -- the page size is at most config.MaxPageSize.
+	if syncID == "" {
+		limit = snapshot.len()
+	}
+
+	var rv []*v2.Grant
+	i := from
+	for ; i < snapshot.len() && len(rv) < limit; i++ {
+		g, err := r.principalGrant(ctx, resource, snapshot, i, workspaceId, isWorkspaceRole, roleName)
 		if err != nil {
-			return nil, nil, fmt.Errorf("databricks-connector: failed to list users: %w", err)
+			return nil, 0, err
+		}
+		if g != nil {
+			rv = append(rv, g)
 		}
+	}
 
-		// check if user has the role
-		for _, u := range users {
-			if !isWorkspaceRole && u.HaveRole(roleName) {
-				uID, err := rs.NewResourceID(userResourceType, u.ID)
-				if err != nil {
-					return nil, nil, fmt.Errorf("databricks-connector: failed to create user resource id: %w", err)
-				}
-
-				rv = append(rv, grant.NewGrant(resource, RoleMemberEntitlement, uID))
-			} else if isWorkspaceRole && u.HaveEntitlement(roleName) {
-				uID, err := rs.NewResourceID(userResourceType, u.ID)
-				if err != nil {
-					return nil, nil, fmt.Errorf("databricks-connector: failed to create user resource id: %w", err)
-				}
-
-				rv = append(rv, grant.NewGrant(resource, RoleMemberEntitlement, uID))
-			}
+	if i >= snapshot.len() {
+		return rv, 0, nil
+	}
+
+	return rv, i, nil
+}
+
+// principalGrant returns the grant of a SCIM role, or workspace entitlement,
+// to the principal at index i of a snapshot, or nil if it doesn't hold it.
+func (r *roleBuilder) principalGrant(
+	ctx context.Context,
+	resource *v2.Resource,
+	snapshot *principalSnapshot,
+	i int,
+	workspaceId string,
+	isWorkspaceRole bool,
+	roleName string,
+) (*v2.Grant, error) {
+	// check if user has the role, directly or through a group
+	if i < len(snapshot.users) {
+		u := snapshot.users[i]
+		sources := permissionSources(u.Permissions, isWorkspaceRole, roleName)
+		if !sources.Any() {
+			return nil, nil
 		}
 
-		token := prepareNextToken(page, len(users), total)
-		err = bag.Next(token)
+		uID, err := rs.NewResourceID(userResourceType, u.ID)
 		if err != nil {
-			return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
+			return nil, fmt.Errorf("databricks-connector: failed to create user resource id: %w", err)
 		}
 
-	case groupResourceType.Id:
-		groups, total, _, err := r.client.ListGroups(
-			ctx,
-			workspaceId,
-			databricks.NewPaginationVars(page, ResourcesPageSize),
-			databricks.NewGroupRolesAttrVars(),
-		)
+		opts, err := r.grantSourceOptions(ctx, workspaceId, sources)
 		if err != nil {
-			return nil, nil, fmt.Errorf("databricks-connector: failed to list groups: %w", err)
+			return nil, err
 		}
 
-		// check if group has the role
-		for _, g := range groups {
-			// skip workspace specific groups (admins and users)
-			if !g.IsAccountGroup() {
-				continue
-			}
-
-			if (!isWorkspaceRole && g.HaveRole(roleName)) || (isWorkspaceRole && g.HaveEntitlement(roleName)) {
-				groupParentResourceId, err := groupGrantParent(r.client.IsAccountAPIAvailable(), r.client.GetAccountId(), workspaceId)
-				if err != nil {
-					return rv, nil, err
-				}
-				resourceId, expandAnnotation, err := groupGrantExpansion(ctx, g.ID, groupParentResourceId)
-				if err != nil {
-					return rv, nil, err
-				}
-
-				rv = append(rv, grant.NewGrant(resource, RoleMemberEntitlement, resourceId, grant.WithAnnotation(expandAnnotation)))
-			}
+		return grant.NewGrant(resource, RoleMemberEntitlement, uID, opts...), nil
+	}
+	i -= len(snapshot.users)
+
+	// check if group has the role
+	if i < len(snapshot.groups) {
+		g := snapshot.groups[i]
+		// skip workspace specific groups (admins and users)
+		if !g.IsAccountGroup() {
+			return nil, nil
 		}
 
-		token := prepareNextToken(page, len(groups), total)
-		err = bag.Next(token)
-		if err != nil {
-			return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
+		sources := permissionSources(g.Permissions, isWorkspaceRole, roleName)
+		if !sources.Any() {
+			return nil, nil
 		}
 
-	case servicePrincipalResourceType.Id:
-		servicePrincipals, total, _, err := r.client.ListServicePrincipals(
-			ctx,
-			workspaceId,
-			databricks.NewPaginationVars(page, ResourcesPageSize),
-			databricks.NewServicePrincipalRolesAttrVars(),
-		)
+		groupParentResourceId, err := groupGrantParent(r.client.IsAccountAPIAvailable(), r.client.GetAccountId(), workspaceId)
 		if err != nil {
-			return nil, nil, fmt.Errorf("databricks-connector: failed to list service principals: %w", err)
+			return nil, err
 		}
-
-		// check if service principal has the role
-		for _, sp := range servicePrincipals {
-			if !isWorkspaceRole && sp.HaveRole(roleName) {
-				spID, err := rs.NewResourceID(servicePrincipalResourceType, sp.ID)
-				if err != nil {
-					return nil, nil, fmt.Errorf("databricks-connector: failed to create service principal resource id: %w", err)
-				}
-
-				rv = append(rv, grant.NewGrant(resource, RoleMemberEntitlement, spID))
-			} else if isWorkspaceRole && sp.HaveEntitlement(roleName) {
-				spID, err := rs.NewResourceID(servicePrincipalResourceType, sp.ID)
-				if err != nil {
-					return nil, nil, fmt.Errorf("databricks-connector: failed to create service principal resource id: %w", err)
-				}
-
-				rv = append(rv, grant.NewGrant(resource, RoleMemberEntitlement, spID))
-			}
+		resourceId, expandAnnotation, err := groupGrantExpansion(ctx, g.ID, groupParentResourceId)
+		if err != nil {
+			return nil, err
 		}
 
-		token := prepareNextToken(page, len(servicePrincipals), total)
-		err = bag.Next(token)
+		opts, err := r.grantSourceOptions(ctx, workspaceId, sources)
 		if err != nil {
-			return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
+			return nil, err
 		}
 
-	default:
-		return nil, nil, fmt.Errorf("databricks-connector: invalid resource type: %s", bag.ResourceTypeID())
+		opts = append(opts, grant.WithAnnotation(expandAnnotation))
+		return grant.NewGrant(resource, RoleMemberEntitlement, resourceId, opts...), nil
+	}
+	i -= len(snapshot.groups)
+
+	// check if service principal has the role, directly or through a group
+	sp := snapshot.servicePrincipals[i]
+	sources := permissionSources(sp.Permissions, isWorkspaceRole, roleName)
+	if !sources.Any() {
+		return nil, nil
 	}
 
-	nextPage, err := bag.Marshal()
+	spID, err := rs.NewResourceID(servicePrincipalResourceType, sp.ID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
+		return nil, fmt.Errorf("databricks-connector: failed to create service principal resource id: %w", err)
 	}
 
-	return rv, &rs.SyncOpResults{NextPageToken: nextPage}, nil
+	opts, err := r.grantSourceOptions(ctx, workspaceId, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return grant.NewGrant(resource, RoleMemberEntitlement, spID, opts...), nil
 }
 
 func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
@@ -313,10 +465,14 @@ func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitle
 
 	isWorkspaceRole := parentType == workspaceResourceType.Id
 	permissionName := entitlement.Resource.Id.Resource
+	if isRuleSetRole(parentType, permissionName) {
+		return r.grantRuleSetRole(ctx, principal, permissionName)
+	}
+
 	var workspaceId string
 	if isWorkspaceRole {
 		workspaceId = parentID
-		permissionName = prepareWorkspaceRole(permissionName)
+		permissionName = workspaceRoleName(entitlement.Resource)
 	}
 
 	switch principal.Id.ResourceType {
@@ -326,6 +482,7 @@ func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitle
 			return nil, fmt.Errorf("databricks-connector: failed to get user: %w", err)
 		}
 
+		u.DirectOnly()
 		addPermissions(isWorkspaceRole, &u.Permissions, permissionName)
 
 		_, err = r.client.UpdateUser(ctx, workspaceId, u)
@@ -339,6 +496,7 @@ func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitle
 			return nil, fmt.Errorf("databricks-connector: failed to get group: %w", err)
 		}
 
+		g.DirectOnly()
 		addPermissions(isWorkspaceRole, &g.Permissions, permissionName)
 
 		_, err = r.client.UpdateGroup(ctx, workspaceId, g)
@@ -352,6 +510,7 @@ func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitle
 			return nil, fmt.Errorf("databricks-connector: failed to get service principal: %w", err)
 		}
 
+		sp.DirectOnly()
 		addPermissions(isWorkspaceRole, &sp.Permissions, permissionName)
 
 		_, err = r.client.UpdateServicePrincipal(ctx, workspaceId, sp)
@@ -389,10 +548,14 @@ func (r *roleBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.
 
 	isWorkspaceRole := parentType == workspaceResourceType.Id
 	permissionName := entitlement.Resource.Id.Resource
+	if isRuleSetRole(parentType, permissionName) {
+		return r.revokeRuleSetRole(ctx, principal, permissionName)
+	}
+
 	var workspaceId string
 	if isWorkspaceRole {
 		workspaceId = parentID
-		permissionName = prepareWorkspaceRole(permissionName)
+		permissionName = workspaceRoleName(entitlement.Resource)
 	}
 
 	switch principal.Id.ResourceType {
@@ -402,6 +565,7 @@ func (r *roleBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.
 			return nil, fmt.Errorf("databricks-connector: failed to get user: %w", err)
 		}
 
+		u.DirectOnly()
 		removePermissions(isWorkspaceRole, &u.Permissions, permissionName)
 
 		_, err = r.client.UpdateUser(ctx, workspaceId, u)
@@ -415,6 +579,7 @@ func (r *roleBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.
 			return nil, fmt.Errorf("databricks-connector: failed to get group: %w", err)
 		}
 
+		g.DirectOnly()
 		removePermissions(isWorkspaceRole, &g.Permissions, permissionName)
 
 		_, err = r.client.UpdateGroup(ctx, workspaceId, g)
@@ -428,6 +593,7 @@ func (r *roleBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.
 			return nil, fmt.Errorf("databricks-connector: failed to get service principal: %w", err)
 		}
 
+		sp.DirectOnly()
 		removePermissions(isWorkspaceRole, &sp.Permissions, permissionName)
 
 		_, err = r.client.UpdateServicePrincipal(ctx, workspaceId, sp)
@@ -442,9 +608,77 @@ func (r *roleBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.
 	return nil, nil
 }
 
-func newRoleBuilder(client *databricks.Client) *roleBuilder {
+// grantRuleSetRole binds the principal to an account role in the account rule set.
+func (r *roleBuilder) grantRuleSetRole(ctx context.Context, principal *v2.Resource, roleName string) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	ruleSets, _, err := r.client.ListRuleSets(ctx, "", "", "")
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to list rule sets for account: %w", err)
+	}
+
+	principalID, err := preparePrincipalId(ctx, r.client, "", principal.Id.ResourceType, principal.Id.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to prepare principal id for principal %s: %w", principal.Id.Resource, err)
+	}
+
+	ruleSets, changed := addRuleSetPrincipal(ruleSets, ruleSetRole(roleName), principalID)
+	if !changed {
+		l.Info(
+			"databricks-connector: principal already has the role",
+			zap.String("principal_id", principalID),
+			zap.String("role", roleName),
+		)
+
+		return nil, nil
+	}
+
+	_, err = r.client.UpdateRuleSets(ctx, "", "", "", ruleSets)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to add role: %w", err)
+	}
+
+	return nil, nil
+}
+
+// revokeRuleSetRole unbinds the principal from an account role in the account rule set.
+func (r *roleBuilder) revokeRuleSetRole(ctx context.Context, principal *v2.Resource, roleName string) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	ruleSets, _, err := r.client.ListRuleSets(ctx, "", "", "")
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to list rule sets for account: %w", err)
+	}
+
+	principalID, err := preparePrincipalId(ctx, r.client, "", principal.Id.ResourceType, principal.Id.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to prepare principal id: %w", err)
+	}
+
+	ruleSets, changed := removeRuleSetPrincipal(ruleSets, ruleSetRole(roleName), principalID)
+	if !changed {
+		l.Info(
+			"databricks-connector: principal already does not have the role",
+			zap.String("principal_id", principalID),
+			zap.String("role", roleName),
+		)
+
+		return nil, nil
+	}
+
+	_, err = r.client.UpdateRuleSets(ctx, "", "", "", ruleSets)
+	if err != nil {
+		return nil, fmt.Errorf("databricks-connector: failed to remove role: %w", err)
+	}
+
+	return nil, nil
+}
+
+func newRoleBuilder(client *databricks.Client, pageConcurrency int) *roleBuilder {
 	return &roleBuilder{
-		client:       client,
-		resourceType: roleResourceType,
+		client:          client,
+		resourceType:    roleResourceType,
+		pageConcurrency: pageConcurrency,
+		principals:      newPrincipalSnapshots(),
 	}
 }
@@ -0,0 +1,283 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+func TestAccountRoleNames(t *testing.T) {
+	got := accountRoleNames([]databricks.Role{
+		{Name: "roles/marketplace.admin"},
+		{Name: "roles/billing.admin"},
+		{Name: "roles/billing.admin"},
+		{Name: "roles/account_admin"},
+		{Name: ""},
+	})
+
+	want := []string{AccountAdminRole, "marketplace.admin", "billing.admin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("accountRoleNames() = %v, want %v", got, want)
+	}
+}
+
+func TestAppendEntitlements(t *testing.T) {
+	got := appendEntitlements(slices.Clone(entitlements), []databricks.PermissionValue{
+		{Value: WorkspaceAccessRole},
+		{Value: "some-future-entitlement"},
+		{Value: ""},
+		{Value: "some-future-entitlement"},
+	})
+
+	want := append(slices.Clone(entitlements), "some-future-entitlement")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appendEntitlements() = %v, want %v", got, want)
+	}
+}
+
+// newWorkspaceTestClient returns a client whose account and workspace dbc-1
+// are both served by handler.
+func newWorkspaceTestClient(t *testing.T, handler http.Handler) *databricks.Client {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := databricks.NewClient(context.Background(), srv.Client(), "example.com", srv.Listener.Addr().String(), "acc-1", "", &databricks.NoAuth{}, nil,
+		databricks.WithWorkspaceURLs(map[string]string{"dbc-1": srv.URL}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	return c
+}
+
+func TestWorkspaceEntitlementsDiscovery(t *testing.T) {
+	ctx := context.Background()
+	workspace := &v2.ResourceId{ResourceType: workspaceResourceType.Id, Resource: "dbc-1"}
+
+	client := newWorkspaceTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/Users"):
+			_, _ = w.Write([]byte(`{"totalResults":1,"Resources":[{"id":"u-1","entitlements":[{"value":"user-only"}]}]}`))
+		case strings.HasSuffix(r.URL.Path, "/Groups"):
+			_, _ = w.Write([]byte(`{"totalResults":1,"Resources":[{"id":"g-1","entitlements":[{"value":"group-only"}]}]}`))
+		case strings.HasSuffix(r.URL.Path, "/ServicePrincipals"):
+			_, _ = w.Write([]byte(`{"totalResults":1,"Resources":[{"id":"sp-1","entitlements":[{"value":"sp-only"}]}]}`))
+		}
+	}))
+
+	roles, _, err := newRoleBuilder(client, 1).List(ctx, workspace, rs.SyncOpAttrs{SyncID: "sync-1"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.DisplayName)
+	}
+	for _, want := range []string{"user-only", "group-only", "sp-only", WorkspaceAccessRole} {
+		if !slices.Contains(names, want) {
+			t.Errorf("workspace roles = %v, missing %s", names, want)
+		}
+	}
+
+	denied := newWorkspaceTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"detail":"forbidden"}`))
+	}))
+	if _, _, err := newRoleBuilder(denied, 1).List(ctx, workspace, rs.SyncOpAttrs{SyncID: "sync-1"}); err == nil {
+		t.Error("expected List to fail when the credentials can't list principals")
+	}
+}
+
+func TestRoleGrantsShareOnePrincipalScanPerSync(t *testing.T) {
+	ctx := context.Background()
+
+	var scans atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/Users"):
+			scans.Add(1)
+			_, _ = w.Write([]byte(`{"totalResults":1,"Resources":[{"id":"u-1","roles":[{"value":"account_admin"}]}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"totalResults":0,"Resources":[]}`))
+		}
+	}))
+
+	b := newRoleBuilder(client, 1)
+	role, err := roleResource(ctx, AccountAdminRole, &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		grants, _, err := b.Grants(ctx, role, rs.SyncOpAttrs{SyncID: "sync-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grants) != 1 || grants[0].Principal.Id.Resource != "u-1" {
+			t.Fatalf("unexpected grants: %v", grants)
+		}
+	}
+
+	if got := scans.Load(); got != 1 {
+		t.Fatalf("expected 1 user scan, got %d", got)
+	}
+}
+
+func TestRoleGrantsPaginateAndReleaseSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	var scans atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/Users"):
+			scans.Add(1)
+			_, _ = w.Write([]byte(`{"totalResults":3,"Resources":[
+				{"id":"u-1","roles":[{"value":"account_admin"}]},
+				{"id":"u-2","roles":[{"value":"account_admin"}]},
+				{"id":"u-3","roles":[{"value":"account_admin"}]}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"totalResults":0,"Resources":[]}`))
+		}
+	}), databricks.WithPageSize(2))
+
+	b := newRoleBuilder(client, 1)
+	account := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+	attr := rs.SyncOpAttrs{SyncID: "sync-1"}
+	roles, _, err := b.List(ctx, account, attr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 || roles[0].Id.Resource != AccountAdminRole {
+		t.Fatalf("unexpected roles: %v", roles)
+	}
+
+	var got []string
+	for page := 0; ; page++ {
+		grants, res, err := b.Grants(ctx, roles[0], attr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grants) > 2 {
+			t.Fatalf("page %d has %d grants, want at most 2", page, len(grants))
+		}
+		for _, g := range grants {
+			got = append(got, g.Principal.Id.Resource)
+		}
+
+		if res.NextPageToken == "" {
+			break
+		}
+		attr.PageToken.Token = res.NextPageToken
+	}
+
+	if want := []string{"u-1", "u-2", "u-3"}; !slices.Equal(got, want) {
+		t.Errorf("grants = %v, want %v", got, want)
+	}
+	if n := scans.Load(); n != 1 {
+		t.Errorf("expected 1 user scan while paginating, got %d", n)
+	}
+
+	// Every role listed in the sync is done, so its snapshot was released.
+	if _, ok := b.principals.cached("sync-1", ""); ok {
+		t.Error("snapshot kept after the last role's grants were emitted")
+	}
+}
+
+func TestRoleGrantsWithoutSyncIDInOnePage(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/Users"):
+			_, _ = w.Write([]byte(`{"totalResults":3,"Resources":[
+				{"id":"u-1","roles":[{"value":"account_admin"}]},
+				{"id":"u-2","roles":[{"value":"account_admin"}]},
+				{"id":"u-3","roles":[{"value":"account_admin"}]}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"totalResults":0,"Resources":[]}`))
+		}
+	}), databricks.WithPageSize(2))
+
+	b := newRoleBuilder(client, 1)
+	role, err := roleResource(context.Background(), AccountAdminRole, &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a sync ID the snapshot would be reloaded for every page.
+	grants, res, err := b.Grants(context.Background(), role, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 3 || res.NextPageToken != "" {
+		t.Errorf("got %d grants and next page %q, want all 3 in one page", len(grants), res.NextPageToken)
+	}
+}
+
+func TestRoleGrantsReleaseSnapshotOnError(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/Users") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"detail":"forbidden"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"totalResults":0,"Resources":[]}`))
+	}))
+
+	b := newRoleBuilder(client, 1)
+	attr := rs.SyncOpAttrs{SyncID: "sync-1"}
+	roles, _, err := b.List(ctx, &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}, attr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := b.Grants(ctx, roles[0], attr); err == nil {
+		t.Fatal("expected an error when users can't be listed")
+	}
+
+	b.principals.mu.Lock()
+	defer b.principals.mu.Unlock()
+	if len(b.principals.pending) != 0 {
+		t.Errorf("pending roles after a failed role = %v, want none", b.principals.pending)
+	}
+}
+
+func TestPrincipalSnapshotsScopedToSync(t *testing.T) {
+	ctx := context.Background()
+	p := newPrincipalSnapshots()
+
+	var loads atomic.Int32
+	load := func(context.Context, string) (*principalSnapshot, error) {
+		loads.Add(1)
+		return &principalSnapshot{}, nil
+	}
+
+	for _, syncID := range []string{"sync-1", "sync-1", "sync-2", "", ""} {
+		if _, err := p.get(ctx, syncID, "ws-1", load); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Once per sync, and every time without a sync ID.
+	if got := loads.Load(); got != 4 {
+		t.Fatalf("expected 4 loads, got %d", got)
+	}
+}
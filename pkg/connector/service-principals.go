@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/conductorone/baton-databricks/pkg/databricks"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -19,19 +21,36 @@ import (
 type servicePrincipalBuilder struct {
 	client       *databricks.Client
 	resourceType *v2.ResourceType
+	// pageConcurrency is the number of SCIM pages List fetches at once.
+	pageConcurrency int
+	// incremental, when set, makes List re-fetch only what changed since the
+	// previous sync.
+	incremental *incrementalSync
+	// logins, when set, provides the last login of service principals.
+	logins *loginActivity
 }
 
 func (s *servicePrincipalBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 	return servicePrincipalResourceType
 }
 
-func (s *servicePrincipalBuilder) servicePrincipalResource(ctx context.Context, servicePrincipal *databricks.ServicePrincipal, parent *v2.ResourceId) (*v2.Resource, error) {
+// servicePrincipalResource builds the resource of a service principal. Service
+// principals are group resources, so a known last login goes in the profile.
+func (s *servicePrincipalBuilder) servicePrincipalResource(
+	ctx context.Context,
+	servicePrincipal *databricks.ServicePrincipal,
+	parent *v2.ResourceId,
+	lastLogin time.Time,
+) (*v2.Resource, error) {
 	profile := map[string]interface{}{
 		"application_id": servicePrincipal.ApplicationID,
 		"display_name":   servicePrincipal.DisplayName,
 		"parent_type":    parent.ResourceType,
 		"parent_id":      parent.Resource,
 	}
+	if !lastLogin.IsZero() {
+		profile["last_login"] = lastLogin.UTC().Format(time.RFC3339)
+	}
 
 	options := []rs.ResourceOption{
 		rs.WithResourceProfile(profile),
@@ -65,6 +84,8 @@ func (s *servicePrincipalBuilder) servicePrincipalResource(ctx context.Context,
 
 // List returns all the servicePrincipals from the database as resource objects.
 func (s *servicePrincipalBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
+	s.client.SetSyncID(attr.SyncID)
+
 	if parentResourceID == nil {
 		return nil, nil, nil
 	}
@@ -79,21 +100,37 @@ func (s *servicePrincipalBuilder) List(ctx context.Context, parentResourceID *v2
 		return nil, nil, fmt.Errorf("databricks-connector: failed to parse page token: %w", err)
 	}
 
-	servicePrincipals, total, _, err := s.client.ListServicePrincipals(
-		ctx,
-		workspaceId,
-		databricks.NewPaginationVars(page, ResourcesPageSize),
-		databricks.NewServicePrincipalAttrVars(),
+	list := func(ctx context.Context, start uint, vars ...databricks.Vars) ([]databricks.ServicePrincipal, uint, error) {
+		servicePrincipals, total, _, err := s.client.ListServicePrincipals(
+			ctx,
+			workspaceId,
+			append([]databricks.Vars{databricks.NewPaginationVars(start, s.client.PageSize())}, vars...)...,
+		)
+		return servicePrincipals, total, err
+	}
+
+	var (
+		servicePrincipals []databricks.ServicePrincipal
+		token             string
 	)
+	if s.incremental != nil {
+		servicePrincipals, token, err = listIncremental(ctx, s.incremental, attr.SyncID, incrementalKey(servicePrincipalResourceType.Id, workspaceId), page, s.client.PageSize(), s.pageConcurrency, list, databricks.NewServicePrincipalAttrVars())
+	} else {
+		servicePrincipals, token, err = listPages(ctx, page, s.pageConcurrency, func(ctx context.Context, start uint) ([]databricks.ServicePrincipal, uint, error) {
+			return list(ctx, start, databricks.NewServicePrincipalAttrVars())
+		})
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to list service principals: %w", err)
 	}
 
+	logins := s.logins.get(ctx, attr.SyncID)
+
 	var rv []*v2.Resource
 	for _, servicePrincipal := range servicePrincipals {
 		gCopy := servicePrincipal
 
-		gr, err := s.servicePrincipalResource(ctx, &gCopy, parentResourceID)
+		gr, err := s.servicePrincipalResource(ctx, &gCopy, parentResourceID, logins[strings.ToLower(gCopy.ApplicationID)])
 		if err != nil {
 			return nil, nil, err
 		}
@@ -101,7 +138,6 @@ func (s *servicePrincipalBuilder) List(ctx context.Context, parentResourceID *v2
 		rv = append(rv, gr)
 	}
 
-	token := prepareNextToken(page, len(servicePrincipals), total)
 	nextPage, err := bag.NextToken(token)
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
@@ -110,6 +146,32 @@ func (s *servicePrincipalBuilder) List(ctx context.Context, parentResourceID *v2
 	return rv, &rs.SyncOpResults{NextPageToken: nextPage}, nil
 }
 
+// Get returns a single service principal, for targeted syncs.
+func (s *servicePrincipalBuilder) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	parent, workspaceId, err := principalParent(s.client, parentResourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	servicePrincipal, _, err := s.client.GetServicePrincipal(ctx, workspaceId, resourceId.Resource)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("databricks-connector: failed to get service principal %s: %w", resourceId.Resource, err)
+	}
+
+	lastLogin := s.logins.lookup(ctx, servicePrincipal.ApplicationID)
+
+	rv, err := s.servicePrincipalResource(ctx, servicePrincipal, parent, lastLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rv, nil, nil
+}
+
 // Entitlements return all entitlements relevant to the servicePrincipal.
 func (s *servicePrincipalBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ rs.SyncOpAttrs) ([]*v2.Entitlement, *rs.SyncOpResults, error) {
 	var rv []*v2.Entitlement
@@ -380,9 +442,12 @@ func (s *servicePrincipalBuilder) Revoke(ctx context.Context, grant *v2.Grant) (
 	return nil, nil
 }
 
-func newServicePrincipalBuilder(client *databricks.Client) *servicePrincipalBuilder {
+func newServicePrincipalBuilder(client *databricks.Client, pageConcurrency int, incremental *incrementalSync, logins *loginActivity) *servicePrincipalBuilder {
 	return &servicePrincipalBuilder{
-		client:       client,
-		resourceType: servicePrincipalResourceType,
+		client:          client,
+		resourceType:    servicePrincipalResourceType,
+		pageConcurrency: pageConcurrency,
+		incremental:     incremental,
+		logins:          logins,
 	}
 }
@@ -0,0 +1,240 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"github.com/conductorone/baton-databricks/pkg/databricks/databrickstest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+// newFakeConnector returns a connector for the fake server, retrying quickly.
+func newFakeConnector(t *testing.T, s *databrickstest.Server, workspaces ...string) *Databricks {
+	t.Helper()
+
+	ctx := context.Background()
+	d, err := New(
+		ctx,
+		databrickstest.Hostname,
+		databrickstest.AccountHostname,
+		s.AccountID,
+		databrickstest.BaseURL,
+		s.Auth(),
+		nil,
+		workspaces,
+		WithRetryPolicy(databricks.RetryPolicy{MaxAttempts: 3, MaxWait: time.Second, BaseDelay: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return d
+}
+
+func resourceIDs(resources []*v2.Resource) []string {
+	rv := make([]string, 0, len(resources))
+	for _, r := range resources {
+		rv = append(rv, r.GetId().GetResource())
+	}
+	slices.Sort(rv)
+
+	return rv
+}
+
+func TestSyncFakeAccount(t *testing.T) {
+	s := databrickstest.NewServer(t, "acc-1")
+	ws := s.AddWorkspace(databricks.Workspace{DeploymentName: "dbc-abc"})
+	alice := s.AddUser("", databricks.User{UserName: "alice@example.com", Active: true})
+	bob := s.AddUser("", databricks.User{UserName: "bob@example.com", Active: true})
+	sp := s.AddServicePrincipal("", databricks.ServicePrincipal{ApplicationID: "app-1", DisplayName: "ci"})
+	admins := s.AddGroup("", databricks.Group{DisplayName: "admins", Members: []databricks.Member{{ID: alice.ID}}})
+	s.AddUser("dbc-abc", databricks.User{UserName: "carol@example.com"})
+	s.Assign(ws.ID, bob.ID, "USER")
+
+	d := newFakeConnector(t, s)
+	ctx := context.Background()
+	if _, err := d.Validate(ctx); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !d.client.IsAccountAPIAvailable() {
+		t.Error("expected the account API to be available")
+	}
+
+	account := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+
+	users, _, err := newUserBuilder(d.client, 1, nil, nil).List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("List users: %v", err)
+	}
+	if got, want := resourceIDs(users), []string{alice.ID, bob.ID}; !slices.Equal(got, want) {
+		t.Errorf("users = %v, want %v", got, want)
+	}
+
+	sps, _, err := newServicePrincipalBuilder(d.client, 1, nil, nil).List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("List service principals: %v", err)
+	}
+	if got := resourceIDs(sps); !slices.Equal(got, []string{sp.ID}) {
+		t.Errorf("service principals = %v, want %s", got, sp.ID)
+	}
+
+	groups := newGroupBuilder(d.client, false, 1, nil)
+	groupResources, _, err := groups.List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("List groups: %v", err)
+	}
+	if len(groupResources) != 1 {
+		t.Fatalf("groups = %v, want admins", groupResources)
+	}
+	grants, _, err := groups.Grants(ctx, groupResources[0], rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("group Grants: %v", err)
+	}
+	if len(grants) != 1 || grants[0].GetPrincipal().GetId().GetResource() != alice.ID {
+		t.Errorf("grants of %s = %v, want alice", admins.ID, grants)
+	}
+
+	workspaces := newWorkspaceBuilder(d.client, nil)
+	workspaceResources, _, err := workspaces.List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("List workspaces: %v", err)
+	}
+	if len(workspaceResources) != 1 {
+		t.Fatalf("workspaces = %v, want dbc-abc", workspaceResources)
+	}
+	grants, _, err = workspaces.Grants(ctx, workspaceResources[0], rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("workspace Grants: %v", err)
+	}
+	if len(grants) != 1 || grants[0].GetPrincipal().GetId().GetResource() != bob.ID {
+		t.Errorf("grants of dbc-abc = %v, want bob", grants)
+	}
+}
+
+func TestGrantRevokeFakeAccount(t *testing.T) {
+	s := databrickstest.NewServer(t, "acc-1")
+	ws := s.AddWorkspace(databricks.Workspace{DeploymentName: "dbc-abc"})
+	alice := s.AddUser("", databricks.User{UserName: "alice@example.com", Active: true})
+	admins := s.AddGroup("", databricks.Group{DisplayName: "admins"})
+
+	d := newFakeConnector(t, s)
+	ctx := context.Background()
+	if _, err := d.Validate(ctx); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	account := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+	principal, err := rs.NewUserResource(alice.UserName, userResourceType, alice.ID, nil, rs.WithParentResourceID(account))
+	if err != nil {
+		t.Fatalf("NewUserResource: %v", err)
+	}
+
+	groups := newGroupBuilder(d.client, false, 1, nil)
+	groupResources, _, err := groups.List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil || len(groupResources) != 1 {
+		t.Fatalf("List groups = %v, %v", groupResources, err)
+	}
+	entitlements, _, err := groups.Entitlements(ctx, groupResources[0], rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("Entitlements: %v", err)
+	}
+
+	if _, err := groups.Grant(ctx, principal, entitlements[0]); err != nil {
+		t.Fatalf("Grant group membership: %v", err)
+	}
+	if g, _ := s.Group("", admins.ID); len(g.Members) != 1 || g.Members[0].ID != alice.ID {
+		t.Errorf("members after grant = %v, want alice", g.Members)
+	}
+
+	if _, err := groups.Revoke(ctx, grant.NewGrant(groupResources[0], groupMemberEntitlement, principal)); err != nil {
+		t.Fatalf("Revoke group membership: %v", err)
+	}
+	if g, _ := s.Group("", admins.ID); len(g.Members) != 0 {
+		t.Errorf("members after revoke = %v, want none", g.Members)
+	}
+
+	workspaces := newWorkspaceBuilder(d.client, nil)
+	workspaceResources, _, err := workspaces.List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil || len(workspaceResources) != 1 {
+		t.Fatalf("List workspaces = %v, %v", workspaceResources, err)
+	}
+	entitlements, _, err = workspaces.Entitlements(ctx, workspaceResources[0], rs.SyncOpAttrs{})
+	if err != nil || len(entitlements) != 1 {
+		t.Fatalf("workspace Entitlements = %v, %v", entitlements, err)
+	}
+
+	if _, err := workspaces.Grant(ctx, principal, entitlements[0]); err != nil {
+		t.Fatalf("Grant workspace membership: %v", err)
+	}
+	if got := s.Assignments(ws.ID)[alice.ID]; !slices.Equal(got, []string{"USER"}) {
+		t.Errorf("permissions after grant = %v, want USER", got)
+	}
+
+	if _, err := workspaces.Revoke(ctx, grant.NewGrant(workspaceResources[0], workspaceMemberEntitlement, principal)); err != nil {
+		t.Fatalf("Revoke workspace membership: %v", err)
+	}
+	if got, ok := s.Assignments(ws.ID)[alice.ID]; ok {
+		t.Errorf("permissions after revoke = %v, want none", got)
+	}
+}
+
+func TestSyncFakeAccountRetriesRateLimits(t *testing.T) {
+	s := databrickstest.NewServer(t, "acc-1")
+	s.AddUser("", databricks.User{UserName: "alice@example.com", Active: true})
+	usersPath := "/api/2.0/accounts/acc-1/scim/v2/Users"
+	s.Fail(databrickstest.Fault{Method: http.MethodGet, Path: usersPath, Status: http.StatusTooManyRequests, Times: 2})
+
+	d := newFakeConnector(t, s)
+	ctx := context.Background()
+	account := &v2.ResourceId{ResourceType: accountResourceType.Id, Resource: "acc-1"}
+
+	users, _, err := newUserBuilder(d.client, 1, nil, nil).List(ctx, account, rs.SyncOpAttrs{})
+	if err != nil {
+		t.Fatalf("List users: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("users = %v, want alice", users)
+	}
+	if n := s.Requests(http.MethodGet, usersPath); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+
+	s.Fail(databrickstest.Fault{Method: http.MethodGet, Path: usersPath, Status: http.StatusServiceUnavailable, Times: 3})
+	d = newFakeConnector(t, s)
+	if _, _, err := newUserBuilder(d.client, 1, nil, nil).List(ctx, account, rs.SyncOpAttrs{}); err == nil {
+		t.Error("expected an error once retries are used up")
+	}
+}
+
+func TestValidateFakeWorkspaceOnly(t *testing.T) {
+	s := databrickstest.NewServer(t, "acc-1")
+	s.AddWorkspace(databricks.Workspace{DeploymentName: "dbc-abc"})
+	s.DisableAccountAPI()
+
+	ctx := context.Background()
+	if _, err := newFakeConnector(t, s).Validate(ctx); err == nil {
+		t.Error("expected Validate to fail without the account API or configured workspaces")
+	}
+
+	d := newFakeConnector(t, s, "dbc-abc")
+	if _, err := d.Validate(ctx); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if d.client.IsAccountAPIAvailable() || !d.client.IsWorkspaceAPIAvailable() {
+		t.Errorf("availability = account %t, workspace %t, want workspace only",
+			d.client.IsAccountAPIAvailable(), d.client.IsWorkspaceAPIAvailable())
+	}
+
+	if _, err := d.Validate(ctx); err != nil {
+		t.Errorf("Validate again: %v", err)
+	}
+	if n := s.Requests(http.MethodGet, "/api/2.0/preview/accounts/access-control/assignable-roles"); n < 1 {
+		t.Errorf("workspace role probes = %d, want at least one", n)
+	}
+}
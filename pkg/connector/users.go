@@ -3,6 +3,8 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/conductorone/baton-databricks/pkg/databricks"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -14,13 +16,21 @@ import (
 type userBuilder struct {
 	client       *databricks.Client
 	resourceType *v2.ResourceType
+	// pageConcurrency is the number of SCIM pages List fetches at once.
+	pageConcurrency int
+	// incremental, when set, makes List re-fetch only what changed since the
+	// previous sync.
+	incremental *incrementalSync
+	// logins, when set, provides the last login of users.
+	logins *loginActivity
 }
 
 func (u *userBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 	return userResourceType
 }
 
-func (u *userBuilder) userResource(ctx context.Context, user *databricks.User, parent *v2.ResourceId) (*v2.Resource, error) {
+// userResource builds the resource of a user. A zero lastLogin is unknown.
+func (u *userBuilder) userResource(ctx context.Context, user *databricks.User, parent *v2.ResourceId, lastLogin time.Time) (*v2.Resource, error) {
 	var emailOptions []rs.UserTraitOption
 	var primaryEmail string
 	for _, email := range user.Emails {
@@ -56,6 +66,9 @@ func (u *userBuilder) userResource(ctx context.Context, user *databricks.User, p
 	}
 
 	userTraitOptions = append(userTraitOptions, emailOptions...)
+	if !lastLogin.IsZero() {
+		userTraitOptions = append(userTraitOptions, rs.WithLastLogin(lastLogin))
+	}
 
 	options := []rs.ResourceOption{
 		rs.WithResourceProfile(profile),
@@ -84,6 +97,8 @@ func (u *userBuilder) userResource(ctx context.Context, user *databricks.User, p
 // List returns all the users from the database as resource objects.
 // Users include a UserTrait because they are the 'shape' of a standard user.
 func (u *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr rs.SyncOpAttrs) ([]*v2.Resource, *rs.SyncOpResults, error) {
+	u.client.SetSyncID(attr.SyncID)
+
 	if parentResourceID == nil {
 		return nil, nil, nil
 	}
@@ -98,21 +113,37 @@ func (u *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 		return nil, nil, fmt.Errorf("databricks-connector: failed to parse page token: %w", err)
 	}
 
-	users, total, _, err := u.client.ListUsers(
-		ctx,
-		workspaceId,
-		databricks.NewPaginationVars(page, ResourcesPageSize),
-		databricks.NewUserAttrVars(),
+	list := func(ctx context.Context, start uint, vars ...databricks.Vars) ([]databricks.User, uint, error) {
+		users, total, _, err := u.client.ListUsers(
+			ctx,
+			workspaceId,
+			append([]databricks.Vars{databricks.NewPaginationVars(start, u.client.PageSize())}, vars...)...,
+		)
+		return users, total, err
+	}
+
+	var (
+		users []databricks.User
+		token string
 	)
+	if u.incremental != nil {
+		users, token, err = listIncremental(ctx, u.incremental, attr.SyncID, incrementalKey(userResourceType.Id, workspaceId), page, u.client.PageSize(), u.pageConcurrency, list, databricks.NewUserAttrVars())
+	} else {
+		users, token, err = listPages(ctx, page, u.pageConcurrency, func(ctx context.Context, start uint) ([]databricks.User, uint, error) {
+			return list(ctx, start, databricks.NewUserAttrVars())
+		})
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to list users: %w", err)
 	}
 
+	logins := u.logins.get(ctx, attr.SyncID)
+
 	var rv []*v2.Resource
 	for _, user := range users {
 		uCopy := user
 
-		ur, err := u.userResource(ctx, &uCopy, parentResourceID)
+		ur, err := u.userResource(ctx, &uCopy, parentResourceID, logins[strings.ToLower(uCopy.UserName)])
 		if err != nil {
 			return nil, nil, err
 		}
@@ -120,7 +151,6 @@ func (u *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 		rv = append(rv, ur)
 	}
 
-	token := prepareNextToken(page, len(users), total)
 	nextPage, err := bag.NextToken(token)
 	if err != nil {
 		return nil, nil, fmt.Errorf("databricks-connector: failed to create next page token: %w", err)
@@ -129,6 +159,32 @@ func (u *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	return rv, &rs.SyncOpResults{NextPageToken: nextPage}, nil
 }
 
+// Get returns a single user, for targeted syncs.
+func (u *userBuilder) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	parent, workspaceId, err := principalParent(u.client, parentResourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, _, err := u.client.GetUser(ctx, workspaceId, resourceId.Resource)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("databricks-connector: failed to get user %s: %w", resourceId.Resource, err)
+	}
+
+	lastLogin := u.logins.lookup(ctx, user.UserName)
+
+	rv, err := u.userResource(ctx, user, parent, lastLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rv, nil, nil
+}
+
 // Entitlements always returns an empty slice for users.
 func (u *userBuilder) Entitlements(
 	_ context.Context,
@@ -220,7 +276,7 @@ func (o *userBuilder) CreateAccount(ctx context.Context, accountInfo *v2.Account
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("baton-databricks: failed to create resource ID for account: %w", err)
 	}
-	resource, err := o.userResource(ctx, user, parentResourceId)
+	resource, err := o.userResource(ctx, user, parentResourceId, time.Time{})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("baton-databricks: failed to create user resource: %w", err)
 	}
@@ -239,9 +295,12 @@ func (o *userBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (an
 	return nil, nil
 }
 
-func newUserBuilder(client *databricks.Client) *userBuilder {
+func newUserBuilder(client *databricks.Client, pageConcurrency int, incremental *incrementalSync, logins *loginActivity) *userBuilder {
 	return &userBuilder{
-		client:       client,
-		resourceType: userResourceType,
+		client:          client,
+		resourceType:    userResourceType,
+		pageConcurrency: pageConcurrency,
+		incremental:     incremental,
+		logins:          logins,
 	}
 }
@@ -187,6 +187,58 @@ func matchConfiguredWorkspace(configured map[string]struct{}, candidates ...stri
 	return "", false
 }
 
+// Get returns a single workspace by deployment name, for targeted syncs.
+// Workspaces that are excluded or outside the configured set aren't found.
+func (w *workspaceBuilder) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	parent := parentResourceId
+	if parent == nil {
+		var err error
+		parent, err = rs.NewResourceID(accountResourceType, w.client.GetAccountId())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if w.client.IsTokenAuth() {
+		if _, ok := matchConfiguredWorkspace(w.workspaces, resourceId.Resource); !ok || w.client.IsWorkspaceNameExcluded(resourceId.Resource) {
+			return nil, nil, nil
+		}
+
+		rv, err := minimalWorkspaceResource(ctx, &databricks.Workspace{DeploymentName: resourceId.Resource}, parent)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rv, nil, nil
+	}
+
+	workspaces, _, err := w.client.ListWorkspaces(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("databricks-connector: failed to list workspaces: %w", err)
+	}
+
+	for _, workspace := range workspaces {
+		if workspace.DeploymentName != resourceId.Resource {
+			continue
+		}
+
+		if len(w.workspaces) > 0 {
+			if _, ok := matchConfiguredWorkspace(w.workspaces, workspace.DeploymentName, workspace.Name, strconv.Itoa(workspace.ID)); !ok {
+				return nil, nil, nil
+			}
+		}
+
+		rv, err := workspaceResource(ctx, &workspace, parent)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rv, nil, nil
+	}
+
+	return nil, nil, nil
+}
+
 // Entitlements returns slice of entitlements representing workspace members.
 // To get workspace members, we can only use the account API.
 func (w *workspaceBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ rs.SyncOpAttrs) ([]*v2.Entitlement, *rs.SyncOpResults, error) {
@@ -0,0 +1,37 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+func TestWorkspaceGet(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[
+			{"workspace_id": 1, "workspace_name": "Prod", "deployment_name": "prod"},
+			{"workspace_id": 2, "workspace_name": "Dev", "deployment_name": "dev"}
+		]`))
+	}))
+
+	b := newWorkspaceBuilder(client, []string{"prod"})
+
+	resource, _, err := b.Get(ctx, &v2.ResourceId{ResourceType: workspaceResourceType.Id, Resource: "prod"}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if resource.GetDisplayName() != "Prod" || resource.GetParentResourceId().GetResource() != "acc-1" {
+		t.Fatalf("unexpected resource: %v", resource)
+	}
+
+	// dev exists but is outside the configured workspaces.
+	for _, name := range []string{"dev", "missing"} {
+		resource, _, err = b.Get(ctx, &v2.ResourceId{ResourceType: workspaceResourceType.Id, Resource: name}, nil)
+		if err != nil || resource != nil {
+			t.Fatalf("expected no resource for %s, got %v, %v", name, resource, err)
+		}
+	}
+}
@@ -2,9 +2,23 @@ package databricks
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505 -- required for the x5t certificate thumbprint.
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
@@ -34,6 +48,19 @@ func (n *NoAuth) GetClient(ctx context.Context) (*http.Client, error) {
 // token for the workspace it targets. Account-level requests match no token.
 type TokenAuth struct {
 	tokens map[string]string
+
+	// hosts maps the API hosts of workspaces whose host isn't
+	// "<deployment-name>.<hostname>", e.g. PrivateLink or custom DNS, to
+	// their deployment names.
+	hostsMu sync.RWMutex
+	hosts   map[string]string
+}
+
+// workspaceHostAuth is implemented by auths that pick credentials by the
+// workspace a request targets. The client tells them the host of every
+// workspace it resolves, so overridden and learned hosts match too.
+type workspaceHostAuth interface {
+	setWorkspaceHost(deploymentName, host string)
 }
 
 func NewTokenAuth(workspaces, tokens []string) *TokenAuth {
@@ -45,14 +72,34 @@ func NewTokenAuth(workspaces, tokens []string) *TokenAuth {
 		tokensMap[workspace] = tokens[i]
 	}
 
-	return &TokenAuth{tokens: tokensMap}
+	return &TokenAuth{tokens: tokensMap, hosts: make(map[string]string)}
+}
+
+func (t *TokenAuth) setWorkspaceHost(deploymentName, host string) {
+	t.hostsMu.Lock()
+	defer t.hostsMu.Unlock()
+
+	t.hosts[host] = deploymentName
 }
 
 func (t *TokenAuth) Apply(req *http.Request) {
+	if token := t.token(req.URL.Host); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// token returns the token of the workspace a host belongs to, if any.
+func (t *TokenAuth) token(host string) string {
+	t.hostsMu.RLock()
+	deploymentName, ok := t.hosts[host]
+	t.hostsMu.RUnlock()
+	if ok {
+		return t.tokens[deploymentName]
+	}
+
 	// A workspace request host is "<deployment-name>.<hostname>". A shorter
 	// deployment name can be a false prefix of a longer one (Azure names
 	// contain a dot, e.g. "adb-123" of "adb-123.1"), so match the longest one.
-	host := req.URL.Host
 	var bestWorkspace, bestToken string
 	for workspace, token := range t.tokens {
 		if host != workspace && !strings.HasPrefix(host, workspace+".") {
@@ -62,9 +109,8 @@ func (t *TokenAuth) Apply(req *http.Request) {
 			bestWorkspace, bestToken = workspace, token
 		}
 	}
-	if bestToken != "" {
-		req.Header.Set("Authorization", "Bearer "+bestToken)
-	}
+
+	return bestToken
 }
 
 func (t *TokenAuth) GetClient(ctx context.Context) (*http.Client, error) {
@@ -76,6 +122,75 @@ func (t *TokenAuth) GetClient(ctx context.Context) (*http.Client, error) {
 	return httpClient, nil
 }
 
+// MixedAuth sends requests for the workspaces that have a personal access
+// token with that token, and everything else (the account API and the
+// remaining workspaces) through OAuth.
+type MixedAuth struct {
+	oauth  Auth
+	tokens *TokenAuth
+}
+
+func NewMixedAuth(oauth Auth, tokens *TokenAuth) *MixedAuth {
+	return &MixedAuth{oauth: oauth, tokens: tokens}
+}
+
+func (m *MixedAuth) GetClient(ctx context.Context) (*http.Client, error) {
+	oauthClient, err := m.oauth.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenClient, err := m.tokens.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &mixedTransport{
+			tokens: m.tokens,
+			oauth:  transportOrDefault(oauthClient),
+			token:  transportOrDefault(tokenClient),
+		},
+	}, nil
+}
+
+func (m *MixedAuth) setWorkspaceHost(deploymentName, host string) {
+	m.tokens.setWorkspaceHost(deploymentName, host)
+}
+
+func (m *MixedAuth) Apply(req *http.Request) {
+	if m.tokens.token(req.URL.Host) != "" {
+		m.tokens.Apply(req)
+		return
+	}
+
+	m.oauth.Apply(req)
+}
+
+// mixedTransport keeps OAuth's transport, which overwrites the Authorization
+// header, away from requests authenticated with a personal access token.
+type mixedTransport struct {
+	tokens *TokenAuth
+	oauth  http.RoundTripper
+	token  http.RoundTripper
+}
+
+func (t *mixedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.tokens.token(req.URL.Host) != "" {
+		return t.token.RoundTrip(req)
+	}
+
+	return t.oauth.RoundTrip(req)
+}
+
+func transportOrDefault(c *http.Client) http.RoundTripper {
+	if c.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return c.Transport
+}
+
 type OAuth2 struct {
 	cfg *clientcredentials.Config
 }
@@ -101,3 +216,389 @@ func (o *OAuth2) GetClient(ctx context.Context) (*http.Client, error) {
 func (o *OAuth2) Apply(req *http.Request) {
 	// No need to set the Authorization header here, the oauth2 client does it automatically
 }
+
+// WorkspaceOAuth2 authenticates a service principal with an OAuth secret
+// against the token endpoint of the host each request targets: the account
+// endpoint for the account API, and each workspace's own /oidc/v1/token for
+// workspace APIs. This works for service principals that are federated to a
+// workspace but can't get account-level tokens. Tokens are fetched on demand
+// and cached per host.
+type WorkspaceOAuth2 struct {
+	clientId        string
+	clientSecret    string
+	accountHostname string
+	accountTokenURL string
+
+	mu      sync.Mutex
+	sources map[string]oauth2.TokenSource
+}
+
+func NewWorkspaceOAuth2(accId, clientId, clientSecret, accountHostname string) *WorkspaceOAuth2 {
+	return &WorkspaceOAuth2{
+		clientId:        clientId,
+		clientSecret:    clientSecret,
+		accountHostname: accountHostname,
+		accountTokenURL: fmt.Sprintf("https://%s/oidc/accounts/%s/v1/token", accountHostname, accId),
+		sources:         make(map[string]oauth2.TokenSource),
+	}
+}
+
+func (w *WorkspaceOAuth2) GetClient(ctx context.Context) (*http.Client, error) {
+	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient.Transport = &workspaceOAuth2Transport{auth: w, next: transportOrDefault(httpClient)}
+
+	return httpClient, nil
+}
+
+func (w *WorkspaceOAuth2) Apply(req *http.Request) {
+	// No need to set the Authorization header here, the client's transport does it
+}
+
+// workspaceOAuth2Transport sets the token for the host of each request. A
+// token that can't be fetched fails the request, rather than sending it
+// unauthenticated and reporting the API's 401 instead.
+type workspaceOAuth2Transport struct {
+	auth *WorkspaceOAuth2
+	next http.RoundTripper
+}
+
+func (t *workspaceOAuth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.auth.tokenSource(req.Context(), req.URL.Host).Token()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to get oauth token for %s: %w", req.URL.Host, err)
+	}
+
+	req = req.Clone(req.Context())
+	token.SetAuthHeader(req)
+
+	return t.next.RoundTrip(req)
+}
+
+// tokenSource returns the cached token source for a host.
+func (w *WorkspaceOAuth2) tokenSource(ctx context.Context, host string) oauth2.TokenSource {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ts, ok := w.sources[host]; ok {
+		return ts
+	}
+
+	tokenURL := w.accountTokenURL
+	if host != w.accountHostname {
+		tokenURL = fmt.Sprintf("https://%s/oidc/v1/token", host)
+	}
+
+	cfg := &clientcredentials.Config{
+		ClientID:     w.clientId,
+		ClientSecret: w.clientSecret,
+		TokenURL:     tokenURL,
+		Scopes:       []string{"all-apis"},
+	}
+
+	// The source outlives the request it was created for, so keep only the
+	// context's values (logger, http client), not its cancellation.
+	ts := cfg.TokenSource(context.WithoutCancel(ctx))
+	w.sources[host] = ts
+
+	return ts
+}
+
+const (
+	// azureDatabricksResourceID is the Entra ID application ID of the
+	// AzureDatabricks first-party app; tokens for it are accepted by both the
+	// account and workspace APIs.
+	azureDatabricksResourceID = "2ff814a6-3304-4ab8-85cb-cd0e6f879c1d"
+	azureLoginHostname        = "login.microsoftonline.com"
+	azureClientAssertionType  = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	azureClientAssertionTTL   = 10 * time.Minute
+)
+
+// AzureAD authenticates as an Entra ID service principal using the client
+// credentials flow, with either a client secret or a certificate. The oauth2
+// client refreshes the token before it expires, for account and workspace
+// hosts alike.
+type AzureAD struct {
+	cfg        *clientcredentials.Config
+	assertion  *azureClientAssertion
+	loginHost  string
+	resourceID string
+}
+
+// NewAzureADWithSecret authenticates with an Entra ID client secret.
+func NewAzureADWithSecret(tenantId, clientId, clientSecret string) *AzureAD {
+	a := &AzureAD{loginHost: azureLoginHostname, resourceID: azureDatabricksResourceID}
+	a.cfg = a.config(tenantId, clientId)
+	a.cfg.ClientSecret = clientSecret
+
+	return a
+}
+
+// NewAzureADWithCertificate authenticates with a certificate registered on the
+// Entra ID application. certificatePEM holds the certificate and its
+// unencrypted RSA private key (PKCS#1 or PKCS#8).
+func NewAzureADWithCertificate(tenantId, clientId, certificatePEM string) (*AzureAD, error) {
+	assertion, err := newAzureClientAssertion(clientId, []byte(certificatePEM))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AzureAD{loginHost: azureLoginHostname, resourceID: azureDatabricksResourceID, assertion: assertion}
+	a.cfg = a.config(tenantId, clientId)
+	a.cfg.AuthStyle = oauth2.AuthStyleInParams
+
+	return a, nil
+}
+
+func (a *AzureAD) config(tenantId, clientId string) *clientcredentials.Config {
+	return &clientcredentials.Config{
+		ClientID: clientId,
+		TokenURL: fmt.Sprintf("https://%s/%s/oauth2/v2.0/token", a.loginHost, tenantId),
+		Scopes:   []string{a.resourceID + "/.default"},
+	}
+}
+
+func (a *AzureAD) GetClient(ctx context.Context) (*http.Client, error) {
+	var ts oauth2.TokenSource
+	if a.assertion == nil {
+		ts = a.cfg.TokenSource(ctx)
+	} else {
+		ts = oauth2.ReuseTokenSource(nil, &azureCertificateTokenSource{ctx: ctx, cfg: a.cfg, assertion: a.assertion})
+	}
+
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+func (a *AzureAD) Apply(req *http.Request) {
+	// No need to set the Authorization header here, the oauth2 client does it automatically
+}
+
+// azureCertificateTokenSource requests a token with a freshly signed client
+// assertion, since each assertion is only valid for a few minutes.
+type azureCertificateTokenSource struct {
+	ctx       context.Context
+	cfg       *clientcredentials.Config
+	assertion *azureClientAssertion
+}
+
+func (s *azureCertificateTokenSource) Token() (*oauth2.Token, error) {
+	signed, err := s.assertion.sign(s.cfg.TokenURL, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *s.cfg
+	cfg.EndpointParams = url.Values{
+		"client_assertion_type": {azureClientAssertionType},
+		"client_assertion":      {signed},
+	}
+
+	return cfg.Token(s.ctx)
+}
+
+// azureClientAssertion signs the JWT client assertions Entra ID accepts in
+// place of a client secret.
+type azureClientAssertion struct {
+	clientId   string
+	thumbprint string
+	key        crypto.Signer
+}
+
+func newAzureClientAssertion(clientId string, certificatePEM []byte) (*azureClientAssertion, error) {
+	var cert *x509.Certificate
+	var key crypto.Signer
+
+	for block, rest := pem.Decode(certificatePEM); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if cert != nil {
+				continue
+			}
+
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse azure client certificate: %w", err)
+			}
+			cert = c
+		case "PRIVATE KEY", "RSA PRIVATE KEY":
+			k, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse azure client certificate private key: %w", err)
+			}
+			key = k
+		}
+	}
+
+	if cert == nil {
+		return nil, fmt.Errorf("azure client certificate PEM has no certificate")
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("azure client certificate PEM has no private key")
+	}
+
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		return nil, fmt.Errorf("azure client certificate private key must be RSA")
+	}
+
+	thumbprint := sha1.Sum(cert.Raw) // #nosec G401 -- x5t is defined as the SHA-1 thumbprint.
+
+	return &azureClientAssertion{
+		clientId:   clientId,
+		thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		key:        key,
+	}, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+
+		return signer, nil
+	}
+}
+
+// sign returns an RS256 client assertion for the given token endpoint.
+func (a *azureClientAssertion) sign(audience string, now time.Time) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": a.thumbprint,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": audience,
+		"iss": a.clientId,
+		"sub": a.clientId,
+		"jti": hex.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(azureClientAssertionTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign azure client assertion: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+	// federatedTokenEarlyExpiry re-exchanges the federated token well before
+	// the Databricks token expires, so requests never race its expiry.
+	federatedTokenEarlyExpiry = 5 * time.Minute
+)
+
+// OIDCFederation exchanges a federated OIDC token issued to the workload (a
+// Kubernetes service account token, a GitHub Actions ID token, ...) for a
+// Databricks OAuth token through the account token-exchange endpoint, so no
+// Databricks secret is needed. The subject token is read again on every
+// exchange, since workload identity providers rotate it.
+type OIDCFederation struct {
+	cfg          *clientcredentials.Config
+	subjectToken func() (string, error)
+}
+
+// NewOIDCFederation reads the subject token from tokenFile or, if that's
+// empty, from the tokenEnv environment variable. clientId is the application
+// ID of the service principal with the federation policy; leave it empty to
+// use an account-wide federation policy.
+func NewOIDCFederation(accId, clientId, accountHostname, tokenFile, tokenEnv string) *OIDCFederation {
+	return &OIDCFederation{
+		cfg: &clientcredentials.Config{
+			ClientID:  clientId,
+			TokenURL:  fmt.Sprintf("https://%s/oidc/accounts/%s/v1/token", accountHostname, accId),
+			Scopes:    []string{"all-apis"},
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		subjectToken: func() (string, error) {
+			return readSubjectToken(tokenFile, tokenEnv)
+		},
+	}
+}
+
+func readSubjectToken(tokenFile, tokenEnv string) (string, error) {
+	var token string
+	if tokenFile != "" {
+		raw, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read federated token file: %w", err)
+		}
+		token = string(raw)
+	} else {
+		token = os.Getenv(tokenEnv)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("federated token is empty")
+	}
+
+	return token, nil
+}
+
+func (o *OIDCFederation) GetClient(ctx context.Context) (*http.Client, error) {
+	ts := oauth2.ReuseTokenSourceWithExpiry(nil, &federatedTokenSource{ctx: ctx, cfg: o.cfg, subjectToken: o.subjectToken}, federatedTokenEarlyExpiry)
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+func (o *OIDCFederation) Apply(req *http.Request) {
+	// No need to set the Authorization header here, the oauth2 client does it automatically
+}
+
+// federatedTokenSource performs a token exchange with the current subject token.
+type federatedTokenSource struct {
+	ctx          context.Context
+	cfg          *clientcredentials.Config
+	subjectToken func() (string, error)
+}
+
+func (s *federatedTokenSource) Token() (*oauth2.Token, error) {
+	subjectToken, err := s.subjectToken()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *s.cfg
+	cfg.EndpointParams = url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {jwtTokenType},
+	}
+
+	return cfg.Token(s.ctx)
+}
@@ -1,9 +1,31 @@
 package databricks
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
 )
 
 func mustURL(t *testing.T, raw string) *url.URL {
@@ -74,6 +96,33 @@ func TestTokenAuthApplyNestedDottedPrefix(t *testing.T) {
 	}
 }
 
+// A workspace whose URL is overridden, e.g. for PrivateLink, gets its token
+// at that host too.
+func TestTokenAuthWorkspaceURLOverride(t *testing.T) {
+	var got string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"Resources": [], "totalResults": 0}`))
+	}))
+	defer srv.Close()
+
+	auth := NewTokenAuth([]string{"dbc-pl"}, []string{"pl-token"})
+	c, err := NewClient(context.Background(), srv.Client(), "cloud.databricks.com", "accounts.cloud.databricks.com", "acc-1", "", auth, nil,
+		WithWorkspaceURLs(map[string]string{"dbc-pl": srv.URL}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if _, _, _, err := c.ListUsers(context.Background(), "dbc-pl"); err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if got != "Bearer pl-token" {
+		t.Errorf("Authorization = %q, want the workspace's token", got)
+	}
+}
+
 // Fewer tokens than workspaces must not panic; unmatched workspaces just get no token.
 func TestNewTokenAuthFewerTokensThanWorkspaces(t *testing.T) {
 	auth := NewTokenAuth([]string{"dbc-1", "dbc-2"}, []string{"token-1"})
@@ -85,3 +134,349 @@ func TestNewTokenAuthFewerTokensThanWorkspaces(t *testing.T) {
 		t.Fatalf("Authorization = %q, want empty", got)
 	}
 }
+
+func TestAzureADWithCertificate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "baton-databricks"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	var tokenURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant-1/oauth2/v2.0/token":
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("ParseForm: %v", err)
+			}
+			if got := r.PostForm.Get("scope"); got != azureDatabricksResourceID+"/.default" {
+				t.Errorf("scope = %q", got)
+			}
+			if got := r.PostForm.Get("client_assertion_type"); got != azureClientAssertionType {
+				t.Errorf("client_assertion_type = %q", got)
+			}
+			verifyAssertion(t, r.PostForm.Get("client_assertion"), &key.PublicKey, der, tokenURL)
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "aad-token", "token_type": "Bearer", "expires_in": 3600}`))
+		default:
+			if got := r.Header.Get("Authorization"); got != "Bearer aad-token" {
+				t.Errorf("Authorization = %q", got)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	auth, err := NewAzureADWithCertificate("tenant-1", "client-1", certPEM)
+	if err != nil {
+		t.Fatalf("NewAzureADWithCertificate: %v", err)
+	}
+	tokenURL = srv.URL + "/tenant-1/oauth2/v2.0/token"
+	auth.cfg.TokenURL = tokenURL
+
+	client, err := auth.GetClient(context.Background())
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+
+	resp, err := client.Get(srv.URL + "/api/2.0/preview/scim/v2/Users")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func verifyAssertion(t *testing.T, assertion string, key *rsa.PublicKey, certDER []byte, audience string) {
+	t.Helper()
+
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("assertion has %d parts", len(parts))
+	}
+
+	var header map[string]string
+	decodeSegment(t, parts[0], &header)
+	thumbprint := sha1.Sum(certDER) // #nosec G401
+	if header["alg"] != "RS256" || header["x5t"] != base64.RawURLEncoding.EncodeToString(thumbprint[:]) {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	var claims map[string]interface{}
+	decodeSegment(t, parts[1], &claims)
+	if claims["aud"] != audience || claims["iss"] != "client-1" || claims["sub"] != "client-1" {
+		t.Errorf("unexpected claims %v", claims)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid assertion signature: %v", err)
+	}
+}
+
+func decodeSegment(t *testing.T, segment string, v interface{}) {
+	t.Helper()
+
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		t.Fatalf("decode segment: %v", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatalf("unmarshal segment: %v", err)
+	}
+}
+
+func TestNewAzureADWithCertificateRejectsIncompletePEM(t *testing.T) {
+	if _, err := NewAzureADWithCertificate("tenant-1", "client-1", "not a pem"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestOIDCFederationExchangesCurrentSubjectToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("jwt-1\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var exchanges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oidc/accounts/acc-1/v1/token" {
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != tokenExchangeGrantType {
+			t.Errorf("grant_type = %q", got)
+		}
+		if got := r.PostForm.Get("subject_token_type"); got != jwtTokenType {
+			t.Errorf("subject_token_type = %q", got)
+		}
+		if got := r.PostForm.Get("client_id"); got != "sp-app-id" {
+			t.Errorf("client_id = %q", got)
+		}
+		exchanges = append(exchanges, r.PostForm.Get("subject_token"))
+
+		// Expires within the early-expiry window, so every request re-exchanges.
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "dbx-%d", "token_type": "Bearer", "expires_in": 60}`, len(exchanges))
+	}))
+	defer srv.Close()
+
+	auth := NewOIDCFederation("acc-1", "sp-app-id", "unused", tokenFile, "")
+	auth.cfg.TokenURL = srv.URL + "/oidc/accounts/acc-1/v1/token"
+
+	client, err := auth.GetClient(context.Background())
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+
+	get := func() string {
+		resp, err := client.Get(srv.URL + "/api/2.0/accounts/acc-1/workspaces")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if got := get(); got != "Bearer dbx-1" {
+		t.Errorf("Authorization = %q, want Bearer dbx-1", got)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("jwt-2"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if got := get(); got != "Bearer dbx-2" {
+		t.Errorf("Authorization = %q, want Bearer dbx-2", got)
+	}
+
+	if !reflect.DeepEqual(exchanges, []string{"jwt-1", "jwt-2"}) {
+		t.Errorf("exchanged subject tokens = %v, want [jwt-1 jwt-2]", exchanges)
+	}
+}
+
+func TestReadSubjectTokenFromEnv(t *testing.T) {
+	t.Setenv("BATON_TEST_OIDC_TOKEN", " jwt-env ")
+
+	got, err := readSubjectToken("", "BATON_TEST_OIDC_TOKEN")
+	if err != nil || got != "jwt-env" {
+		t.Errorf("readSubjectToken() = %q, %v", got, err)
+	}
+
+	if _, err := readSubjectToken("", "BATON_TEST_OIDC_TOKEN_UNSET"); err == nil {
+		t.Error("expected error for an empty token")
+	}
+}
+
+func TestWorkspaceOAuth2TokenPerHost(t *testing.T) {
+	newTokenServer := func(path, token string, calls *int) *httptest.Server {
+		return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != path {
+				t.Errorf("unexpected token path %q", r.URL.Path)
+			}
+			*calls++
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"access_token": %q, "token_type": "Bearer", "expires_in": 3600}`, token)
+		}))
+	}
+
+	var accountCalls, workspaceCalls int
+	account := newTokenServer("/oidc/accounts/acc-1/v1/token", "account-token", &accountCalls)
+	defer account.Close()
+	workspace := newTokenServer("/oidc/v1/token", "workspace-token", &workspaceCalls)
+	defer workspace.Close()
+
+	accountHost := account.Listener.Addr().String()
+	workspaceHost := workspace.Listener.Addr().String()
+
+	// Both servers share a test CA, so either client trusts both.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, account.Client())
+	var authorization string
+	transport := &workspaceOAuth2Transport{
+		auth: NewWorkspaceOAuth2("acc-1", "client", "secret", accountHost),
+		next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+	}
+
+	for i := 0; i < 2; i++ {
+		for host, want := range map[string]string{accountHost: "account-token", workspaceHost: "workspace-token"} {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/api/2.0/preview/scim/v2/Users", nil)
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+
+			if _, err := transport.RoundTrip(req); err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			if authorization != "Bearer "+want {
+				t.Errorf("%s: Authorization = %q, want %q", host, authorization, "Bearer "+want)
+			}
+		}
+	}
+
+	if accountCalls != 1 || workspaceCalls != 1 {
+		t.Errorf("expected one token request per host, got %d account and %d workspace", accountCalls, workspaceCalls)
+	}
+}
+
+func TestWorkspaceOAuth2TokenFailure(t *testing.T) {
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid_client"}`))
+	}))
+	defer tokenServer.Close()
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, tokenServer.Client())
+	sent := false
+	transport := &workspaceOAuth2Transport{
+		auth: NewWorkspaceOAuth2("acc-1", "client", "secret", tokenServer.Listener.Addr().String()),
+		next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			sent = true
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+tokenServer.Listener.Addr().String()+"/api/2.0/accounts/acc-1/workspaces", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	if _, err := transport.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "oauth token") {
+		t.Errorf("RoundTrip = %v, want the token error", err)
+	}
+	if sent {
+		t.Error("request was sent without a token")
+	}
+}
+
+// headerAuth sets a fixed bearer token in Apply.
+type headerAuth struct {
+	NoAuth
+	token string
+}
+
+func (h *headerAuth) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+h.token)
+}
+
+// roundTripperFunc records which transport a request was routed to.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestMixedAuthRoutesByHost(t *testing.T) {
+	tokens := NewTokenAuth([]string{"dbc-pat"}, []string{"pat-1"})
+	auth := NewMixedAuth(&headerAuth{token: "oauth"}, tokens)
+
+	var routed string
+	transport := &mixedTransport{
+		tokens: tokens,
+		oauth: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			routed = "oauth"
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		token: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			routed = "token"
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+	}
+
+	cases := []struct {
+		host       string
+		wantHeader string
+		wantRoute  string
+	}{
+		{"dbc-pat.cloud.databricks.com", "Bearer pat-1", "token"},
+		{"dbc-other.cloud.databricks.com", "Bearer oauth", "oauth"},
+		{"accounts.cloud.databricks.com", "Bearer oauth", "oauth"},
+		{"pat.privatelink.example.com", "Bearer pat-1", "token"},
+	}
+
+	// The PAT workspace is reached at a custom host.
+	if _, err := NewClient(context.Background(), &http.Client{}, "cloud.databricks.com", "accounts.cloud.databricks.com", "acc-1", "", auth, nil,
+		WithWorkspaceURLs(map[string]string{"dbc-pat": "https://pat.privatelink.example.com"}),
+	); err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	for _, tc := range cases {
+		req := &http.Request{URL: mustURL(t, "https://"+tc.host+"/api"), Header: http.Header{}}
+		auth.Apply(req)
+		if got := req.Header.Get("Authorization"); got != tc.wantHeader {
+			t.Errorf("%s: Authorization = %q, want %q", tc.host, got, tc.wantHeader)
+		}
+
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip: %v", err)
+		}
+		if routed != tc.wantRoute {
+			t.Errorf("%s: routed to %s transport, want %s", tc.host, routed, tc.wantRoute)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
@@ -35,6 +36,13 @@ const (
 
 	accountWorkspacesEndpoint           = "/api/2.0/accounts/%s/workspaces"
 	accountWorkspaceAssignmentsEndpoint = "/api/2.0/accounts/%s/workspaces/%s/permissionassignments"
+
+	// The account console reads its single sign-on configuration from these
+	// endpoints. They aren't part of the published REST API reference, so
+	// accounts may not serve them; callers treat a 404 as the settings being
+	// unavailable rather than as SSO being off.
+	accountSSOSettingsEndpoint     = "/api/2.0/accounts/%s/sso"
+	accountEmergencyAccessEndpoint = "/api/2.0/accounts/%s/sso/emergency-access"
 )
 
 type Client struct {
@@ -46,6 +54,17 @@ type Client struct {
 	accountId         string
 	excludeWorkspaces map[string]struct{}
 
+	// workspaceHosts maps deployment names to API hosts: overrides from
+	// WithWorkspaceURLs, plus hosts learned from ListWorkspaces.
+	workspaceHostsMu sync.RWMutex
+	workspaceHosts   map[string]string
+	workspaceURLs    map[string]string
+	baseUrlOverride  bool
+	retryPolicy      RetryPolicy
+	rateLimiter      *rateLimiter
+	lookups          *lookupCache
+	pageSize         uint
+
 	isAccAPIAvailable bool
 	isWSAPIAvailable  bool
 }
@@ -59,7 +78,44 @@ func GetAccountHostname(hostname string) string {
 	return "accounts." + hostname
 }
 
-func NewClient(ctx context.Context, httpClient *http.Client, hostname, accountHostname, accountID, baseURL string, auth Auth, excludeWorkspaces []string) (*Client, error) {
+// ClientOption configures optional client behavior.
+type ClientOption func(*Client)
+
+// WithWorkspaceURLs sets the URL of workspaces, by deployment name, whose host
+// can't be derived from the workspace list, e.g. PrivateLink or custom DNS.
+func WithWorkspaceURLs(urls map[string]string) ClientOption {
+	return func(c *Client) {
+		for deploymentName, u := range urls {
+			c.workspaceURLs[deploymentName] = normalizeHost(u)
+		}
+	}
+}
+
+// DefaultPageSize is the number of SCIM resources requested per page unless
+// WithPageSize sets another.
+const DefaultPageSize uint = 50
+
+// WithPageSize sets the number of SCIM resources requested per page. A size of
+// 0 keeps the default.
+func WithPageSize(size uint) ClientOption {
+	return func(c *Client) {
+		if size > 0 {
+			c.pageSize = size
+		}
+	}
+}
+
+func NewClient(
+	ctx context.Context,
+	httpClient *http.Client,
+	hostname,
+	accountHostname,
+	accountID,
+	baseURL string,
+	auth Auth,
+	excludeWorkspaces []string,
+	opts ...ClientOption,
+) (*Client, error) {
 	var baseUrl *url.URL
 	var err error
 
@@ -91,14 +147,37 @@ func NewClient(ctx context.Context, httpClient *http.Client, hostname, accountHo
 	}
 
 	cli, err := uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
-	return &Client{
+	c := &Client{
 		httpClient:        cli,
 		auth:              auth,
 		accountId:         accountID,
 		accountBaseUrl:    accountBaseUrl,
 		baseUrl:           baseUrl,
+		baseUrlOverride:   baseURL != "",
 		excludeWorkspaces: excludeSet,
-	}, err
+		workspaceHosts:    make(map[string]string),
+		workspaceURLs:     make(map[string]string),
+		retryPolicy:       DefaultRetryPolicy,
+		pageSize:          DefaultPageSize,
+		rateLimiter:       newRateLimiter(),
+		lookups:           newLookupCache(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for deploymentName, host := range c.workspaceURLs {
+		c.setAuthWorkspaceHost(deploymentName, host)
+	}
+
+	return c, err
+}
+
+// setAuthWorkspaceHost tells an auth that picks credentials by workspace the
+// API host of a workspace.
+func (c *Client) setAuthWorkspaceHost(deploymentName, host string) {
+	if a, ok := c.auth.(workspaceHostAuth); ok {
+		a.setWorkspaceHost(deploymentName, host)
+	}
 }
 
 // isWorkspaceExcluded case-insensitively matches w against the exclude set (name,
@@ -130,10 +209,44 @@ func (c *Client) IsWorkspaceNameExcluded(deploymentName string) bool {
 	return ok
 }
 
+// workspaceUrl returns the API URL of a workspace by deployment name: its
+// configured URL, else the host learned from ListWorkspaces, else the
+// deployment name under the configured hostname.
 func (c *Client) workspaceUrl(workspaceId string) *url.URL {
+	c.workspaceHostsMu.RLock()
+	defer c.workspaceHostsMu.RUnlock()
+
+	host, ok := c.workspaceURLs[workspaceId]
+	if !ok {
+		host, ok = c.workspaceHosts[workspaceId]
+	}
+	if !ok {
+		host = workspaceId + "." + c.baseUrl.Host
+	}
+
 	return &url.URL{
 		Scheme: "https",
-		Host:   workspaceId + "." + c.baseUrl.Host,
+		Host:   host,
+	}
+}
+
+// learnWorkspaceHosts records the API host of each listed workspace. With a
+// base URL override (for testing) the hosts stay under that URL.
+func (c *Client) learnWorkspaceHosts(workspaces []Workspace) {
+	if c.baseUrlOverride {
+		return
+	}
+
+	c.workspaceHostsMu.Lock()
+	defer c.workspaceHostsMu.Unlock()
+
+	for _, w := range workspaces {
+		if w.DeploymentName == "" {
+			continue
+		}
+		host := w.Host(c.baseUrl.Host)
+		c.workspaceHosts[w.DeploymentName] = host
+		c.setAuthWorkspaceHost(w.DeploymentName, host)
 	}
 }
 
@@ -155,6 +268,11 @@ func (c *Client) IsTokenAuth() bool {
 	return ok
 }
 
+// PageSize returns the number of SCIM resources to request per page.
+func (c *Client) PageSize() uint {
+	return c.pageSize
+}
+
 func (c *Client) UpdateEtag(etag string) {
 	c.etag = etag
 }
@@ -191,6 +309,8 @@ func (c *Client) ListUsers(
 		return nil, 0, ratelimitData, err
 	}
 
+	c.lookups.addUsers(workspaceId, res.Resources)
+
 	return res.Resources, res.Total, ratelimitData, nil
 }
 
@@ -239,11 +359,15 @@ func (c *Client) FindUserID(
 	*v2.RateLimitDescription,
 	error,
 ) {
+	if v, ok := c.lookups.get(ctx, lookupUserID, workspaceId, username); ok {
+		return v, nil, nil
+	}
+
 	users, _, ratelimitData, err := c.ListUsers(
 		ctx,
 		workspaceId,
 		&PaginationVars{Count: 1},
-		NewFilterVars(fmt.Sprintf("userName eq '%s'", username)),
+		NewFilterVars(Eq("userName", username)),
 	)
 
 	if err != nil {
@@ -266,11 +390,15 @@ func (c *Client) FindUsername(
 	*v2.RateLimitDescription,
 	error,
 ) {
+	if v, ok := c.lookups.get(ctx, lookupUsername, workspaceId, userID); ok {
+		return v, nil, nil
+	}
+
 	users, _, ratelimitData, err := c.ListUsers(
 		ctx,
 		workspaceId,
 		&PaginationVars{Count: 1},
-		NewFilterVars(fmt.Sprintf("id eq '%s'", userID)),
+		NewFilterVars(Eq("id", userID)),
 	)
 
 	if err != nil {
@@ -307,6 +435,8 @@ func (c *Client) ListGroups(
 		return nil, 0, ratelimitData, err
 	}
 
+	c.lookups.addGroups(workspaceId, res.Resources)
+
 	return res.Resources, res.Total, ratelimitData, nil
 }
 
@@ -351,11 +481,15 @@ func (c *Client) FindGroupID(
 	*v2.RateLimitDescription,
 	error,
 ) {
+	if v, ok := c.lookups.get(ctx, lookupGroupID, workspaceId, displayName); ok {
+		return v, nil, nil
+	}
+
 	groups, _, ratelimitData, err := c.ListGroups(
 		ctx,
 		workspaceId,
 		&PaginationVars{Count: 1},
-		NewFilterVars(fmt.Sprintf("displayName eq '%s'", displayName)),
+		NewFilterVars(Eq("displayName", displayName)),
 	)
 
 	if err != nil {
@@ -378,11 +512,15 @@ func (c *Client) FindGroupDisplayName(
 	*v2.RateLimitDescription,
 	error,
 ) {
+	if v, ok := c.lookups.get(ctx, lookupGroupDisplayName, workspaceId, groupID); ok {
+		return v, nil, nil
+	}
+
 	groups, _, ratelimitData, err := c.ListGroups(
 		ctx,
 		workspaceId,
 		&PaginationVars{Count: 1},
-		NewFilterVars(fmt.Sprintf("id eq '%s'", groupID)),
+		NewFilterVars(Eq("id", groupID)),
 	)
 
 	if err != nil {
@@ -419,6 +557,8 @@ func (c *Client) ListServicePrincipals(
 		return nil, 0, ratelimitData, err
 	}
 
+	c.lookups.addServicePrincipals(workspaceId, res.Resources)
+
 	return res.Resources, res.Total, ratelimitData, nil
 }
 
@@ -469,11 +609,15 @@ func (c *Client) FindServicePrincipalID(
 	*v2.RateLimitDescription,
 	error,
 ) {
+	if v, ok := c.lookups.get(ctx, lookupServicePrincipalID, workspaceId, appID); ok {
+		return v, nil, nil
+	}
+
 	servicePrincipals, _, ratelimitData, err := c.ListServicePrincipals(
 		ctx,
 		workspaceId,
 		&PaginationVars{Count: 1},
-		NewFilterVars(fmt.Sprintf("applicationId eq '%s'", appID)),
+		NewFilterVars(Eq("applicationId", appID)),
 	)
 
 	if err != nil {
@@ -496,11 +640,15 @@ func (c *Client) FindServicePrincipalAppID(
 	*v2.RateLimitDescription,
 	error,
 ) {
+	if v, ok := c.lookups.get(ctx, lookupServicePrincipalAppID, workspaceId, servicePrincipalID); ok {
+		return v, nil, nil
+	}
+
 	servicePrincipals, _, ratelimitData, err := c.ListServicePrincipals(
 		ctx,
 		workspaceId,
 		&PaginationVars{Count: 1},
-		NewFilterVars(fmt.Sprintf("id eq '%s'", servicePrincipalID)),
+		NewFilterVars(Eq("id", servicePrincipalID)),
 	)
 
 	if err != nil {
@@ -565,6 +713,8 @@ func (c *Client) ListWorkspaces(
 		return nil, ratelimitData, err
 	}
 
+	c.learnWorkspaceHosts(res)
+
 	if len(c.excludeWorkspaces) == 0 {
 		return res, ratelimitData, nil
 	}
@@ -616,6 +766,47 @@ func (c *Client) ListWorkspaceMembers(
 	return res.Assignments, ratelimitData, nil
 }
 
+// GetSSOSettings returns the account console single sign-on configuration.
+func (c *Client) GetSSOSettings(
+	ctx context.Context,
+) (
+	*SSOSettings,
+	*v2.RateLimitDescription,
+	error,
+) {
+	var res SSOSettings
+
+	u := c.accountBaseUrl.JoinPath(fmt.Sprintf(accountSSOSettingsEndpoint, c.accountId))
+	ratelimitData, err := c.Get(ctx, u, &res)
+	if err != nil {
+		return nil, ratelimitData, err
+	}
+
+	return &res, ratelimitData, nil
+}
+
+// ListEmergencyAccessUsers returns the usernames of the account users allowed
+// to log in to the account console with a password while SSO is enabled.
+func (c *Client) ListEmergencyAccessUsers(
+	ctx context.Context,
+) (
+	[]string,
+	*v2.RateLimitDescription,
+	error,
+) {
+	var res struct {
+		Users []string `json:"users"`
+	}
+
+	u := c.accountBaseUrl.JoinPath(fmt.Sprintf(accountEmergencyAccessEndpoint, c.accountId))
+	ratelimitData, err := c.Get(ctx, u, &res)
+	if err != nil {
+		return nil, ratelimitData, err
+	}
+
+	return res.Users, ratelimitData, nil
+}
+
 func (c *Client) CreateOrUpdateWorkspaceMember(
 	ctx context.Context,
 	workspaceId string,
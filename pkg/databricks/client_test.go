@@ -63,3 +63,35 @@ func TestIsWorkspaceExcludedReturnsEveryMatchedKey(t *testing.T) {
 		}
 	}
 }
+
+func TestWorkspaceUrl(t *testing.T) {
+	c, err := NewClient(
+		context.Background(), &http.Client{}, "cloud.databricks.com", "accounts.cloud.databricks.com", "acc-1", "", nil, nil,
+		WithWorkspaceURLs(map[string]string{"dbc-private": "https://dbc-private.privatelink.example.com/"}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	c.learnWorkspaceHosts([]Workspace{
+		{DeploymentName: "dbc-aws", Cloud: "aws"},
+		{DeploymentName: "adb-123.4", Cloud: "azure"},
+		{DeploymentName: "123.4", Cloud: "gcp"},
+		{DeploymentName: "dbc-custom", WorkspaceURL: "https://custom.example.com"},
+		{DeploymentName: "dbc-private", Cloud: "aws"},
+	})
+
+	tests := map[string]string{
+		"dbc-aws":     "dbc-aws.cloud.databricks.com",
+		"adb-123.4":   "adb-123.4.azuredatabricks.net",
+		"123.4":       "123.4.gcp.databricks.com",
+		"dbc-custom":  "custom.example.com",
+		"dbc-private": "dbc-private.privatelink.example.com",
+		"dbc-unknown": "dbc-unknown.cloud.databricks.com",
+	}
+	for deploymentName, want := range tests {
+		if got := c.workspaceUrl(deploymentName).Host; got != want {
+			t.Errorf("workspaceUrl(%q).Host = %q, want %q", deploymentName, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package databricks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ConfigFileEnv overrides the location of the Databricks CLI config file.
+	ConfigFileEnv     = "DATABRICKS_CONFIG_FILE"
+	defaultConfigFile = ".databrickscfg"
+)
+
+// ConfigProfile is the subset of a Databricks CLI config profile the connector
+// can authenticate with.
+type ConfigProfile struct {
+	Name         string
+	Host         string
+	AccountID    string
+	ClientID     string
+	ClientSecret string
+	Token        string
+}
+
+// IsAccountProfile reports whether the profile targets the account console
+// rather than a single workspace.
+func (p *ConfigProfile) IsAccountProfile() bool {
+	return strings.HasPrefix(p.Host, "accounts.")
+}
+
+// ConfigFilePath returns the path of the Databricks CLI config file:
+// $DATABRICKS_CONFIG_FILE if set, ~/.databrickscfg otherwise.
+func ConfigFilePath() (string, error) {
+	path := os.Getenv(ConfigFileEnv)
+	if path != "" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+
+	if path != "" {
+		return filepath.Join(home, strings.TrimPrefix(path, "~/")), nil
+	}
+
+	return filepath.Join(home, defaultConfigFile), nil
+}
+
+// LoadConfigProfile reads the named profile from the Databricks CLI config file.
+func LoadConfigProfile(profile string) (*ConfigProfile, error) {
+	path, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open databricks config file: %w", err)
+	}
+	defer f.Close()
+
+	p, err := ParseConfigProfile(f, profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+// ParseConfigProfile reads the named profile from an INI-formatted Databricks
+// CLI config. Keys are matched case-insensitively and unknown keys are ignored.
+func ParseConfigProfile(r io.Reader, profile string) (*ConfigProfile, error) {
+	var (
+		p       *ConfigProfile
+		section string
+	)
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header %q", lineNo, line)
+			}
+
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == profile && p == nil {
+				p = &ConfigProfile{Name: profile}
+			}
+			continue
+		}
+
+		if section != profile {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "host":
+			p.Host = normalizeHost(value)
+		case "account_id":
+			p.AccountID = value
+		case "client_id":
+			p.ClientID = value
+		case "client_secret":
+			p.ClientSecret = value
+		case "token":
+			p.Token = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		return nil, fmt.Errorf("profile %q not found", profile)
+	}
+
+	if p.Host == "" {
+		return nil, fmt.Errorf("profile %q has no host", profile)
+	}
+
+	if p.Token == "" && (p.ClientID == "" || p.ClientSecret == "") {
+		return nil, fmt.Errorf("profile %q needs a token or a client_id and client_secret", profile)
+	}
+
+	return p, nil
+}
+
+// normalizeHost strips the scheme and any path from a profile host.
+func normalizeHost(host string) string {
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host, _, _ = strings.Cut(host, "/")
+
+	return host
+}
+
+// SplitWorkspaceHost splits a workspace host into its deployment name and the
+// Databricks hostname, e.g. "adb-123.4.azuredatabricks.net" into "adb-123.4"
+// and "azuredatabricks.net". Unknown domains split at the first dot.
+func SplitWorkspaceHost(host string) (string, string) {
+	for _, known := range []string{defaultHost, azureHost, gcpHost} {
+		if deployment, ok := strings.CutSuffix(host, "."+known); ok {
+			return deployment, known
+		}
+	}
+
+	deployment, hostname, _ := strings.Cut(host, ".")
+	return deployment, hostname
+}
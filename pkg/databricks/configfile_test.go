@@ -0,0 +1,101 @@
+package databricks
+
+import (
+	"strings"
+	"testing"
+)
+
+const testConfigFile = `
+; Databricks CLI profiles
+[DEFAULT]
+host  = https://dbc-a1b2c3.cloud.databricks.com/
+token = dapi123
+
+[account]
+host          = https://accounts.cloud.databricks.com
+ACCOUNT_ID    = acc-1
+client_id     = sp-client
+client_secret = sp-secret
+# trailing comment
+
+[no-credentials]
+host = https://accounts.cloud.databricks.com
+`
+
+func TestParseConfigProfile(t *testing.T) {
+	p, err := ParseConfigProfile(strings.NewReader(testConfigFile), "account")
+	if err != nil {
+		t.Fatalf("ParseConfigProfile: %v", err)
+	}
+
+	want := ConfigProfile{
+		Name:         "account",
+		Host:         "accounts.cloud.databricks.com",
+		AccountID:    "acc-1",
+		ClientID:     "sp-client",
+		ClientSecret: "sp-secret",
+	}
+	if *p != want {
+		t.Errorf("got %+v, want %+v", *p, want)
+	}
+	if !p.IsAccountProfile() {
+		t.Error("expected an account profile")
+	}
+
+	p, err = ParseConfigProfile(strings.NewReader(testConfigFile), "DEFAULT")
+	if err != nil {
+		t.Fatalf("ParseConfigProfile: %v", err)
+	}
+	if p.Host != "dbc-a1b2c3.cloud.databricks.com" || p.Token != "dapi123" || p.IsAccountProfile() {
+		t.Errorf("unexpected DEFAULT profile %+v", *p)
+	}
+}
+
+func TestParseConfigProfileErrors(t *testing.T) {
+	for _, profile := range []string{"missing", "no-credentials"} {
+		if _, err := ParseConfigProfile(strings.NewReader(testConfigFile), profile); err == nil {
+			t.Errorf("%s: expected error, got nil", profile)
+		}
+	}
+
+	if _, err := ParseConfigProfile(strings.NewReader("[DEFAULT\nhost = x\n"), "DEFAULT"); err == nil {
+		t.Error("expected error for malformed section header")
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	t.Setenv("HOME", "/home/baton")
+
+	t.Setenv(ConfigFileEnv, "")
+	if got, _ := ConfigFilePath(); got != "/home/baton/.databrickscfg" {
+		t.Errorf("default path = %q", got)
+	}
+
+	t.Setenv(ConfigFileEnv, "~/profiles/databricks.cfg")
+	if got, _ := ConfigFilePath(); got != "/home/baton/profiles/databricks.cfg" {
+		t.Errorf("home-relative path = %q", got)
+	}
+
+	t.Setenv(ConfigFileEnv, "/etc/databrickscfg")
+	if got, _ := ConfigFilePath(); got != "/etc/databrickscfg" {
+		t.Errorf("absolute path = %q", got)
+	}
+}
+
+func TestSplitWorkspaceHost(t *testing.T) {
+	cases := []struct {
+		host, deployment, hostname string
+	}{
+		{"dbc-a1b2c3.cloud.databricks.com", "dbc-a1b2c3", "cloud.databricks.com"},
+		{"adb-123.4.azuredatabricks.net", "adb-123.4", "azuredatabricks.net"},
+		{"123.4.gcp.databricks.com", "123.4", "gcp.databricks.com"},
+		{"ws.example.com", "ws", "example.com"},
+	}
+
+	for _, tc := range cases {
+		deployment, hostname := SplitWorkspaceHost(tc.host)
+		if deployment != tc.deployment || hostname != tc.hostname {
+			t.Errorf("SplitWorkspaceHost(%q) = %q, %q", tc.host, deployment, hostname)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package databrickstest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"github.com/conductorone/baton-databricks/pkg/databricks/internal/scim"
+)
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if f := s.fault(r); f != nil {
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter/time.Second)))
+		}
+		writeError(w, f.Status, errorCode(f.Status), fmt.Sprintf("injected %d for %s %s", f.Status, r.Method, r.URL.Path))
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+Token {
+		writeError(w, http.StatusUnauthorized, errorCode(http.StatusUnauthorized), "Invalid access token.")
+		return
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	switch {
+	case host == AccountHostname:
+		if s.accountAPIDisabled {
+			writeError(w, http.StatusUnauthorized, errorCode(http.StatusUnauthorized), "Invalid access token.")
+			return
+		}
+		s.serveAccount(w, r)
+
+	case strings.HasSuffix(host, "."+Hostname):
+		deployment := strings.TrimSuffix(host, "."+Hostname)
+		sc, ok := s.scopes[deployment]
+		if !ok {
+			writeError(w, http.StatusNotFound, errorCode(http.StatusNotFound), fmt.Sprintf("workspace %s does not exist", deployment))
+			return
+		}
+		s.serveWorkspace(w, r, sc)
+
+	default:
+		writeError(w, http.StatusNotFound, errorCode(http.StatusNotFound), fmt.Sprintf("unknown host %s", host))
+	}
+}
+
+func (s *Server) serveAccount(w http.ResponseWriter, r *http.Request) {
+	prefix := "/api/2.0/accounts/" + s.AccountID + "/"
+	previewPrefix := "/api/2.0/preview/accounts/" + s.AccountID + "/access-control/"
+
+	switch p := r.URL.Path; {
+	case strings.HasPrefix(p, prefix+"scim/v2/"):
+		s.serveSCIM(w, r, s.account, strings.TrimPrefix(p, prefix+"scim/v2/"))
+	case p == prefix+"workspaces" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.workspaces)
+	case strings.HasPrefix(p, prefix+"workspaces/"):
+		s.serveAssignments(w, r, strings.TrimPrefix(p, prefix+"workspaces/"))
+	case p == prefix+"sso" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, databricks.SSOSettings{})
+	case p == prefix+"sso/emergency-access" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string][]string{"users": {}})
+	case p == previewPrefix+"assignable-roles":
+		s.serveRoles(w, r, s.account)
+	case p == previewPrefix+"rule-sets":
+		s.serveRuleSets(w, r, s.account)
+	default:
+		writeError(w, http.StatusNotFound, "ENDPOINT_NOT_FOUND", fmt.Sprintf("No API found for '%s %s'", r.Method, p))
+	}
+}
+
+func (s *Server) serveWorkspace(w http.ResponseWriter, r *http.Request, sc *scope) {
+	switch p := r.URL.Path; {
+	case strings.HasPrefix(p, "/api/2.0/preview/scim/v2/"):
+		s.serveSCIM(w, r, sc, strings.TrimPrefix(p, "/api/2.0/preview/scim/v2/"))
+	case p == "/api/2.0/preview/accounts/access-control/assignable-roles":
+		s.serveRoles(w, r, sc)
+	case p == "/api/2.0/preview/accounts/access-control/rule-sets":
+		s.serveRuleSets(w, r, sc)
+	default:
+		writeError(w, http.StatusNotFound, "ENDPOINT_NOT_FOUND", fmt.Sprintf("No API found for '%s %s'", r.Method, p))
+	}
+}
+
+// serveSCIM serves a SCIM collection: listing and creating at its root, and
+// reading, replacing and deleting its resources.
+func (s *Server) serveSCIM(w http.ResponseWriter, r *http.Request, sc *scope, rest string) {
+	collection, id, _ := strings.Cut(rest, "/")
+	if collection != users && collection != groups && collection != servicePrincipals {
+		writeSCIMError(w, http.StatusNotFound, fmt.Sprintf("Unknown SCIM resource %s", collection))
+		return
+	}
+	resources := sc.resources[collection]
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		res, err := scim.List(resources, r.URL.Query())
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+
+	case id == "" && r.Method == http.MethodPost:
+		var created scim.Resource
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if name, ok := created["userName"].(string); ok && collection == users {
+			if _, exists := findBy(resources, "userName", name); exists {
+				writeSCIMError(w, http.StatusConflict, fmt.Sprintf("User with username %s already exists.", name))
+				return
+			}
+		}
+
+		created["id"] = strconv.Itoa(s.newID())
+		touch(created)
+		if collection == groups {
+			sc.resolveMembers(created)
+		}
+		sc.resources[collection] = append(resources, created)
+		writeJSON(w, http.StatusCreated, created)
+
+	case id != "" && r.Method == http.MethodGet:
+		res, ok := scim.Find(resources, id)
+		if !ok {
+			writeSCIMError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found.", strings.TrimSuffix(collection, "s"), id))
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+
+	case id != "" && r.Method == http.MethodPut:
+		i := slices.IndexFunc(resources, func(res scim.Resource) bool { return res["id"] == id })
+		if i < 0 {
+			writeSCIMError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found.", strings.TrimSuffix(collection, "s"), id))
+			return
+		}
+
+		var replaced scim.Resource
+		if err := json.NewDecoder(r.Body).Decode(&replaced); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// The server owns IDs and metadata, whatever the client sends.
+		replaced["id"] = id
+		replaced["meta"] = resources[i]["meta"]
+		touch(replaced)
+		if collection == groups {
+			sc.resolveMembers(replaced)
+		}
+		resources[i] = replaced
+		writeJSON(w, http.StatusOK, replaced)
+
+	case id != "" && r.Method == http.MethodDelete:
+		i := slices.IndexFunc(resources, func(res scim.Resource) bool { return res["id"] == id })
+		if i < 0 {
+			writeSCIMError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found.", strings.TrimSuffix(collection, "s"), id))
+			return
+		}
+		sc.resources[collection] = slices.Delete(resources, i, i+1)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeSCIMError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not supported", r.Method))
+	}
+}
+
+func findBy(resources []scim.Resource, attr, value string) (scim.Resource, bool) {
+	for _, r := range resources {
+		if v, ok := scim.Attr(r, attr); ok && strings.EqualFold(v, value) {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *Server) serveRoles(w http.ResponseWriter, r *http.Request, sc *scope) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "BAD_REQUEST", fmt.Sprintf("%s is not supported", r.Method))
+		return
+	}
+
+	roles := sc.roles
+	if roles == nil {
+		roles = []databricks.Role{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"roles": roles})
+}
+
+func ruleSetResponse(name string, rs *ruleSet) map[string]any {
+	rules := rs.rules
+	if rules == nil {
+		rules = []databricks.RuleSet{}
+	}
+
+	return map[string]any{"name": name, "etag": "etag-" + strconv.Itoa(rs.etag), "grant_rules": rules}
+}
+
+// serveRuleSets reads and replaces rule sets. Replacing needs the etag of the
+// current rule set, like the real API, so lost updates surface as conflicts.
+func (s *Server) serveRuleSets(w http.ResponseWriter, r *http.Request, sc *scope) {
+	name := r.URL.Query().Get("name")
+	current, ok := sc.ruleSets[name]
+	if !ok {
+		current = &ruleSet{}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, ruleSetResponse(name, current))
+
+	case http.MethodPut:
+		var body struct {
+			Name    string `json:"name"`
+			RuleSet struct {
+				Etag       string               `json:"etag"`
+				GrantRules []databricks.RuleSet `json:"grant_rules"`
+			} `json:"rule_set"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, errorCode(http.StatusBadRequest), err.Error())
+			return
+		}
+		if body.RuleSet.Etag != "etag-"+strconv.Itoa(current.etag) {
+			writeError(w, http.StatusConflict, errorCode(http.StatusConflict), fmt.Sprintf("Conflict with another request on rule set %s, etag %q is stale.", name, body.RuleSet.Etag))
+			return
+		}
+
+		current.etag++
+		current.rules = body.RuleSet.GrantRules
+		sc.ruleSets[name] = current
+		writeJSON(w, http.StatusOK, ruleSetResponse(name, current))
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "BAD_REQUEST", fmt.Sprintf("%s is not supported", r.Method))
+	}
+}
+
+// serveAssignments lists a workspace's permission assignments and assigns
+// account principals to it; assigning no permissions removes the principal.
+func (s *Server) serveAssignments(w http.ResponseWriter, r *http.Request, rest string) {
+	workspace, rest, _ := strings.Cut(rest, "/")
+	workspaceID, err := strconv.Atoi(workspace)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorCode(http.StatusBadRequest), fmt.Sprintf("invalid workspace ID %s", workspace))
+		return
+	}
+
+	assignments, ok := s.assignments[workspaceID]
+	if !ok {
+		writeError(w, http.StatusNotFound, errorCode(http.StatusNotFound), fmt.Sprintf("Workspace %d does not exist.", workspaceID))
+		return
+	}
+
+	switch {
+	case rest == "permissionassignments" && r.Method == http.MethodGet:
+		rv := []map[string]any{}
+		ids := make([]string, 0, len(assignments))
+		for id := range assignments {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+
+		for _, id := range ids {
+			principal, ok := s.workspacePrincipal(id)
+			if !ok {
+				continue
+			}
+			rv = append(rv, map[string]any{"principal": principal, "permissions": assignments[id]})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"permission_assignments": rv})
+
+	case strings.HasPrefix(rest, "permissionassignments/principals/") && r.Method == http.MethodPut:
+		id := strings.TrimPrefix(rest, "permissionassignments/principals/")
+		if _, ok := s.workspacePrincipal(id); !ok {
+			writeError(w, http.StatusNotFound, errorCode(http.StatusNotFound), fmt.Sprintf("Principal %s does not exist.", id))
+			return
+		}
+
+		// Removing a principal sends no body at all.
+		var body struct {
+			Permissions []string `json:"permissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, errorCode(http.StatusBadRequest), err.Error())
+			return
+		}
+
+		if len(body.Permissions) == 0 {
+			delete(assignments, id)
+		} else {
+			assignments[id] = body.Permissions
+		}
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	default:
+		writeError(w, http.StatusNotFound, "ENDPOINT_NOT_FOUND", fmt.Sprintf("No API found for '%s %s'", r.Method, r.URL.Path))
+	}
+}
+
+// workspacePrincipal describes an account principal the way permission
+// assignments do.
+func (s *Server) workspacePrincipal(id string) (databricks.WorkspacePrincipal, bool) {
+	numericID, err := strconv.Atoi(id)
+	if err != nil {
+		return databricks.WorkspacePrincipal{}, false
+	}
+
+	if u, ok := scim.Find(s.account.resources[users], id); ok {
+		name, _ := u["userName"].(string)
+		return databricks.WorkspacePrincipal{ID: numericID, UserName: name}, true
+	}
+	if g, ok := scim.Find(s.account.resources[groups], id); ok {
+		name, _ := g["displayName"].(string)
+		return databricks.WorkspacePrincipal{ID: numericID, GroupDisplayName: name}, true
+	}
+	if sp, ok := scim.Find(s.account.resources[servicePrincipals], id); ok {
+		appID, _ := sp["applicationId"].(string)
+		return databricks.WorkspacePrincipal{ID: numericID, ServicePrincipalAppID: appID}, true
+	}
+
+	return databricks.WorkspacePrincipal{}, false
+}
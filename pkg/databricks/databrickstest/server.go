@@ -0,0 +1,471 @@
+// Package databrickstest provides a fake Databricks API for tests: the account
+// and workspace SCIM APIs, assignable roles, rule sets, workspaces and
+// workspace permission assignments, seeded with fixtures and able to fail the
+// way the real API does.
+//
+// Point a client at it with Hostname, AccountHostname and BaseURL, and the
+// Auth of the server:
+//
+//	s := databrickstest.NewServer(t, "acc-1")
+//	s.AddUser("", databricks.User{UserName: "alice@example.com"})
+//	d, err := connector.New(ctx, databrickstest.Hostname, databrickstest.AccountHostname,
+//		s.AccountID, databrickstest.BaseURL, s.Auth(), nil, nil)
+package databrickstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+	"github.com/conductorone/baton-databricks/pkg/databricks/internal/scim"
+)
+
+// The server answers for these hosts, which its TLS certificate covers. Every
+// workspace is served at its deployment name under Hostname, so deployment
+// names must be single DNS labels.
+const (
+	Hostname        = "example.com"
+	AccountHostname = "accounts." + Hostname
+	BaseURL         = "https://" + Hostname
+
+	// Token is the bearer token the server accepts.
+	Token = "databrickstest-token"
+)
+
+// Fault makes matching requests fail. Method and Path are matched exactly when
+// set; Path is the URL path, without the host.
+type Fault struct {
+	Method string
+	Path   string
+	Status int
+	// Times is how many requests fail; 0 fails all of them.
+	Times int
+	// RetryAfter, when set, is sent in the Retry-After header.
+	RetryAfter time.Duration
+}
+
+// Server is a fake Databricks account. It is safe for concurrent use.
+type Server struct {
+	AccountID string
+
+	srv *httptest.Server
+
+	mu                 sync.Mutex
+	nextID             int
+	account            *scope
+	workspaces         []databricks.Workspace
+	scopes             map[string]*scope
+	assignments        map[int]map[string][]string
+	faults             []*Fault
+	accountAPIDisabled bool
+	requests           []string
+}
+
+// scope holds the principals, roles and rule sets of the account or of a
+// workspace.
+type scope struct {
+	resources map[string][]scim.Resource
+	roles     []databricks.Role
+	ruleSets  map[string]*ruleSet
+}
+
+type ruleSet struct {
+	etag  int
+	rules []databricks.RuleSet
+}
+
+func newScope() *scope {
+	return &scope{
+		resources: map[string][]scim.Resource{},
+		ruleSets:  map[string]*ruleSet{},
+	}
+}
+
+// The SCIM collections, by the last segment of their path.
+const (
+	users             = "Users"
+	groups            = "Groups"
+	servicePrincipals = "ServicePrincipals"
+)
+
+// NewServer starts a fake account, stopped when the test ends.
+func NewServer(t testing.TB, accountID string) *Server {
+	t.Helper()
+
+	s := &Server{
+		AccountID:   accountID,
+		nextID:      1000,
+		account:     newScope(),
+		scopes:      map[string]*scope{},
+		assignments: map[int]map[string][]string{},
+	}
+	s.srv = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.srv.Close)
+
+	return s
+}
+
+// Auth returns credentials that send every request of a client to the server.
+func (s *Server) Auth() databricks.Auth {
+	return &auth{s: s}
+}
+
+type auth struct {
+	s *Server
+}
+
+func (a *auth) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+Token)
+}
+
+func (a *auth) GetClient(ctx context.Context) (*http.Client, error) {
+	transport := a.s.srv.Client().Transport.(*http.Transport).Clone()
+	addr := a.s.srv.Listener.Addr().String()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// AddWorkspace adds a workspace to the account, assigning it an ID when it has
+// none. Workspaces have their own principals, roles and rule sets.
+func (s *Server) AddWorkspace(w databricks.Workspace) databricks.Workspace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w.ID == 0 {
+		w.ID = s.newID()
+	}
+	if w.Status == "" {
+		w.Status = "RUNNING"
+	}
+
+	s.workspaces = append(s.workspaces, w)
+	s.scopes[w.DeploymentName] = newScope()
+	s.assignments[w.ID] = map[string][]string{}
+
+	return w
+}
+
+// AddUser adds a user to the account, or to a workspace by deployment name,
+// assigning it an ID when it has none.
+func (s *Server) AddUser(workspace string, u databricks.User) databricks.User {
+	return add(s, workspace, users, u)
+}
+
+// AddGroup adds a group to the account or a workspace. Workspace groups are
+// workspace-local unless their meta type says otherwise.
+func (s *Server) AddGroup(workspace string, g databricks.Group) databricks.Group {
+	if g.Meta.Type == "" {
+		g.Meta.Type = "Group"
+		if workspace != "" {
+			g.Meta.Type = "WorkspaceGroup"
+		}
+	}
+
+	return add(s, workspace, groups, g)
+}
+
+// AddServicePrincipal adds a service principal to the account or a workspace.
+func (s *Server) AddServicePrincipal(workspace string, sp databricks.ServicePrincipal) databricks.ServicePrincipal {
+	return add(s, workspace, servicePrincipals, sp)
+}
+
+// User returns a user as the server currently has it.
+func (s *Server) User(workspace, id string) (databricks.User, bool) {
+	return get[databricks.User](s, workspace, users, id)
+}
+
+// Group returns a group as the server currently has it.
+func (s *Server) Group(workspace, id string) (databricks.Group, bool) {
+	return get[databricks.Group](s, workspace, groups, id)
+}
+
+// ServicePrincipal returns a service principal as the server currently has it.
+func (s *Server) ServicePrincipal(workspace, id string) (databricks.ServicePrincipal, bool) {
+	return get[databricks.ServicePrincipal](s, workspace, servicePrincipals, id)
+}
+
+// SetRoles sets the assignable roles of the account or a workspace.
+func (s *Server) SetRoles(workspace string, roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc := s.mustScope(workspace)
+	sc.roles = nil
+	for _, r := range roles {
+		sc.roles = append(sc.roles, databricks.Role{Name: r})
+	}
+}
+
+// SetRuleSet sets the grant rules of a rule set, by its full name, e.g.
+// accounts/acc-1/ruleSets/default.
+func (s *Server) SetRuleSet(workspace, name string, rules ...databricks.RuleSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc := s.mustScope(workspace)
+	rs, ok := sc.ruleSets[name]
+	if !ok {
+		rs = &ruleSet{}
+		sc.ruleSets[name] = rs
+	}
+	rs.etag++
+	rs.rules = rules
+}
+
+// RuleSet returns the grant rules of a rule set.
+func (s *Server) RuleSet(workspace, name string) []databricks.RuleSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rs, ok := s.mustScope(workspace).ruleSets[name]; ok {
+		return rs.rules
+	}
+
+	return nil
+}
+
+// Assign gives an account principal, by ID, permissions on a workspace.
+func (s *Server) Assign(workspaceID int, principalID string, permissions ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assignments[workspaceID][principalID] = permissions
+}
+
+// Assignments returns the permissions of account principals on a workspace,
+// by principal ID.
+func (s *Server) Assignments(workspaceID int) map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rv := make(map[string][]string, len(s.assignments[workspaceID]))
+	for id, permissions := range s.assignments[workspaceID] {
+		rv[id] = permissions
+	}
+
+	return rv
+}
+
+// Fail makes requests matching the fault fail until it is used up.
+func (s *Server) Fail(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// DisableAccountAPI makes the account API reject the server's credentials, as
+// it does for workspace tokens.
+func (s *Server) DisableAccountAPI() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accountAPIDisabled = true
+}
+
+// Requests returns how many requests the server received with a method and
+// URL path.
+func (s *Server) Requests(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, r := range s.requests {
+		if r == method+" "+path {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (s *Server) newID() int {
+	s.nextID++
+	return s.nextID
+}
+
+// mustScope returns the account scope for "" or a workspace's scope. Seeding
+// an unknown workspace is a bug in the test.
+func (s *Server) mustScope(workspace string) *scope {
+	if workspace == "" {
+		return s.account
+	}
+
+	sc, ok := s.scopes[workspace]
+	if !ok {
+		panic(fmt.Sprintf("databrickstest: workspace %s was not added", workspace))
+	}
+
+	return sc
+}
+
+func add[T any](s *Server, workspace, collection string, v T) T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := toResource(v)
+	if id, _ := r["id"].(string); id == "" {
+		r["id"] = strconv.Itoa(s.newID())
+	}
+	touch(r)
+
+	sc := s.mustScope(workspace)
+	if collection == groups {
+		sc.resolveMembers(r)
+	}
+	sc.resources[collection] = append(sc.resources[collection], r)
+
+	return fromResource[T](r)
+}
+
+func get[T any](s *Server, workspace, collection, id string) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := scim.Find(s.mustScope(workspace).resources[collection], id)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	return fromResource[T](r), true
+}
+
+// touch sets the last modification time of a resource to now.
+func touch(r scim.Resource) {
+	meta, _ := r["meta"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+		r["meta"] = meta
+	}
+	meta["lastModified"] = time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// resolveMembers fills in the reference and display name of the members of a
+// group, as the API does for members added by ID alone.
+func (sc *scope) resolveMembers(group scim.Resource) {
+	members, _ := group["members"].([]any)
+	for _, m := range members {
+		member, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		id, _ := member["value"].(string)
+		for _, collection := range []string{users, groups, servicePrincipals} {
+			r, ok := scim.Find(sc.resources[collection], id)
+			if !ok {
+				continue
+			}
+
+			member["$ref"] = collection + "/" + id
+			if display, ok := r["displayName"].(string); ok && display != "" {
+				member["display"] = display
+			} else if name, ok := r["userName"].(string); ok {
+				member["display"] = name
+			}
+			break
+		}
+	}
+}
+
+func toResource(v any) scim.Resource {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	var r scim.Resource
+	if err := json.Unmarshal(data, &r); err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func fromResource[T any](r scim.Resource) T {
+	var v T
+	data, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// fault returns the first fault matching a request, using it up.
+func (s *Server) fault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if (f.Method != "" && f.Method != r.Method) || (f.Path != "" && f.Path != r.URL.Path) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+
+		return f
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error the way the REST APIs do.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{"error_code": code, "message": message})
+}
+
+// writeSCIMError writes an error the way the SCIM APIs do.
+func writeSCIMError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]any{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+		"detail":  detail,
+		"status":  strconv.Itoa(status),
+	})
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_PARAMETER_VALUE"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "RESOURCE_DOES_NOT_EXIST"
+	case http.StatusConflict:
+		return "RESOURCE_CONFLICT"
+	case http.StatusTooManyRequests:
+		return "REQUEST_LIMIT_EXCEEDED"
+	case http.StatusServiceUnavailable:
+		return "TEMPORARILY_UNAVAILABLE"
+	}
+
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
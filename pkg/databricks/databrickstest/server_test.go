@@ -0,0 +1,86 @@
+package databrickstest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks"
+)
+
+func newTestClient(t *testing.T, s *Server) *databricks.Client {
+	t.Helper()
+
+	ctx := context.Background()
+	auth := s.Auth()
+	httpClient, err := auth.GetClient(ctx)
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+
+	c, err := databricks.NewClient(ctx, httpClient, Hostname, AccountHostname, s.AccountID, BaseURL, auth, nil,
+		databricks.WithRetryPolicy(databricks.RetryPolicy{}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	return c
+}
+
+func isStatus(err error, status int) bool {
+	var apiErr *databricks.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+func TestServerRuleSetConflicts(t *testing.T) {
+	s := NewServer(t, "acc-1")
+	name := "accounts/acc-1/ruleSets/default"
+	admin := databricks.RuleSet{Role: "roles/account.admin", Principals: []string{"users/alice@example.com"}}
+	s.SetRuleSet("", name, admin)
+
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	rules, _, err := c.ListRuleSets(ctx, "", "", "")
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("ListRuleSets = %v, %v", rules, err)
+	}
+
+	// Someone else changes the rule set after it was read.
+	s.SetRuleSet("", name)
+
+	if _, err := c.UpdateRuleSets(ctx, "", "", "", rules); !isStatus(err, http.StatusConflict) {
+		t.Errorf("UpdateRuleSets with a stale etag = %v, want 409", err)
+	}
+	if got := s.RuleSet("", name); len(got) != 0 {
+		t.Errorf("rule set after conflict = %v, want it unchanged", got)
+	}
+}
+
+func TestServerSCIMErrors(t *testing.T) {
+	s := NewServer(t, "acc-1")
+	s.AddUser("", databricks.User{UserName: "alice@example.com"})
+
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	filter := databricks.NewFilterVars(databricks.Or(databricks.Eq("userName", "a"), databricks.Eq("userName", "b")))
+	if _, _, _, err := c.ListUsers(ctx, "", filter); !isStatus(err, http.StatusBadRequest) {
+		t.Errorf("ListUsers with a compound filter = %v, want 400", err)
+	}
+
+	if _, _, err := c.GetUser(ctx, "", "404"); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("GetUser of a missing user = %v, want 404", err)
+	}
+
+	if _, _, err := c.CreateUser(ctx, "", &databricks.CreateUserBody{UserName: "alice@example.com"}); !isStatus(err, http.StatusConflict) {
+		t.Errorf("CreateUser of an existing user = %v, want 409", err)
+	}
+
+	s.DisableAccountAPI()
+	if _, _, _, err := c.ListUsers(ctx, "", databricks.NewFilterVars(databricks.Eq("userName", "bob@example.com"))); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("ListUsers without the account API = %v, want 401", err)
+	}
+}
@@ -0,0 +1,341 @@
+package databricks
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	gcpTokenURL           = "https://oauth2.googleapis.com/token"
+	gcpIAMCredentialsURL  = "https://iamcredentials.googleapis.com/v1"
+	gcpMetadataURL        = "http://metadata.google.internal/computeMetadata/v1"
+	gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	// GCPSAAccessTokenHeader carries the Google access token Databricks on
+	// Google Cloud uses to act on the customer's project.
+	GCPSAAccessTokenHeader = "X-Databricks-GCP-SA-Access-Token"
+)
+
+// GCP authenticates as a Google service account. Every request carries a
+// Google ID token whose audience is the host being called, and a Google access
+// token in the X-Databricks-GCP-SA-Access-Token header. Tokens are minted from
+// a service account key, or by impersonating a service account through the IAM
+// Credentials API using either the key or the GCE/GKE metadata server as the
+// source identity.
+type GCP struct {
+	key         *gcpServiceAccountKey
+	impersonate string
+
+	tokenURL          string
+	iamCredentialsURL string
+	metadataURL       string
+}
+
+// gcpServiceAccountKey is the subset of a Google service account JSON key used
+// to sign token requests.
+type gcpServiceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// NewGCP returns GCP auth using the service account key JSON, impersonating
+// the impersonate service account when it's set. Without a key, impersonation
+// uses the metadata server's default service account as the source identity.
+func NewGCP(serviceAccountKey, impersonate string) (*GCP, error) {
+	g := &GCP{
+		impersonate:       impersonate,
+		tokenURL:          gcpTokenURL,
+		iamCredentialsURL: gcpIAMCredentialsURL,
+		metadataURL:       gcpMetadataURL,
+	}
+
+	if serviceAccountKey != "" {
+		var key gcpServiceAccountKey
+		if err := json.Unmarshal([]byte(serviceAccountKey), &key); err != nil {
+			return nil, fmt.Errorf("failed to parse gcp service account key: %w", err)
+		}
+
+		if key.ClientEmail == "" || key.PrivateKey == "" {
+			return nil, fmt.Errorf("gcp service account key must have client_email and private_key")
+		}
+
+		if key.TokenURI != "" {
+			g.tokenURL = key.TokenURI
+		}
+
+		g.key = &key
+	}
+
+	if g.key == nil && g.impersonate == "" {
+		return nil, fmt.Errorf("gcp auth needs a service account key or a service account to impersonate")
+	}
+
+	return g, nil
+}
+
+func (g *GCP) GetClient(ctx context.Context) (*http.Client, error) {
+	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Token requests go through their own client so they don't carry the
+	// tokens they fetch; the oauth2 package and gcpDo pick it up from ctx.
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
+		Timeout:   httpClient.Timeout,
+		Transport: transportOrDefault(httpClient),
+	})
+
+	source := oauth2.ReuseTokenSource(nil, g.sourceTokenSource(ctx))
+
+	accessToken := source
+	if g.impersonate != "" {
+		accessToken = oauth2.ReuseTokenSource(nil, &gcpImpersonatedTokenSource{ctx: ctx, auth: g, source: source})
+	}
+
+	httpClient.Transport = &gcpTransport{
+		ctx:         ctx,
+		base:        transportOrDefault(httpClient),
+		auth:        g,
+		source:      source,
+		accessToken: accessToken,
+		idTokens:    make(map[string]oauth2.TokenSource),
+	}
+
+	return httpClient, nil
+}
+
+func (g *GCP) Apply(req *http.Request) {
+	// No need to set the headers here, the transport returned by GetClient does it.
+}
+
+// sourceTokenSource returns access tokens for the identity the connector runs as.
+func (g *GCP) sourceTokenSource(ctx context.Context) oauth2.TokenSource {
+	if g.key != nil {
+		return g.jwtConfig(nil).TokenSource(ctx)
+	}
+
+	return &gcpMetadataTokenSource{ctx: ctx, url: g.metadataURL + "/instance/service-accounts/default/token"}
+}
+
+func (g *GCP) jwtConfig(claims map[string]interface{}) *jwt.Config {
+	cfg := &jwt.Config{
+		Email:        g.key.ClientEmail,
+		PrivateKey:   []byte(g.key.PrivateKey),
+		PrivateKeyID: g.key.PrivateKeyID,
+		TokenURL:     g.tokenURL,
+	}
+
+	if claims == nil {
+		cfg.Scopes = []string{gcpCloudPlatformScope}
+	} else {
+		cfg.PrivateClaims = claims
+		cfg.UseIDToken = true
+	}
+
+	return cfg
+}
+
+// gcpTransport sets the ID token for the request's host and the access token
+// on every request.
+type gcpTransport struct {
+	ctx         context.Context
+	base        http.RoundTripper
+	auth        *GCP
+	source      oauth2.TokenSource
+	accessToken oauth2.TokenSource
+
+	mu       sync.Mutex
+	idTokens map[string]oauth2.TokenSource
+}
+
+// idTokenSource returns the cached ID token source for an audience.
+func (t *gcpTransport) idTokenSource(audience string) oauth2.TokenSource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts, ok := t.idTokens[audience]; ok {
+		return ts
+	}
+
+	var ts oauth2.TokenSource
+	if t.auth.impersonate != "" {
+		ts = &gcpImpersonatedTokenSource{ctx: t.ctx, auth: t.auth, source: t.source, audience: audience}
+	} else {
+		ts = t.auth.jwtConfig(map[string]interface{}{"target_audience": audience}).TokenSource(t.ctx)
+	}
+
+	ts = oauth2.ReuseTokenSource(nil, ts)
+	t.idTokens[audience] = ts
+
+	return ts
+}
+
+func (t *gcpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	audience := "https://" + req.URL.Host
+
+	idToken, err := t.idTokenSource(audience).Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gcp id token for %s: %w", audience, err)
+	}
+
+	accessToken, err := t.accessToken.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gcp access token: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+idToken.AccessToken)
+	req.Header.Set(GCPSAAccessTokenHeader, accessToken.AccessToken)
+
+	return t.base.RoundTrip(req)
+}
+
+// gcpMetadataTokenSource fetches access tokens for the default service account
+// of the GCE instance or GKE workload the connector runs on.
+type gcpMetadataTokenSource struct {
+	ctx context.Context
+	url string
+}
+
+func (s *gcpMetadataTokenSource) Token() (*oauth2.Token, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := gcpDo(s.ctx, req, &res); err != nil {
+		return nil, fmt.Errorf("failed to get token from gcp metadata server: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: res.AccessToken,
+		TokenType:   res.TokenType,
+		Expiry:      time.Now().Add(time.Duration(res.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// gcpImpersonatedTokenSource mints tokens for the impersonated service account
+// through the IAM Credentials API: an ID token when audience is set, an access
+// token otherwise.
+type gcpImpersonatedTokenSource struct {
+	ctx      context.Context
+	auth     *GCP
+	source   oauth2.TokenSource
+	audience string
+}
+
+func (s *gcpImpersonatedTokenSource) Token() (*oauth2.Token, error) {
+	method, body := "generateAccessToken", map[string]interface{}{"scope": []string{gcpCloudPlatformScope}}
+	if s.audience != "" {
+		method, body = "generateIdToken", map[string]interface{}{"audience": s.audience, "includeEmail": true}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/projects/-/serviceAccounts/%s:%s", s.auth.iamCredentialsURL, s.auth.impersonate, method)
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	source.SetAuthHeader(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	var res struct {
+		Token       string    `json:"token"`
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := gcpDo(s.ctx, req, &res); err != nil {
+		return nil, fmt.Errorf("failed to impersonate gcp service account %s: %w", s.auth.impersonate, err)
+	}
+
+	if s.audience == "" {
+		return &oauth2.Token{AccessToken: res.AccessToken, TokenType: "Bearer", Expiry: res.ExpireTime}, nil
+	}
+
+	return &oauth2.Token{AccessToken: res.Token, TokenType: "Bearer", Expiry: idTokenExpiry(res.Token)}, nil
+}
+
+// gcpDo sends a token request with the client GetClient put in ctx, or one
+// with uhttp's default timeout when there is none, and decodes its response.
+func gcpDo(ctx context.Context, req *http.Request, res interface{}) error {
+	client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if !ok {
+		var err error
+		client, err = uhttp.NewClient(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, res)
+}
+
+// idTokenExpiry reads the exp claim of a Google ID token. The token is only
+// decoded, not verified: it was just received from Google over TLS. A token
+// that can't be decoded is treated as already expired.
+func idTokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Now()
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Now()
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(raw, &claims); err != nil || claims.Exp == 0 {
+		return time.Now()
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
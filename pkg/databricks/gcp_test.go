@@ -0,0 +1,246 @@
+package databricks
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+)
+
+// fakeIDToken returns an unsigned JWT carrying aud and exp claims.
+func fakeIDToken(t *testing.T, audience string) string {
+	t.Helper()
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": audience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256"}`)) + "." + enc(claims) + "." + enc([]byte("sig"))
+}
+
+// gcpCalls records the Authorization and access token headers seen per host.
+type gcpCalls struct {
+	mu      sync.Mutex
+	headers map[string][2]string
+}
+
+func (c *gcpCalls) record(r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.headers[r.URL.Query().Get("host")] = [2]string{r.Header.Get("Authorization"), r.Header.Get(GCPSAAccessTokenHeader)}
+}
+
+func TestGCPWithServiceAccountKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+
+	calls := &gcpCalls{headers: map[string][2]string{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			calls.record(r)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		_ = json.Unmarshal(raw, &claims)
+
+		w.Header().Set("Content-Type", "application/json")
+		if audience, ok := claims["target_audience"].(string); ok {
+			_, _ = fmt.Fprintf(w, `{"id_token": %q}`, fakeIDToken(t, audience))
+			return
+		}
+
+		if claims["scope"] != gcpCloudPlatformScope {
+			t.Errorf("scope = %v", claims["scope"])
+		}
+		_, _ = w.Write([]byte(`{"access_token": "gcp-access", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer srv.Close()
+
+	keyJSON, err := json.Marshal(gcpServiceAccountKey{
+		ClientEmail: "baton@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    srv.URL + "/token",
+	})
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	auth, err := NewGCP(string(keyJSON), "")
+	if err != nil {
+		t.Fatalf("NewGCP: %v", err)
+	}
+
+	assertGCPHeaders(t, auth, srv.URL, calls, "gcp-access")
+}
+
+func TestGCPImpersonationFromMetadataServer(t *testing.T) {
+	calls := &gcpCalls{headers: map[string][2]string{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/metadata/instance/service-accounts/default/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				t.Error("missing Metadata-Flavor header")
+			}
+			_, _ = w.Write([]byte(`{"access_token": "source-token", "token_type": "Bearer", "expires_in": 3600}`))
+		case strings.HasPrefix(r.URL.Path, "/iam/projects/-/serviceAccounts/target@project.iam.gserviceaccount.com:"):
+			if r.Header.Get("Authorization") != "Bearer source-token" {
+				t.Errorf("impersonation Authorization = %q", r.Header.Get("Authorization"))
+			}
+
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if strings.HasSuffix(r.URL.Path, ":generateIdToken") {
+				_, _ = fmt.Fprintf(w, `{"token": %q}`, fakeIDToken(t, body["audience"].(string)))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"accessToken": "impersonated-access", "expireTime": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+		default:
+			calls.record(r)
+		}
+	}))
+	defer srv.Close()
+
+	auth, err := NewGCP("", "target@project.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("NewGCP: %v", err)
+	}
+	auth.metadataURL = srv.URL + "/metadata"
+	auth.iamCredentialsURL = srv.URL + "/iam"
+
+	assertGCPHeaders(t, auth, srv.URL, calls, "impersonated-access")
+}
+
+// assertGCPHeaders sends requests for two hosts through the auth's client and
+// checks each got an ID token for its own audience plus the access token.
+func assertGCPHeaders(t *testing.T, auth *GCP, srvURL string, calls *gcpCalls, wantAccessToken string) {
+	t.Helper()
+
+	client, err := auth.GetClient(context.Background())
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+
+	// Every host resolves to the test server; the audience comes from the URL host.
+	transport := client.Transport.(*gcpTransport)
+	transport.base = rewriteHostTransport{target: strings.TrimPrefix(srvURL, "http://")}
+
+	for _, host := range []string{"accounts.gcp.databricks.com", "123.4.gcp.databricks.com"} {
+		resp, err := client.Get("https://" + host + "/api?host=" + host)
+		if err != nil {
+			t.Fatalf("Get %s: %v", host, err)
+		}
+		resp.Body.Close()
+	}
+
+	for _, host := range []string{"accounts.gcp.databricks.com", "123.4.gcp.databricks.com"} {
+		got := calls.headers[host]
+
+		parts := strings.Split(strings.TrimPrefix(got[0], "Bearer "), ".")
+		if len(parts) != 3 {
+			t.Fatalf("%s: Authorization %q is not an ID token", host, got[0])
+		}
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		_ = json.Unmarshal(raw, &claims)
+		if claims["aud"] != "https://"+host {
+			t.Errorf("%s: ID token audience = %v", host, claims["aud"])
+		}
+
+		if got[1] != wantAccessToken {
+			t.Errorf("%s: %s = %q, want %q", host, GCPSAAccessTokenHeader, got[1], wantAccessToken)
+		}
+	}
+}
+
+// rewriteHostTransport sends https requests for any host to a local http server.
+type rewriteHostTransport struct {
+	target string
+}
+
+func (r rewriteHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = r.target
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewGCPRequiresCredentials(t *testing.T) {
+	if _, err := NewGCP("", ""); err == nil {
+		t.Error("expected error without a key or a service account to impersonate")
+	}
+	if _, err := NewGCP(`{"client_email": "x"}`, ""); err == nil {
+		t.Error("expected error for a key without a private key")
+	}
+}
+
+func TestGCPTokenRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	auth, err := NewGCP("", "target@project.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("NewGCP: %v", err)
+	}
+	auth.metadataURL = srv.URL + "/metadata"
+
+	ctx := context.WithValue(context.Background(), uhttp.ContextHTTPTimeoutKey, 100*time.Millisecond)
+	client, err := auth.GetClient(ctx)
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+
+	// A metadata server that never answers fails the request instead of
+	// hanging the sync.
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(srv.URL + "/api")
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the token request to time out")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token request didn't time out")
+	}
+}
@@ -0,0 +1,142 @@
+// Package scim serves SCIM listings from resources held in memory, for the
+// stand-ins of the Databricks API: offline exports and the fake test server.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resource is a SCIM resource as decoded from JSON.
+type Resource = map[string]any
+
+var comparison = regexp.MustCompile(`^([A-Za-z][\w.]*) (eq|co|sw|ge) (".*")$`)
+
+// Find returns the resource with an ID.
+func Find(resources []Resource, id string) (Resource, bool) {
+	for _, r := range resources {
+		if r["id"] == id {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// List returns the list response for a query, with its filter, startIndex and
+// count applied. Attribute selection is ignored.
+func List(resources []Resource, query url.Values) (map[string]any, error) {
+	if filter := query.Get("filter"); filter != "" {
+		match, err := Filter(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := resources[:0:0]
+		for _, r := range resources {
+			if match(r) {
+				matched = append(matched, r)
+			}
+		}
+		resources = matched
+	}
+
+	total := len(resources)
+	start, count := 1, total
+	if v, err := strconv.Atoi(query.Get("startIndex")); err == nil && v > 1 {
+		start = v
+	}
+	if v, err := strconv.Atoi(query.Get("count")); err == nil && v >= 0 {
+		count = v
+	}
+
+	page := resources[min(start-1, total):min(start-1+count, total)]
+
+	return map[string]any{
+		"schemas":      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
+		"totalResults": total,
+		"startIndex":   start,
+		"itemsPerPage": len(page),
+		"Resources":    page,
+	}, nil
+}
+
+// Parse reads the resources of a SCIM list response or of a plain array.
+func Parse(data []byte) ([]Resource, error) {
+	var resources []Resource
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err := json.Unmarshal(data, &resources)
+		return resources, err
+	}
+
+	var res struct {
+		Resources []Resource `json:"Resources"`
+	}
+	err := json.Unmarshal(data, &res)
+	return res.Resources, err
+}
+
+// Filter supports the single comparisons the connector sends. Attribute names
+// and eq, co and sw comparisons are case-insensitive, as in SCIM.
+func Filter(filter string) (func(Resource) bool, error) {
+	m := comparison.FindStringSubmatch(filter)
+	if m == nil {
+		return nil, fmt.Errorf("filter %q is not supported", filter)
+	}
+
+	attr, op := m[1], m[2]
+	var value string
+	if err := json.Unmarshal([]byte(m[3]), &value); err != nil {
+		return nil, fmt.Errorf("filter %q is not supported", filter)
+	}
+
+	return func(r Resource) bool {
+		v, ok := Attr(r, attr)
+		if !ok {
+			return false
+		}
+
+		switch op {
+		case "eq":
+			return strings.EqualFold(v, value)
+		case "co":
+			return strings.Contains(strings.ToLower(v), strings.ToLower(value))
+		case "sw":
+			return strings.HasPrefix(strings.ToLower(v), strings.ToLower(value))
+		default:
+			vt, err1 := time.Parse(time.RFC3339Nano, v)
+			valueT, err2 := time.Parse(time.RFC3339Nano, value)
+			if err1 == nil && err2 == nil {
+				return !vt.Before(valueT)
+			}
+			return v >= value
+		}
+	}, nil
+}
+
+// Attr returns a string attribute of a resource by its dotted path.
+func Attr(r Resource, attr string) (string, bool) {
+	var v any = r
+	for _, name := range strings.Split(attr, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+
+		v = nil
+		for k, child := range obj {
+			if strings.EqualFold(k, name) {
+				v = child
+				break
+			}
+		}
+	}
+
+	s, ok := v.(string)
+	return s, ok
+}
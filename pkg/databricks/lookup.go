@@ -0,0 +1,122 @@
+package databricks
+
+import (
+	"context"
+	"sync"
+)
+
+// lookupKind names one direction of a principal lookup.
+type lookupKind int
+
+const (
+	lookupUserID lookupKind = iota
+	lookupUsername
+	lookupGroupID
+	lookupGroupDisplayName
+	lookupServicePrincipalID
+	lookupServicePrincipalAppID
+)
+
+type lookupKey struct {
+	kind      lookupKind
+	workspace string
+	key       string
+}
+
+// lookupCache remembers principal name↔ID lookups for one sync, per workspace
+// ("" for the account). Rule sets name principals by username, display name or
+// application ID, so without it every principal of every rule set costs a SCIM
+// call. Listings pre-warm it, and a new sync ID drops what the previous sync
+// cached, since names can change between syncs.
+type lookupCache struct {
+	mu      sync.RWMutex
+	syncID  string
+	entries map[lookupKey]string
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{entries: make(map[lookupKey]string)}
+}
+
+// reset drops all entries when syncID differs from the current sync.
+func (l *lookupCache) reset(syncID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if syncID == l.syncID {
+		return
+	}
+
+	l.syncID = syncID
+	l.entries = make(map[lookupKey]string)
+}
+
+// get returns a cached lookup, unless ctx asks for fresh lookups.
+func (l *lookupCache) get(ctx context.Context, kind lookupKind, workspace, key string) (string, bool) {
+	if freshLookups(ctx) {
+		return "", false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	v, ok := l.entries[lookupKey{kind: kind, workspace: workspace, key: key}]
+	return v, ok
+}
+
+// pair records a name↔ID pair in both directions. Partial pairs, e.g. from
+// listings that didn't request the name attribute, are skipped.
+func (l *lookupCache) pair(byName, byID lookupKind, workspace, name, id string) {
+	if name == "" || id == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[lookupKey{kind: byName, workspace: workspace, key: name}] = id
+	l.entries[lookupKey{kind: byID, workspace: workspace, key: id}] = name
+}
+
+func (l *lookupCache) addUsers(workspace string, users []User) {
+	for _, u := range users {
+		l.pair(lookupUserID, lookupUsername, workspace, u.UserName, u.ID)
+	}
+}
+
+func (l *lookupCache) addGroups(workspace string, groups []Group) {
+	for _, g := range groups {
+		l.pair(lookupGroupID, lookupGroupDisplayName, workspace, g.DisplayName, g.ID)
+	}
+}
+
+func (l *lookupCache) addServicePrincipals(workspace string, servicePrincipals []ServicePrincipal) {
+	for _, sp := range servicePrincipals {
+		l.pair(lookupServicePrincipalID, lookupServicePrincipalAppID, workspace, sp.ApplicationID, sp.ID)
+	}
+}
+
+type freshLookupsKey struct{}
+
+// WithFreshLookups returns a context whose principal lookups go to the API,
+// past both the lookup cache and the HTTP cache. Grants and revokes use it: a
+// name cached earlier in the sync may have changed since, and a rule set
+// edited with a stale name binds the wrong principal.
+func WithFreshLookups(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshLookupsKey{}, true)
+}
+
+func freshLookups(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshLookupsKey{}).(bool)
+	return fresh
+}
+
+// SetSyncID scopes the principal lookup cache to a sync. Lookups cached
+// during an earlier sync are dropped; an empty sync ID is ignored.
+func (c *Client) SetSyncID(syncID string) {
+	if syncID == "" {
+		return
+	}
+
+	c.lookups.reset(syncID)
+}
@@ -0,0 +1,83 @@
+package databricks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFindUsesLookupCache(t *testing.T) {
+	var calls int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"Resources": [{"id": "u-1", "userName": "alice@example.com"}], "totalResults": 1}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(context.Background(), srv.Client(), "example.com", srv.Listener.Addr().String(), "acc-1", "", &NoAuth{}, nil)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ctx := context.Background()
+
+	c.SetSyncID("sync-1")
+	if _, _, _, err := c.ListUsers(ctx, ""); err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+
+	// Both directions come from the listing.
+	if id, _, err := c.FindUserID(ctx, "", "alice@example.com"); err != nil || id != "u-1" {
+		t.Fatalf("FindUserID = %q, %v", id, err)
+	}
+	if name, _, err := c.FindUsername(ctx, "", "u-1"); err != nil || name != "alice@example.com" {
+		t.Fatalf("FindUsername = %q, %v", name, err)
+	}
+	if calls != 1 {
+		t.Errorf("made %d calls, want 1", calls)
+	}
+
+	// Workspaces are cached separately from the account.
+	if _, ok := c.lookups.get(ctx, lookupUserID, "dbc-1", "alice@example.com"); ok {
+		t.Error("account listing warmed a workspace lookup")
+	}
+
+	// The same sync keeps the cache, a new one drops it.
+	c.SetSyncID("sync-1")
+	if _, _, err := c.FindUserID(ctx, "", "alice@example.com"); err != nil {
+		t.Fatalf("FindUserID: %v", err)
+	}
+	c.SetSyncID("sync-2")
+	if _, _, err := c.FindUserID(ctx, "", "alice@example.com"); err != nil {
+		t.Fatalf("FindUserID: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("made %d calls, want 2", calls)
+	}
+
+	// Fresh lookups skip both the lookup cache and the HTTP cache.
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.FindUsername(WithFreshLookups(ctx), "", "u-1"); err != nil {
+			t.Fatalf("FindUsername: %v", err)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("made %d calls, want 4", calls)
+	}
+}
+
+func TestLookupCacheSkipsPartialPairs(t *testing.T) {
+	l := newLookupCache()
+	l.addGroups("", []Group{{BaseResponse: BaseResponse{ID: "g-1"}}})
+	l.addServicePrincipals("", []ServicePrincipal{{BaseResponse: BaseResponse{ID: "sp-1"}, ApplicationID: "app-1"}})
+
+	if _, ok := l.get(context.Background(), lookupGroupDisplayName, "", "g-1"); ok {
+		t.Error("cached a group without a display name")
+	}
+	if id, ok := l.get(context.Background(), lookupServicePrincipalID, "", "app-1"); !ok || id != "sp-1" {
+		t.Errorf("service principal by app ID = %q, %v", id, ok)
+	}
+	if appID, ok := l.get(context.Background(), lookupServicePrincipalAppID, "", "sp-1"); !ok || appID != "app-1" {
+		t.Errorf("service principal by ID = %q, %v", appID, ok)
+	}
+}
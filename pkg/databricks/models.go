@@ -1,11 +1,57 @@
 package databricks
 
+import (
+	"slices"
+	"strings"
+)
+
 type BaseResponse struct {
-	ID string `json:"id"`
+	ID   string `json:"id"`
+	Meta Meta   `json:"meta,omitempty"`
+}
+
+// GetID returns the SCIM ID of the resource.
+func (b BaseResponse) GetID() string {
+	return b.ID
 }
 
+// GetMeta returns the SCIM metadata of the resource.
+func (b BaseResponse) GetMeta() Meta {
+	return b.Meta
+}
+
+// Meta is the SCIM metadata of a resource. LastModified is only returned by
+// some endpoints, and only when the meta attribute is requested.
+type Meta struct {
+	Type         string `json:"resourceType,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// PermissionValue is a SCIM role or entitlement. Type is "direct" for values
+// assigned to the principal itself and Ref points at the group ("Groups/<id>")
+// for values inherited through group membership.
 type PermissionValue struct {
 	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+	Ref   string `json:"$ref,omitempty"`
+}
+
+const directPermissionType = "direct"
+
+// IsInherited reports whether the value comes from a group rather than being
+// assigned directly. Values without a type are treated as direct.
+func (p PermissionValue) IsInherited() bool {
+	return (p.Type != "" && p.Type != directPermissionType) || p.SourceGroupID() != ""
+}
+
+// SourceGroupID returns the ID of the group an inherited value comes from.
+func (p PermissionValue) SourceGroupID() string {
+	ref := strings.TrimSuffix(p.Ref, "/")
+	if !strings.Contains(ref, "Groups/") {
+		return ""
+	}
+
+	return ref[strings.LastIndex(ref, "/")+1:]
 }
 
 type Permissions struct {
@@ -13,6 +59,59 @@ type Permissions struct {
 	Entitlements []PermissionValue `json:"entitlements,omitempty"`
 }
 
+// PermissionSources describes where a principal's role or entitlement comes
+// from: assigned directly, inherited from groups, or both. Groups lists the
+// source groups the API referenced.
+type PermissionSources struct {
+	Direct    bool
+	Inherited bool
+	Groups    []string
+}
+
+// Any reports whether the principal has the permission from any source.
+func (s PermissionSources) Any() bool {
+	return s.Direct || s.Inherited
+}
+
+// RoleSources returns the sources of the given role.
+func (p Permissions) RoleSources(role string) PermissionSources {
+	return permissionSources(p.Roles, role)
+}
+
+// EntitlementSources returns the sources of the given entitlement.
+func (p Permissions) EntitlementSources(entitlement string) PermissionSources {
+	return permissionSources(p.Entitlements, entitlement)
+}
+
+// DirectOnly drops inherited values, leaving what can be written back with a
+// SCIM update. Sending group-derived values would assign them directly.
+func (p *Permissions) DirectOnly() {
+	isInherited := func(v PermissionValue) bool { return v.IsInherited() }
+	p.Roles = slices.DeleteFunc(p.Roles, isInherited)
+	p.Entitlements = slices.DeleteFunc(p.Entitlements, isInherited)
+}
+
+func permissionSources(values []PermissionValue, name string) PermissionSources {
+	var rv PermissionSources
+	for _, v := range values {
+		if v.Value != name {
+			continue
+		}
+
+		if !v.IsInherited() {
+			rv.Direct = true
+			continue
+		}
+
+		rv.Inherited = true
+		if id := v.SourceGroupID(); id != "" && !slices.Contains(rv.Groups, id) {
+			rv.Groups = append(rv.Groups, id)
+		}
+	}
+
+	return rv
+}
+
 type User struct {
 	BaseResponse
 	Permissions
@@ -56,10 +155,7 @@ type Group struct {
 	Permissions
 	DisplayName string   `json:"displayName"`
 	Members     []Member `json:"members,omitempty"`
-	Meta        struct {
-		Type string `json:"resourceType"`
-	} `json:"meta,omitempty"`
-	Schemas []string `json:"schemas,omitempty"`
+	Schemas     []string `json:"schemas,omitempty"`
 }
 
 func (g Group) HaveRole(role string) bool {
@@ -119,6 +215,28 @@ type Workspace struct {
 	Name           string `json:"workspace_name"`
 	Status         string `json:"workspace_status"`
 	DeploymentName string `json:"deployment_name"`
+	Cloud          string `json:"cloud,omitempty"`
+	Location       string `json:"location,omitempty"`
+	AWSRegion      string `json:"aws_region,omitempty"`
+	WorkspaceURL   string `json:"workspace_url,omitempty"`
+}
+
+// Host returns the workspace's API host. The workspace URL wins when the API
+// returns one; otherwise the host is built from the deployment name and the
+// cloud's domain, falling back to hostname for AWS and unknown clouds.
+func (w Workspace) Host(hostname string) string {
+	if w.WorkspaceURL != "" {
+		return normalizeHost(w.WorkspaceURL)
+	}
+
+	switch strings.ToLower(w.Cloud) {
+	case "azure":
+		return w.DeploymentName + "." + azureHost
+	case "gcp":
+		return w.DeploymentName + "." + gcpHost
+	}
+
+	return w.DeploymentName + "." + hostname
 }
 
 type WorkspacePrincipal struct {
@@ -132,6 +250,15 @@ type WorkspaceAssignment struct {
 	Principal *WorkspacePrincipal `json:"principal"`
 }
 
+// SSOSettings is the account console single sign-on configuration. With SSO
+// disabled every account user logs in with a password; with it enabled only the
+// emergency access users can.
+type SSOSettings struct {
+	Enabled             bool   `json:"enabled"`
+	Protocol            string `json:"protocol,omitempty"`
+	UnifiedLoginEnabled bool   `json:"unified_login_enabled"`
+}
+
 type Role struct {
 	Name string `json:"name"`
 }
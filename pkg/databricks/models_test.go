@@ -0,0 +1,50 @@
+package databricks
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPermissionSources(t *testing.T) {
+	var u User
+	err := json.Unmarshal([]byte(`{
+		"id": "1",
+		"entitlements": [
+			{"value": "databricks-sql-access", "type": "direct"},
+			{"value": "databricks-sql-access", "type": "derived", "$ref": "Groups/100"},
+			{"value": "workspace-access", "type": "derived", "$ref": "Groups/200"},
+			{"value": "allow-cluster-create"}
+		]
+	}`), &u)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	tests := []struct {
+		entitlement string
+		want        PermissionSources
+	}{
+		{"databricks-sql-access", PermissionSources{Direct: true, Inherited: true, Groups: []string{"100"}}},
+		{"workspace-access", PermissionSources{Inherited: true, Groups: []string{"200"}}},
+		{"allow-cluster-create", PermissionSources{Direct: true}},
+		{"workspace-consume", PermissionSources{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entitlement, func(t *testing.T) {
+			if got := u.EntitlementSources(tt.entitlement); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EntitlementSources() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	u.DirectOnly()
+	want := []PermissionValue{
+		{Value: "databricks-sql-access", Type: "direct"},
+		{Value: "allow-cluster-create"},
+	}
+	if !reflect.DeepEqual(u.Entitlements, want) {
+		t.Errorf("DirectOnly() left %+v, want %+v", u.Entitlements, want)
+	}
+}
@@ -0,0 +1,332 @@
+package databricks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/conductorone/baton-databricks/pkg/databricks/internal/scim"
+)
+
+// Offline exports are served under these hosts, which never resolve, so a
+// client built for them can't reach a real account by mistake.
+const (
+	OfflineHostname        = "offline.databricks.invalid"
+	OfflineAccountHostname = "accounts." + OfflineHostname
+	OfflineBaseURL         = "https://" + OfflineHostname
+)
+
+// OfflineAuth serves API requests from JSON exports in a directory instead of
+// the live API, for accounts and workspaces the connector can't reach. The
+// directory holds the responses of the read endpoints the connector calls:
+//
+//	workspaces.json                           GET /accounts/{id}/workspaces
+//	account/users.json                        SCIM Users of the account
+//	account/groups.json                       SCIM Groups of the account
+//	account/service_principals.json           SCIM ServicePrincipals of the account
+//	account/roles.json                        assignable roles of the account
+//	account/rule_sets.json                    rule sets of the account, by name
+//	account/sso.json                          single sign-on settings
+//	account/emergency_access.json             emergency access users
+//	workspaces/{deployment}/users.json        SCIM Users of a workspace, etc.
+//	workspaces/{deployment}/permission_assignments.json
+//
+// SCIM files hold a list response or a plain array of resources; rule set
+// files map rule set names to GET rule-sets responses. Roles are the same for
+// every resource. A scope without a directory answers 404, like an API the
+// credentials can't use, and a missing roles or rule sets file in a present
+// scope means there are none. Writes are rejected.
+type OfflineAuth struct {
+	dir string
+}
+
+func NewOfflineAuth(dir string) *OfflineAuth {
+	return &OfflineAuth{dir: dir}
+}
+
+func (o *OfflineAuth) Apply(req *http.Request) {}
+
+func (o *OfflineAuth) GetClient(ctx context.Context) (*http.Client, error) {
+	info, err := os.Stat(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open offline export: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("offline export %s is not a directory", o.dir)
+	}
+
+	return &http.Client{Transport: &offlineTransport{dir: o.dir}}, nil
+}
+
+var (
+	offlineAccountSCIMPath     = regexp.MustCompile(`^/api/2\.0/accounts/[^/]+/scim/v2/(Users|Groups|ServicePrincipals)(?:/([^/]+))?$`)
+	offlineWorkspacesPath      = regexp.MustCompile(`^/api/2\.0/accounts/[^/]+/workspaces$`)
+	offlineAssignmentsPath     = regexp.MustCompile(`^/api/2\.0/accounts/[^/]+/workspaces/([^/]+)/permissionassignments$`)
+	offlineSSOPath             = regexp.MustCompile(`^/api/2\.0/accounts/[^/]+/sso$`)
+	offlineEmergencyAccessPath = regexp.MustCompile(`^/api/2\.0/accounts/[^/]+/sso/emergency-access$`)
+	offlineAccountRolesPath    = regexp.MustCompile(`^/api/2\.0/preview/accounts/[^/]+/access-control/assignable-roles$`)
+	offlineAccountRuleSetsPath = regexp.MustCompile(`^/api/2\.0/preview/accounts/[^/]+/access-control/rule-sets$`)
+
+	offlineWorkspaceSCIMPath = regexp.MustCompile(`^/api/2\.0/preview/scim/v2/(Users|Groups|ServicePrincipals)(?:/([^/]+))?$`)
+)
+
+var offlineSCIMFiles = map[string]string{
+	"Users":             "users.json",
+	"Groups":            "groups.json",
+	"ServicePrincipals": "service_principals.json",
+}
+
+// offlineTransport answers requests from an offline export. Exports don't
+// change under a sync, so each file it filters or looks up in is parsed once,
+// by the first request that needs it.
+type offlineTransport struct {
+	dir string
+
+	mu     sync.Mutex
+	parsed map[string]offlineFile
+}
+
+// offlineFile is a parsed file of the export, or a record that it is missing.
+type offlineFile struct {
+	value  any
+	exists bool
+}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	if req.Method != http.MethodGet {
+		return offlineError(req, http.StatusMethodNotAllowed, "offline exports are read-only")
+	}
+
+	p := req.URL.Path
+	switch host := req.URL.Hostname(); {
+	case host == OfflineAccountHostname:
+		switch {
+		case offlineAccountSCIMPath.MatchString(p):
+			m := offlineAccountSCIMPath.FindStringSubmatch(p)
+			return t.scim(req, "account", m[1], m[2])
+		case offlineWorkspacesPath.MatchString(p):
+			return t.file(req, "workspaces.json")
+		case offlineAssignmentsPath.MatchString(p):
+			return t.permissionAssignments(req, offlineAssignmentsPath.FindStringSubmatch(p)[1])
+		case offlineSSOPath.MatchString(p):
+			return t.file(req, filepath.Join("account", "sso.json"))
+		case offlineEmergencyAccessPath.MatchString(p):
+			return t.file(req, filepath.Join("account", "emergency_access.json"))
+		case offlineAccountRolesPath.MatchString(p):
+			return t.roles(req, "account")
+		case offlineAccountRuleSetsPath.MatchString(p):
+			return t.ruleSets(req, "account")
+		}
+
+	case strings.HasSuffix(host, "."+OfflineHostname):
+		deployment := strings.TrimSuffix(host, "."+OfflineHostname)
+		if !filepath.IsLocal(deployment) {
+			break
+		}
+
+		scope := filepath.Join("workspaces", deployment)
+		switch {
+		case offlineWorkspaceSCIMPath.MatchString(p):
+			m := offlineWorkspaceSCIMPath.FindStringSubmatch(p)
+			return t.scim(req, scope, m[1], m[2])
+		case p == rolesEndpoint:
+			return t.roles(req, scope)
+		case p == ruleSetsEndpoint:
+			return t.ruleSets(req, scope)
+		}
+	}
+
+	return offlineError(req, http.StatusNotFound, fmt.Sprintf("%s is not in the offline export", req.URL.Path))
+}
+
+// read reads a file of the export, or returns nil if it doesn't exist.
+func (t *offlineTransport) read(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(t.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offline export: %w", err)
+	}
+
+	return data, nil
+}
+
+// parseOffline returns a file of the export as parsed by parse, parsing it on
+// first use, and whether it exists. Failures aren't kept, so a file that
+// couldn't be read or parsed is tried again by the next request.
+func parseOffline[T any](t *offlineTransport, name string, parse func([]byte) (T, error)) (T, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if f, ok := t.parsed[name]; ok {
+		value, _ := f.value.(T)
+		return value, f.exists, nil
+	}
+
+	var value T
+	data, err := t.read(name)
+	if err != nil {
+		return value, false, err
+	}
+	if data != nil {
+		if value, err = parse(data); err != nil {
+			return value, false, fmt.Errorf("failed to parse %s of offline export: %w", name, err)
+		}
+	}
+
+	if t.parsed == nil {
+		t.parsed = make(map[string]offlineFile)
+	}
+	t.parsed[name] = offlineFile{value: value, exists: data != nil}
+
+	return value, data != nil, nil
+}
+
+func unmarshalOffline[T any](data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// hasScope reports whether the export has the account, or a workspace.
+func (t *offlineTransport) hasScope(scope string) bool {
+	info, err := os.Stat(filepath.Join(t.dir, scope))
+	return err == nil && info.IsDir()
+}
+
+func (t *offlineTransport) file(req *http.Request, name string) (*http.Response, error) {
+	data, err := t.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return offlineError(req, http.StatusNotFound, fmt.Sprintf("%s is not in the offline export", name))
+	}
+
+	return offlineResponse(req, http.StatusOK, data), nil
+}
+
+func (t *offlineTransport) roles(req *http.Request, scope string) (*http.Response, error) {
+	if !t.hasScope(scope) {
+		return offlineError(req, http.StatusNotFound, fmt.Sprintf("%s is not in the offline export", scope))
+	}
+
+	data, err := t.read(filepath.Join(scope, "roles.json"))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = []byte(`{"roles": []}`)
+	}
+
+	return offlineResponse(req, http.StatusOK, data), nil
+}
+
+func (t *offlineTransport) ruleSets(req *http.Request, scope string) (*http.Response, error) {
+	if !t.hasScope(scope) {
+		return offlineError(req, http.StatusNotFound, fmt.Sprintf("%s is not in the offline export", scope))
+	}
+
+	ruleSets, _, err := parseOffline(t, filepath.Join(scope, "rule_sets.json"), unmarshalOffline[map[string]json.RawMessage])
+	if err != nil {
+		return nil, err
+	}
+
+	name := req.URL.Query().Get("name")
+	if ruleSet, ok := ruleSets[name]; ok {
+		return offlineResponse(req, http.StatusOK, ruleSet), nil
+	}
+
+	return offlineJSON(req, http.StatusOK, map[string]any{"name": name, "grant_rules": []any{}})
+}
+
+// permissionAssignments serves the assignments of a workspace, which the API
+// addresses by numeric ID and the export by deployment name.
+func (t *offlineTransport) permissionAssignments(req *http.Request, workspaceId string) (*http.Response, error) {
+	workspaces, _, err := parseOffline(t, "workspaces.json", unmarshalOffline[[]Workspace])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range workspaces {
+		if strconv.Itoa(w.ID) == workspaceId {
+			return t.file(req, filepath.Join("workspaces", w.DeploymentName, "permission_assignments.json"))
+		}
+	}
+
+	return offlineError(req, http.StatusNotFound, fmt.Sprintf("workspace %s is not in the offline export", workspaceId))
+}
+
+// scim serves a SCIM listing, with filtering and pagination, or one of its
+// resources by ID.
+func (t *offlineTransport) scim(req *http.Request, scope, kind, id string) (*http.Response, error) {
+	name := filepath.Join(scope, offlineSCIMFiles[kind])
+	resources, exists, err := parseOffline(t, name, scim.Parse)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return offlineError(req, http.StatusNotFound, fmt.Sprintf("%s is not in the offline export", name))
+	}
+
+	if id != "" {
+		if r, ok := scim.Find(resources, id); ok {
+			return offlineJSON(req, http.StatusOK, r)
+		}
+
+		return offlineError(req, http.StatusNotFound, fmt.Sprintf("%s %s is not in the offline export", kind, id))
+	}
+
+	res, err := scim.List(resources, req.URL.Query())
+	if err != nil {
+		return offlineError(req, http.StatusBadRequest, err.Error())
+	}
+
+	return offlineJSON(req, http.StatusOK, res)
+}
+
+func offlineResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func offlineJSON(req *http.Request, status int, v any) (*http.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode offline response: %w", err)
+	}
+
+	return offlineResponse(req, status, body), nil
+}
+
+func offlineError(req *http.Request, status int, message string) (*http.Response, error) {
+	return offlineJSON(req, status, map[string]string{
+		"error_code": strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		"message":    message,
+	})
+}
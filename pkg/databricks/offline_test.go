@@ -0,0 +1,170 @@
+package databricks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeOfflineExport writes files, by path relative to the export, to a new
+// export directory.
+func writeOfflineExport(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	return dir
+}
+
+func newOfflineTestClient(t *testing.T, dir string) *Client {
+	t.Helper()
+
+	auth := NewOfflineAuth(dir)
+	httpClient, err := auth.GetClient(context.Background())
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+
+	c, err := NewClient(context.Background(), httpClient, OfflineHostname, OfflineAccountHostname, "acc-1", OfflineBaseURL, auth, nil)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	return c
+}
+
+func TestOfflineSCIM(t *testing.T) {
+	dir := writeOfflineExport(t, map[string]string{
+		"account/users.json": `{"Resources": [
+			{"id": "u-1", "userName": "alice@example.com", "meta": {"lastModified": "2024-05-01T10:00:00Z"}},
+			{"id": "u-2", "userName": "bob@example.com", "meta": {"lastModified": "2024-04-01T10:00:00Z"}},
+			{"id": "u-3", "userName": "carol@example.com", "meta": {"lastModified": "2024-06-01T10:00:00Z"}}
+		]}`,
+		"workspaces/dbc-abc/users.json": `[{"id": "w-1", "userName": "alice@example.com"}]`,
+	})
+	c := newOfflineTestClient(t, dir)
+	ctx := context.Background()
+
+	users, total, _, err := c.ListUsers(ctx, "", NewPaginationVars(2, 1))
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if total != 3 || len(users) != 1 || users[0].ID != "u-2" {
+		t.Errorf("page 2 = %v of %d, want u-2 of 3", users, total)
+	}
+
+	id, _, err := c.FindUserID(ctx, "", "Alice@Example.com")
+	if err != nil || id != "u-1" {
+		t.Errorf("FindUserID = %q, %v, want u-1", id, err)
+	}
+
+	users, _, _, err = c.ListUsers(ctx, "", NewFilterVars(Ge("meta.lastModified", "2024-05-01T10:00:00Z")))
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(users) != 2 || users[0].ID != "u-1" || users[1].ID != "u-3" {
+		t.Errorf("modified since = %v, want u-1 and u-3", users)
+	}
+
+	users, _, _, err = c.ListUsers(ctx, "dbc-abc")
+	if err != nil || len(users) != 1 || users[0].ID != "w-1" {
+		t.Errorf("workspace users = %v, %v, want w-1", users, err)
+	}
+
+	if _, _, err := c.GetUser(ctx, "", "u-404"); !isOfflineStatus(err, http.StatusNotFound) {
+		t.Errorf("GetUser of a missing user = %v, want 404", err)
+	}
+
+	if _, _, _, err := c.ListUsers(ctx, "", NewFilterVars(Or(Eq("userName", "a"), Eq("userName", "b")))); !isOfflineStatus(err, http.StatusBadRequest) {
+		t.Errorf("compound filter = %v, want 400", err)
+	}
+}
+
+func TestOfflineParsesFilesOnce(t *testing.T) {
+	dir := writeOfflineExport(t, map[string]string{
+		"account/users.json": `[{"id": "u-1", "userName": "alice@example.com"}, {"id": "u-2", "userName": "bob@example.com"}]`,
+	})
+	c := newOfflineTestClient(t, dir)
+	ctx := context.Background()
+
+	if _, _, _, err := c.ListUsers(ctx, "", NewPaginationVars(1, 1)); err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+
+	// Later pages are served from the parsed file, not the one on disk.
+	if err := os.WriteFile(filepath.Join(dir, "account", "users.json"), []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	users, total, _, err := c.ListUsers(ctx, "", NewPaginationVars(2, 1))
+	if err != nil || total != 2 || len(users) != 1 || users[0].ID != "u-2" {
+		t.Errorf("page 2 = %v of %d, %v, want u-2 of 2", users, total, err)
+	}
+}
+
+func TestOfflineAccountAPIs(t *testing.T) {
+	dir := writeOfflineExport(t, map[string]string{
+		"workspaces.json": `[{"workspace_id": 123, "deployment_name": "dbc-abc"}]`,
+		"workspaces/dbc-abc/permission_assignments.json": `{"permission_assignments": [
+			{"principal": {"principal_id": 7, "user_name": "alice@example.com"}, "permissions": ["USER"]}
+		]}`,
+		"account/rule_sets.json": `{"accounts/acc-1/ruleSets/default": {
+			"name": "accounts/acc-1/ruleSets/default",
+			"etag": "e-1",
+			"grant_rules": [{"role": "roles/account.admin", "principals": ["users/alice@example.com"]}]
+		}}`,
+	})
+	c := newOfflineTestClient(t, dir)
+	ctx := context.Background()
+
+	workspaces, _, err := c.ListWorkspaces(ctx)
+	if err != nil || len(workspaces) != 1 {
+		t.Fatalf("ListWorkspaces = %v, %v", workspaces, err)
+	}
+	if got := c.workspaceUrl("dbc-abc").Host; got != "dbc-abc."+OfflineHostname {
+		t.Errorf("workspace host = %q", got)
+	}
+
+	assignments, _, err := c.ListWorkspaceMembers(ctx, "123")
+	if err != nil || len(assignments) != 1 {
+		t.Errorf("ListWorkspaceMembers = %v, %v", assignments, err)
+	}
+
+	ruleSets, _, err := c.ListRuleSets(ctx, "", "", "")
+	if err != nil || len(ruleSets) != 1 || ruleSets[0].Role != "roles/account.admin" {
+		t.Errorf("account rule sets = %v, %v", ruleSets, err)
+	}
+
+	ruleSets, _, err = c.ListRuleSets(ctx, "", "groups", "g-1")
+	if err != nil || len(ruleSets) != 0 {
+		t.Errorf("rule sets of a group without rules = %v, %v, want none", ruleSets, err)
+	}
+
+	if _, _, err := c.ListRoles(ctx, "", "", ""); err != nil {
+		t.Errorf("ListRoles of the account: %v", err)
+	}
+	if _, _, err := c.ListRoles(ctx, "dbc-missing", "", ""); !isOfflineStatus(err, http.StatusNotFound) {
+		t.Errorf("ListRoles of a workspace not in the export = %v, want 404", err)
+	}
+
+	if _, err := c.DeleteUser(ctx, "", "u-1"); !isOfflineStatus(err, http.StatusMethodNotAllowed) {
+		t.Errorf("DeleteUser = %v, want 405", err)
+	}
+}
+
+func isOfflineStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
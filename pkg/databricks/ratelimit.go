@@ -0,0 +1,179 @@
+package databricks
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EndpointFamily groups the endpoints that share a Databricks rate limit.
+type EndpointFamily string
+
+const (
+	// EndpointFamilySCIMUsers covers the SCIM Users and ServicePrincipals APIs.
+	EndpointFamilySCIMUsers             EndpointFamily = "scim-users"
+	EndpointFamilySCIMGroups            EndpointFamily = "scim-groups"
+	EndpointFamilyRuleSets              EndpointFamily = "rule-sets"
+	EndpointFamilyPermissionAssignments EndpointFamily = "permission-assignments"
+)
+
+// DefaultRateLimits are the requests per second allowed for each endpoint
+// family on each host, unless WithRateLimits sets others. They are kept low so
+// a sync leaves headroom for other API clients of the account.
+var DefaultRateLimits = map[EndpointFamily]int{
+	EndpointFamilySCIMUsers:             20,
+	EndpointFamilySCIMGroups:            20,
+	EndpointFamilyRuleSets:              10,
+	EndpointFamilyPermissionAssignments: 10,
+}
+
+// WithRateLimits sets the requests per second allowed for endpoint families on
+// each host. Families left out keep their default; a limit of 0 disables
+// limiting for the family.
+func WithRateLimits(limits map[EndpointFamily]int) ClientOption {
+	return func(c *Client) {
+		for family, limit := range limits {
+			c.rateLimiter.limits[family] = limit
+		}
+	}
+}
+
+// endpointFamily returns the family of an API path, or "" for endpoints that
+// aren't rate limited by the client.
+func endpointFamily(path string) EndpointFamily {
+	switch {
+	case strings.Contains(path, "/scim/v2/Users"), strings.Contains(path, "/scim/v2/ServicePrincipals"):
+		return EndpointFamilySCIMUsers
+	case strings.Contains(path, "/scim/v2/Groups"):
+		return EndpointFamilySCIMGroups
+	case strings.Contains(path, "/access-control/rule-sets"):
+		return EndpointFamilyRuleSets
+	case strings.Contains(path, "/permissionassignments"):
+		return EndpointFamilyPermissionAssignments
+	}
+
+	return ""
+}
+
+// rateLimiter keeps a token bucket per host and endpoint family.
+type rateLimiter struct {
+	limits map[EndpointFamily]int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	limits := make(map[EndpointFamily]int, len(DefaultRateLimits))
+	for family, limit := range DefaultRateLimits {
+		limits[family] = limit
+	}
+
+	return &rateLimiter{limits: limits, buckets: make(map[string]*tokenBucket)}
+}
+
+// bucket returns the token bucket for a request, or nil if it isn't limited.
+func (l *rateLimiter) bucket(host, path string) *tokenBucket {
+	family := endpointFamily(path)
+	limit := l.limits[family]
+	if family == "" || limit <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := host + " " + string(family)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newTokenBucket(limit, time.Now())
+		l.buckets[key] = b
+	}
+
+	return b
+}
+
+// wait blocks until the request may be sent.
+func (l *rateLimiter) wait(ctx context.Context, host, path string) error {
+	b := l.bucket(host, path)
+	if b == nil {
+		return nil
+	}
+
+	delay := b.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	return sleep(ctx, delay)
+}
+
+// describe fills in the client-side limit of a request when the API didn't
+// report its own, so throttling shows up in the returned rate limit data as
+// no remaining requests until the bucket refills. The status stays OK: the
+// request was already delayed to fit the limit.
+func (l *rateLimiter) describe(host, path string, desc *v2.RateLimitDescription) {
+	b := l.bucket(host, path)
+	if b == nil || desc == nil || desc.GetLimit() != 0 {
+		return
+	}
+
+	remaining, resetAt := b.state(time.Now())
+
+	desc.Limit = int64(b.rate)
+	desc.Remaining = remaining
+	desc.ResetAt = timestamppb.New(resetAt)
+	if desc.GetStatus() == v2.RateLimitDescription_STATUS_UNSPECIFIED {
+		desc.Status = v2.RateLimitDescription_STATUS_OK
+	}
+}
+
+// tokenBucket allows rate requests per second with bursts of up to one second's
+// worth. Tokens can go negative: each caller reserves one and waits until the
+// bucket has refilled to cover it.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: float64(rate), tokens: float64(rate), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.rate, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// state returns the whole tokens left and when the bucket will be full again.
+func (b *tokenBucket) state(now time.Time) (int64, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	missing := b.rate - b.tokens
+
+	return max(int64(b.tokens), 0), now.Add(time.Duration(missing / b.rate * float64(time.Second)))
+}
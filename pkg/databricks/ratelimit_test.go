@@ -0,0 +1,86 @@
+package databricks
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+func TestEndpointFamily(t *testing.T) {
+	tests := map[string]EndpointFamily{
+		"/api/2.0/preview/scim/v2/Users":                                  EndpointFamilySCIMUsers,
+		"/api/2.0/accounts/acc-1/scim/v2/ServicePrincipals/123":           EndpointFamilySCIMUsers,
+		"/api/2.0/accounts/acc-1/scim/v2/Groups":                          EndpointFamilySCIMGroups,
+		"/api/2.0/preview/accounts/acc-1/access-control/rule-sets":        EndpointFamilyRuleSets,
+		"/api/2.0/accounts/acc-1/workspaces/1/permissionassignments":      EndpointFamilyPermissionAssignments,
+		"/api/2.0/accounts/acc-1/workspaces":                              "",
+		"/api/2.0/preview/accounts/acc-1/access-control/assignable-roles": "",
+	}
+
+	for path, want := range tests {
+		if got := endpointFamily(path); got != want {
+			t.Errorf("endpointFamily(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, now)
+
+	// The first second's worth of requests goes out at once.
+	for i := 0; i < 2; i++ {
+		if d := b.reserve(now); d != 0 {
+			t.Fatalf("reserve %d waited %v, want 0", i, d)
+		}
+	}
+
+	// Then each request waits for its share of the refill.
+	if d := b.reserve(now); d != 500*time.Millisecond {
+		t.Errorf("third reserve waited %v, want 500ms", d)
+	}
+	if d := b.reserve(now); d != time.Second {
+		t.Errorf("fourth reserve waited %v, want 1s", d)
+	}
+
+	// Idle time refills the bucket, up to one second's worth.
+	later := now.Add(10 * time.Second)
+	remaining, resetAt := b.state(later)
+	if remaining != 2 || !resetAt.Equal(later) {
+		t.Errorf("state after idling = %d, %v, want 2, %v", remaining, resetAt, later)
+	}
+}
+
+func TestRateLimiterDescribe(t *testing.T) {
+	l := newRateLimiter()
+	l.limits[EndpointFamilySCIMGroups] = 1
+
+	if err := l.wait(t.Context(), "example.com", "/api/2.0/preview/scim/v2/Groups"); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	desc := &v2.RateLimitDescription{}
+	l.describe("example.com", "/api/2.0/preview/scim/v2/Groups", desc)
+	if desc.GetLimit() != 1 || desc.GetRemaining() != 0 || desc.GetStatus() != v2.RateLimitDescription_STATUS_OK || desc.GetResetAt() == nil {
+		t.Errorf("describe() = %+v, want limit 1 with none remaining", desc)
+	}
+
+	// Limits reported by the API win.
+	desc = &v2.RateLimitDescription{Limit: 100, Remaining: 42}
+	l.describe("example.com", "/api/2.0/preview/scim/v2/Groups", desc)
+	if desc.GetLimit() != 100 || desc.GetRemaining() != 42 {
+		t.Errorf("describe() overwrote the API's limits: %+v", desc)
+	}
+
+	// Each host has its own bucket.
+	if b := l.bucket("other.example.com", "/api/2.0/preview/scim/v2/Groups"); b == l.bucket("example.com", "/api/2.0/preview/scim/v2/Groups") {
+		t.Error("hosts share a bucket")
+	}
+
+	// Unlimited families have no bucket.
+	l.limits[EndpointFamilySCIMUsers] = 0
+	if b := l.bucket("example.com", "/api/2.0/preview/scim/v2/Users"); b != nil {
+		t.Error("expected no bucket for a family with limit 0")
+	}
+}
@@ -0,0 +1,313 @@
+package databricks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Exchange is a request to the Databricks API and its response, as saved by a
+// RecordingTransport and served by a ReplayTransport. Bodies are kept as text,
+// so quirks such as JSON served as text/plain survive the round trip.
+type Exchange struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// recordedHeaders are the response headers worth keeping: the ones the client
+// reads, for content and rate limits. Everything else, cookies included, is
+// dropped.
+var recordedHeaders = []string{
+	"Content-Type",
+	"Retry-After",
+	"X-Ratelimit-Limit",
+	"X-Ratelimit-Remaining",
+	"X-Ratelimit-Reset",
+}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	tokenPattern  = regexp.MustCompile(`\b(?:dapi|dose|dkea)[0-9a-f]{32}(?:-\d+)?\b`)
+	secretPattern = regexp.MustCompile(`("(?:access_token|refresh_token|id_token|client_secret|secret|token_value|password)"\s*:\s*)"[^"]*"`)
+
+	// namePattern finds the names of people, groups and workspaces in JSON
+	// bodies, namePrincipalPattern group principals of rule sets, and
+	// nameFilterPattern the same names in SCIM filters.
+	namePattern          = regexp.MustCompile(`("(?:displayName|display_name|display|givenName|familyName|formatted|externalId|external_id|group_name|workspace_name)"\s*:\s*)"((?:[^"\\]|\\.)*)"`)
+	namePrincipalPattern = regexp.MustCompile(`"groups/((?:[^"\\]|\\.)*)"`)
+	nameFilterPattern    = regexp.MustCompile(`(?i)(\b(?:displayName|externalId)\s+(?:eq|ne|co|sw|ew)\s+)"((?:[^"\\]|\\.)*)"`)
+	pseudonymNamePattern = regexp.MustCompile(`^name-[0-9a-f]{8}$`)
+)
+
+// Sanitize removes credentials and personal data from recorded text. Email
+// addresses, and the names of people, groups and workspaces, become stable
+// pseudonyms, so the same user keeps the same address across requests and
+// fixtures and a group lookup by name still matches its listing. Tokens and
+// secrets are redacted. Account IDs, deployment names and hostnames are
+// kept, since replays route on them.
+func Sanitize(s string) string {
+	s = emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		if strings.HasSuffix(strings.ToLower(email), "@example.com") {
+			return email
+		}
+
+		return "user-" + pseudonym(email) + "@example.com"
+	})
+	s = tokenPattern.ReplaceAllString(s, "REDACTED")
+	s = secretPattern.ReplaceAllString(s, `$1"REDACTED"`)
+	s = replaceName(namePattern, s)
+	s = replaceName(nameFilterPattern, s)
+	s = namePrincipalPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return `"groups/` + pseudonymName(namePrincipalPattern.FindStringSubmatch(m)[1]) + `"`
+	})
+
+	return s
+}
+
+// replaceName replaces the quoted name matched by the second group of pattern
+// with its pseudonym, keeping what the first group matched.
+func replaceName(pattern *regexp.Regexp, s string) string {
+	return pattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := pattern.FindStringSubmatch(m)
+		return sub[1] + `"` + pseudonymName(sub[2]) + `"`
+	})
+}
+
+// pseudonymName returns the pseudonym of a name. Empty names and pseudonyms
+// are kept, so sanitizing a recorded URL again for replay leaves it as is.
+func pseudonymName(name string) string {
+	if name == "" || pseudonymNamePattern.MatchString(name) {
+		return name
+	}
+
+	return "name-" + pseudonym(name)
+}
+
+// pseudonym is a short, case-insensitive digest of a name or address.
+func pseudonym(s string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(s)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// sanitizeURL sanitizes a URL in its unescaped form, so that addresses in
+// filters are found however they were encoded.
+func sanitizeURL(u *url.URL) string {
+	query := u.Query()
+	for key, values := range query {
+		for i, v := range values {
+			values[i] = Sanitize(v)
+		}
+		query[key] = values
+	}
+
+	sanitized := *u
+	sanitized.User = nil
+	sanitized.Path = Sanitize(u.Path)
+	sanitized.RawPath = ""
+	sanitized.RawQuery = query.Encode()
+
+	return sanitized.String()
+}
+
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// RecordingTransport saves every exchange with the API to a directory, one
+// sanitized JSON file per exchange, numbered in the order they completed.
+type RecordingTransport struct {
+	next http.RoundTripper
+	dir  string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecordingTransport records the exchanges of next, or of the default
+// transport if it is nil, to dir, creating it if needed.
+func NewRecordingTransport(next http.RoundTripper, dir string) (*RecordingTransport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	return &RecordingTransport{next: next, dir: dir}, nil
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body for recording: %w", err)
+	}
+	if reqBody != nil {
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body for recording: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	exchange := Exchange{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    sanitizeURL(req.URL),
+			Body:   Sanitize(string(reqBody)),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     make(map[string]string),
+			Body:       Sanitize(string(respBody)),
+		},
+	}
+	for _, name := range recordedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			exchange.Response.Header[name] = v
+		}
+	}
+
+	if err := t.save(&exchange); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (t *RecordingTransport) save(exchange *Exchange) error {
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recorded exchange: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	name := fmt.Sprintf("%04d-%s.json", t.seq, strings.ToLower(exchange.Request.Method))
+	if err := os.WriteFile(filepath.Join(t.dir, name), append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to save recorded exchange: %w", err)
+	}
+
+	return nil
+}
+
+// ReplayTransport answers requests from recorded exchanges, without network
+// access. A request is matched on its method and sanitized URL; repeated
+// requests get the recorded responses in order, and the last one after that.
+type ReplayTransport struct {
+	mu        sync.Mutex
+	exchanges map[string][]Exchange
+	served    map[string]int
+}
+
+// NewReplayTransport loads the exchanges recorded to dir.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recorded exchanges: %w", err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no recorded exchanges in %s", dir)
+	}
+	slices.Sort(names)
+
+	t := &ReplayTransport{
+		exchanges: make(map[string][]Exchange),
+		served:    make(map[string]int),
+	}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recorded exchange: %w", err)
+		}
+
+		var exchange Exchange
+		if err := json.Unmarshal(data, &exchange); err != nil {
+			return nil, fmt.Errorf("failed to parse recorded exchange %s: %w", filepath.Base(name), err)
+		}
+
+		u, err := url.Parse(exchange.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse URL of recorded exchange %s: %w", filepath.Base(name), err)
+		}
+
+		key := exchange.Request.Method + " " + sanitizeURL(u)
+		t.exchanges[key] = append(t.exchanges[key], exchange)
+	}
+
+	return t, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, err := readBody(req.Body); err != nil {
+		return nil, fmt.Errorf("failed to read request body for replay: %w", err)
+	}
+
+	key := req.Method + " " + sanitizeURL(req.URL)
+
+	t.mu.Lock()
+	exchanges, ok := t.exchanges[key]
+	if !ok {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("no recorded exchange for %s", key)
+	}
+	i := min(t.served[key], len(exchanges)-1)
+	t.served[key]++
+	t.mu.Unlock()
+
+	recorded := exchanges[i].Response
+	header := make(http.Header, len(recorded.Header))
+	for name, v := range recorded.Header {
+		header.Set(name, v)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
@@ -46,12 +46,36 @@ func (c *Client) Get(
 	response interface{},
 	params ...Vars,
 ) (*v2.RateLimitDescription, error) {
+	if freshLookups(ctx) {
+		return c.getUncached(ctx, urlAddress, response, params...)
+	}
+
 	return c.doRequest(
 		ctx,
 		urlAddress,
 		http.MethodGet,
 		nil,
 		response,
+		nil,
+		params...,
+	)
+}
+
+// getUncached is Get for resources that change between requests, such as the
+// status of a running statement, bypassing the response cache.
+func (c *Client) getUncached(
+	ctx context.Context,
+	urlAddress *url.URL,
+	response interface{},
+	params ...Vars,
+) (*v2.RateLimitDescription, error) {
+	return c.doRequest(
+		ctx,
+		urlAddress,
+		http.MethodGet,
+		nil,
+		response,
+		[]uhttp.RequestOption{uhttp.WithNoCache()},
 		params...,
 	)
 }
@@ -69,6 +93,7 @@ func (c *Client) Put(
 		http.MethodPut,
 		body,
 		response,
+		nil,
 		params...,
 	)
 }
@@ -86,6 +111,7 @@ func (c *Client) Post(
 		http.MethodPost,
 		body,
 		response,
+		nil,
 		params...,
 	)
 }
@@ -120,6 +146,7 @@ func (c *Client) doRequest(
 	method string,
 	body interface{},
 	response interface{},
+	requestOptions []uhttp.RequestOption,
 	params ...Vars,
 ) (*v2.RateLimitDescription, error) {
 	// TODO(marcos): Refactor URLs so that we don't have to unescape.
@@ -133,35 +160,7 @@ func (c *Client) doRequest(
 		return nil, err
 	}
 
-	options := []uhttp.RequestOption{
-		uhttp.WithAcceptJSONHeader(),
-	}
-	if body != nil {
-		options = append(options, uhttp.WithJSONBody(body))
-	}
-
-	req, err := c.httpClient.NewRequest(ctx, method, uri, options...)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(params) > 0 {
-		query := url.Values{}
-		for _, param := range params {
-			param.Apply(&query)
-		}
-
-		req.URL.RawQuery = query.Encode()
-	}
-
-	c.auth.Apply(req)
-
-	ratelimitData := &v2.RateLimitDescription{}
-	resp, err := c.httpClient.Do(
-		req,
-		uhttp.WithAlwaysJSONResponse(&response),
-		uhttp.WithRatelimitData(ratelimitData),
-	)
+	resp, ratelimitData, err := c.send(ctx, method, uri, body, params, requestOptions, uhttp.WithAlwaysJSONResponse(&response))
 	if resp == nil {
 		return ratelimitData, err
 	}
@@ -208,34 +207,7 @@ func (c *Client) doRequestNoResponse(
 		return nil, err
 	}
 
-	options := []uhttp.RequestOption{
-		uhttp.WithAcceptJSONHeader(),
-	}
-	if body != nil {
-		options = append(options, uhttp.WithJSONBody(body))
-	}
-
-	req, err := c.httpClient.NewRequest(ctx, method, uri, options...)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(params) > 0 {
-		query := url.Values{}
-		for _, param := range params {
-			param.Apply(&query)
-		}
-
-		req.URL.RawQuery = query.Encode()
-	}
-
-	c.auth.Apply(req)
-
-	ratelimitData := &v2.RateLimitDescription{}
-	resp, err := c.httpClient.Do(
-		req,
-		uhttp.WithRatelimitData(ratelimitData),
-	)
+	resp, ratelimitData, err := c.send(ctx, method, uri, body, params, nil)
 	if resp == nil {
 		return ratelimitData, err
 	}
@@ -253,3 +225,70 @@ func (c *Client) doRequestNoResponse(
 		Err:        err,
 	}
 }
+
+// send builds and sends a request within the client's rate limits, retrying it
+// per the client's retry policy.
+// The request is rebuilt for each attempt so the body and auth are fresh.
+func (c *Client) send(
+	ctx context.Context,
+	method string,
+	uri *url.URL,
+	body interface{},
+	params []Vars,
+	requestOptions []uhttp.RequestOption,
+	doOptions ...uhttp.DoOption,
+) (*http.Response, *v2.RateLimitDescription, error) {
+	options := append([]uhttp.RequestOption{
+		uhttp.WithAcceptJSONHeader(),
+	}, requestOptions...)
+	if body != nil {
+		options = append(options, uhttp.WithJSONBody(body))
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := c.httpClient.NewRequest(ctx, method, uri, options...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(params) > 0 {
+			query := url.Values{}
+			for _, param := range params {
+				param.Apply(&query)
+			}
+
+			req.URL.RawQuery = query.Encode()
+		}
+
+		c.auth.Apply(req)
+
+		if err := c.rateLimiter.wait(ctx, req.URL.Host, req.URL.Path); err != nil {
+			return nil, nil, err
+		}
+
+		ratelimitData := &v2.RateLimitDescription{}
+		resp, err := c.httpClient.Do(req, append(doOptions, uhttp.WithRatelimitData(ratelimitData))...)
+		c.rateLimiter.describe(req.URL.Host, req.URL.Path, ratelimitData)
+		if err == nil {
+			return resp, ratelimitData, nil
+		}
+
+		wait, ok := c.retryPolicy.backoff(attempt, method, resp)
+		if !ok {
+			return resp, ratelimitData, err
+		}
+
+		ctxzap.Extract(ctx).Debug(
+			"databricks-connector: retrying request",
+			zap.String("method", method),
+			zap.String("url", uri.String()),
+			zap.Int("attempt", attempt),
+			zap.Duration("wait", wait),
+			zap.Error(err),
+		)
+
+		if err := sleep(ctx, wait); err != nil {
+			return resp, ratelimitData, err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package databricks
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests that failed with a 429 or 5xx are retried.
+// Idempotent methods (GET, PUT, DELETE) are retried on 429, 5xx and network
+// errors; POST and PATCH only on 429, where the API rejected the request
+// without acting on it.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// MaxWait is the longest single wait. A Retry-After beyond it isn't waited
+	// for; the error is returned instead.
+	MaxWait time.Duration
+	// BaseDelay is the backoff before the first retry, doubled for each one after.
+	BaseDelay time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy sets another one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MaxWait:     30 * time.Second,
+	BaseDelay:   500 * time.Millisecond,
+}
+
+// WithRetryPolicy sets the retry policy of the client.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = p
+	}
+}
+
+// backoff returns how long to wait before retrying a request that failed on
+// the given attempt (starting at 1) with resp, or false if it shouldn't be
+// retried. resp is nil for network errors.
+func (p RetryPolicy) backoff(attempt int, method string, resp *http.Response) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !p.retryable(method, resp) {
+		return 0, false
+	}
+
+	// Full jitter: a random wait up to the exponential backoff, so clients
+	// throttled together don't retry together.
+	limit := p.BaseDelay << (attempt - 1)
+	if limit <= 0 || limit > p.MaxWait {
+		limit = p.MaxWait
+	}
+	var wait time.Duration
+	if limit > 0 {
+		wait = rand.N(limit) // #nosec G404 -- jitter doesn't need a secure source.
+	}
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if retryAfter > p.MaxWait {
+				return 0, false
+			}
+			wait = max(wait, retryAfter)
+		}
+	}
+
+	return wait, true
+}
+
+func (p RetryPolicy) retryable(method string, resp *http.Response) bool {
+	idempotent := method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+	if resp == nil {
+		return idempotent
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package databricks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"Tue, 02 Jan 2024 03:04:15 GMT", 10 * time.Second, true},
+		{"Tue, 02 Jan 2024 03:04:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, MaxWait: 10 * time.Second, BaseDelay: time.Second}
+	status := func(code int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name    string
+		attempt int
+		method  string
+		resp    *http.Response
+		wantOK  bool
+	}{
+		{"get 429", 1, http.MethodGet, status(http.StatusTooManyRequests, ""), true},
+		{"get 503", 1, http.MethodGet, status(http.StatusServiceUnavailable, ""), true},
+		{"get network error", 1, http.MethodGet, nil, true},
+		{"get 404", 1, http.MethodGet, status(http.StatusNotFound, ""), false},
+		{"post 429", 1, http.MethodPost, status(http.StatusTooManyRequests, ""), true},
+		{"post 503", 1, http.MethodPost, status(http.StatusServiceUnavailable, ""), false},
+		{"patch network error", 1, http.MethodPatch, nil, false},
+		{"attempts exhausted", 3, http.MethodGet, status(http.StatusTooManyRequests, ""), false},
+		{"retry-after beyond max wait", 1, http.MethodGet, status(http.StatusTooManyRequests, "60"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := p.backoff(tt.attempt, tt.method, tt.resp)
+			if ok != tt.wantOK {
+				t.Fatalf("backoff() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (wait < 0 || wait > p.MaxWait) {
+				t.Errorf("backoff() wait = %v, want within [0, %v]", wait, p.MaxWait)
+			}
+		})
+	}
+
+	wait, _ := p.backoff(1, http.MethodGet, status(http.StatusTooManyRequests, "5"))
+	if wait < 5*time.Second {
+		t.Errorf("backoff() wait = %v, want at least the Retry-After of 5s", wait)
+	}
+}
+
+func TestClientRetriesThrottledRequests(t *testing.T) {
+	var gets, posts int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"message": "unavailable"}`))
+			return
+		}
+
+		gets++
+		if gets < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message": "slow down"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(
+		context.Background(), srv.Client(), "example.com", srv.Listener.Addr().String(), "acc-1", "", &NoAuth{}, nil,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MaxWait: time.Second, BaseDelay: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	var res struct {
+		OK bool `json:"ok"`
+	}
+	if _, err := c.Get(context.Background(), c.accountBaseUrl.JoinPath("/retry"), &res); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !res.OK || gets != 3 {
+		t.Errorf("got ok=%v after %d attempts, want ok=true after 3", res.OK, gets)
+	}
+
+	if _, err := c.Post(context.Background(), c.accountBaseUrl.JoinPath("/retry"), map[string]string{}, &res); err == nil {
+		t.Fatal("Post: expected error")
+	}
+	if posts != 1 {
+		t.Errorf("POST sent %d times, want 1", posts)
+	}
+}
@@ -0,0 +1,130 @@
+package databricks
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	statementsEndpoint = "/api/2.0/sql/statements"
+
+	statementWaitTimeout  = "30s"
+	statementPollInterval = time.Second
+)
+
+// StatementParameter is a named parameter of a SQL statement, referenced in
+// the statement as :name.
+type StatementParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+// StatementResult holds the rows of a SQL statement. Values are returned as
+// strings, or nil for NULL.
+type StatementResult struct {
+	Columns []string
+	Rows    [][]*string
+}
+
+type statementResponse struct {
+	StatementID string `json:"statement_id"`
+	Status      struct {
+		State string `json:"state"`
+		Error struct {
+			ErrorCode string `json:"error_code"`
+			Message   string `json:"message"`
+		} `json:"error"`
+	} `json:"status"`
+	Manifest struct {
+		Schema struct {
+			Columns []struct {
+				Name string `json:"name"`
+			} `json:"columns"`
+		} `json:"schema"`
+	} `json:"manifest"`
+	Result statementChunk `json:"result"`
+}
+
+type statementChunk struct {
+	DataArray             [][]*string `json:"data_array"`
+	NextChunkInternalLink string      `json:"next_chunk_internal_link"`
+}
+
+// ExecuteStatement runs a SQL statement on a SQL warehouse of a workspace with
+// the Statement Execution API, waiting for it to finish, and returns all its
+// rows. Results are read inline, so statements should bound their size, e.g.
+// with LIMIT.
+func (c *Client) ExecuteStatement(
+	ctx context.Context,
+	workspaceId string,
+	warehouseID string,
+	statement string,
+	params ...StatementParameter,
+) (*StatementResult, error) {
+	u := c.workspaceUrl(workspaceId).JoinPath(statementsEndpoint)
+
+	body := struct {
+		WarehouseID   string               `json:"warehouse_id"`
+		Statement     string               `json:"statement"`
+		Parameters    []StatementParameter `json:"parameters,omitempty"`
+		WaitTimeout   string               `json:"wait_timeout"`
+		OnWaitTimeout string               `json:"on_wait_timeout"`
+		Disposition   string               `json:"disposition"`
+		Format        string               `json:"format"`
+	}{
+		WarehouseID:   warehouseID,
+		Statement:     statement,
+		Parameters:    params,
+		WaitTimeout:   statementWaitTimeout,
+		OnWaitTimeout: "CONTINUE",
+		Disposition:   "INLINE",
+		Format:        "JSON_ARRAY",
+	}
+
+	var res statementResponse
+	if _, err := c.Post(ctx, u, body, &res); err != nil {
+		return nil, err
+	}
+
+	statementID := res.StatementID
+	for res.Status.State == "PENDING" || res.Status.State == "RUNNING" {
+		if err := sleep(ctx, statementPollInterval); err != nil {
+			return nil, err
+		}
+
+		res = statementResponse{}
+		if _, err := c.getUncached(ctx, u.JoinPath(statementID), &res); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.Status.State != "SUCCEEDED" {
+		return nil, fmt.Errorf("statement %s %s: %s %s", statementID, res.Status.State, res.Status.Error.ErrorCode, res.Status.Error.Message)
+	}
+
+	rv := &StatementResult{Rows: res.Result.DataArray}
+	for _, column := range res.Manifest.Schema.Columns {
+		rv.Columns = append(rv.Columns, column.Name)
+	}
+
+	next := res.Result.NextChunkInternalLink
+	for next != "" {
+		link, err := url.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse result chunk link: %w", err)
+		}
+
+		var chunk statementChunk
+		if _, err := c.Get(ctx, u.ResolveReference(link), &chunk); err != nil {
+			return nil, err
+		}
+
+		rv.Rows = append(rv.Rows, chunk.DataArray...)
+		next = chunk.NextChunkInternalLink
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,93 @@
+package databricks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExecuteStatement(t *testing.T) {
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/2.0/sql/statements", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			WarehouseID string               `json:"warehouse_id"`
+			Statement   string               `json:"statement"`
+			Parameters  []StatementParameter `json:"parameters"`
+			Disposition string               `json:"disposition"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.WarehouseID != "wh-1" || body.Statement != "SELECT :x" || body.Disposition != "INLINE" {
+			t.Errorf("unexpected body %+v", body)
+		}
+		if len(body.Parameters) != 1 || body.Parameters[0].Name != "x" {
+			t.Errorf("unexpected parameters %+v", body.Parameters)
+		}
+
+		_, _ = w.Write([]byte(`{"statement_id": "st-1", "status": {"state": "RUNNING"}}`))
+	})
+	mux.HandleFunc("GET /api/2.0/sql/statements/st-1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		_, _ = w.Write([]byte(`{
+			"statement_id": "st-1",
+			"status": {"state": "SUCCEEDED"},
+			"manifest": {"schema": {"columns": [{"name": "a"}, {"name": "b"}]}},
+			"result": {
+				"data_array": [["1", null]],
+				"next_chunk_internal_link": "/api/2.0/sql/statements/st-1/result/chunks/1"
+			}
+		}`))
+	})
+	mux.HandleFunc("GET /api/2.0/sql/statements/st-1/result/chunks/1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data_array": [["2", "two"]]}`))
+	})
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(
+		context.Background(), srv.Client(), "cloud.databricks.com", "accounts.cloud.databricks.com", "acc-1", "", &NoAuth{}, nil,
+		WithWorkspaceURLs(map[string]string{"dbc-abc": srv.URL}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	res, err := c.ExecuteStatement(context.Background(), "dbc-abc", "wh-1", "SELECT :x", StatementParameter{Name: "x", Value: "1"})
+	if err != nil {
+		t.Fatalf("ExecuteStatement: %v", err)
+	}
+
+	if polls != 1 {
+		t.Errorf("polled %d times, want 1", polls)
+	}
+	if len(res.Columns) != 2 || res.Columns[0] != "a" || res.Columns[1] != "b" {
+		t.Errorf("unexpected columns %v", res.Columns)
+	}
+	if len(res.Rows) != 2 || res.Rows[0][1] != nil || *res.Rows[1][1] != "two" {
+		t.Errorf("unexpected rows %v", res.Rows)
+	}
+}
+
+func TestExecuteStatementFailed(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"statement_id": "st-1", "status": {"state": "FAILED", "error": {"error_code": "BAD_REQUEST", "message": "no such table"}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(
+		context.Background(), srv.Client(), "cloud.databricks.com", "accounts.cloud.databricks.com", "acc-1", "", &NoAuth{}, nil,
+		WithWorkspaceURLs(map[string]string{"dbc-abc": srv.URL}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if _, err := c.ExecuteStatement(context.Background(), "dbc-abc", "wh-1", "SELECT 1"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
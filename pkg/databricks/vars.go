@@ -1,15 +1,19 @@
 package databricks
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
+	"strings"
 )
 
 type Vars interface {
 	Apply(params *url.Values)
 }
 
-// Pagination vars are used for paginating results from the API.
+// Pagination vars are used for paginating results from the API. Applied
+// after other pagination vars, they replace them.
 type PaginationVars struct {
 	Start uint `json:"startIndex"`
 	Count uint `json:"count"`
@@ -17,11 +21,11 @@ type PaginationVars struct {
 
 func (p *PaginationVars) Apply(params *url.Values) {
 	if p.Start > 0 {
-		params.Add("startIndex", fmt.Sprintf("%d", p.Start))
+		params.Set("startIndex", fmt.Sprintf("%d", p.Start))
 	}
 
 	if p.Count > 0 {
-		params.Add("count", fmt.Sprintf("%d", p.Count))
+		params.Set("count", fmt.Sprintf("%d", p.Count))
 	}
 }
 
@@ -43,12 +47,91 @@ func (f *FilterVars) Apply(params *url.Values) {
 	}
 }
 
-func NewFilterVars(filter string) *FilterVars {
+func NewFilterVars(filter Filter) *FilterVars {
 	return &FilterVars{
-		Filter: filter,
+		Filter: filter.String(),
 	}
 }
 
+// Filter is a SCIM filter expression (RFC 7644, section 3.4.2.2). Build it with
+// Eq, Co, Sw, And and Or rather than by formatting strings: values are quoted
+// and escaped, so names with quotes can't break out of the comparison.
+// Attribute names are not escaped and must come from code, not from input.
+type Filter struct {
+	expr     string
+	compound bool
+}
+
+// Eq matches resources whose attribute equals value.
+func Eq(attr, value string) Filter {
+	return compare(attr, "eq", value)
+}
+
+// Co matches resources whose attribute contains value.
+func Co(attr, value string) Filter {
+	return compare(attr, "co", value)
+}
+
+// Sw matches resources whose attribute starts with value.
+func Sw(attr, value string) Filter {
+	return compare(attr, "sw", value)
+}
+
+// Ge matches resources whose attribute is greater than or equal to value.
+func Ge(attr, value string) Filter {
+	return compare(attr, "ge", value)
+}
+
+// And matches resources that match every filter.
+func And(filters ...Filter) Filter {
+	return join("and", filters)
+}
+
+// Or matches resources that match any filter.
+func Or(filters ...Filter) Filter {
+	return join("or", filters)
+}
+
+func (f Filter) String() string {
+	return f.expr
+}
+
+func compare(attr, op, value string) Filter {
+	return Filter{expr: attr + " " + op + " " + quoteFilterValue(value)}
+}
+
+func join(op string, filters []Filter) Filter {
+	filters = slices.DeleteFunc(slices.Clone(filters), func(f Filter) bool { return f.expr == "" })
+	if len(filters) < 2 {
+		if len(filters) == 1 {
+			return filters[0]
+		}
+		return Filter{}
+	}
+
+	parts := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if f.compound {
+			parts = append(parts, "("+f.expr+")")
+			continue
+		}
+		parts = append(parts, f.expr)
+	}
+
+	return Filter{expr: strings.Join(parts, " "+op+" "), compound: true}
+}
+
+// quoteFilterValue quotes a value as the JSON string SCIM filters expect.
+func quoteFilterValue(value string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	// Encoding a string can't fail.
+	_ = enc.Encode(value)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
 // Attribute vars are used to specify which attributes to return from the API.
 type AttrVars struct {
 	Attrs []string `json:"attributes"`
@@ -70,6 +153,7 @@ func NewUserAttrVars() *AttrVars {
 			"userName",
 			"displayName",
 			"active",
+			"meta",
 		},
 	}
 }
@@ -88,6 +172,7 @@ func NewGroupAttrVars() *AttrVars {
 		Attrs: []string{
 			"id",
 			"displayName",
+			"meta",
 		},
 	}
 }
@@ -119,6 +204,16 @@ func NewServicePrincipalAttrVars() *AttrVars {
 			"displayName",
 			"active",
 			"applicationId",
+			"meta",
+		},
+	}
+}
+
+// NewIDAttrVars requests only resource IDs, to list what exists cheaply.
+func NewIDAttrVars() *AttrVars {
+	return &AttrVars{
+		Attrs: []string{
+			"id",
 		},
 	}
 }
@@ -0,0 +1,53 @@
+package databricks
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"eq", Eq("userName", "alice@example.com"), `userName eq "alice@example.com"`},
+		{"single quotes need no escaping", Eq("displayName", "O'Brien's team"), `displayName eq "O'Brien's team"`},
+		{"double quotes and backslashes are escaped", Eq("displayName", `say "hi" \o/`), `displayName eq "say \"hi\" \\o/"`},
+		{"injection stays inside the value", Eq("id", `x" or id pr or id eq "y`), `id eq "x\" or id pr or id eq \"y"`},
+		{"html is not escaped", Co("displayName", "R&D <eng>"), `displayName co "R&D <eng>"`},
+		{"sw", Sw("displayName", "data-"), `displayName sw "data-"`},
+		{"ge", Ge("meta.lastModified", "2024-05-01T00:00:00Z"), `meta.lastModified ge "2024-05-01T00:00:00Z"`},
+		{"and", And(Eq("active", "true"), Sw("userName", "a")), `active eq "true" and userName sw "a"`},
+		{
+			"nested groups are parenthesized",
+			And(Or(Eq("id", "1"), Eq("id", "2")), Co("displayName", "x")),
+			`(id eq "1" or id eq "2") and displayName co "x"`,
+		},
+		{"single operand is unwrapped", Or(And(Eq("id", "1"), Eq("id", "2"))), `id eq "1" and id eq "2"`},
+		{"empty operands are dropped", And(Filter{}, Eq("id", "1")), `id eq "1"`},
+		{"no operands", Or(), ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterVarsApply(t *testing.T) {
+	params := url.Values{}
+	NewFilterVars(Eq("displayName", "O'Brien's team")).Apply(&params)
+	if got := params.Get("filter"); got != `displayName eq "O'Brien's team"` {
+		t.Errorf("filter = %s", got)
+	}
+
+	params = url.Values{}
+	NewFilterVars(Filter{}).Apply(&params)
+	if params.Has("filter") {
+		t.Error("empty filter was added to the query")
+	}
+}